@@ -0,0 +1,177 @@
+package fda
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// engineAgreement is the agreement between each pair of engines.
+type engineAgreement struct {
+	Engines []string `json:"engines"`
+	IoU     float64  `json:"iou"`
+	// box-level agreement of each engine against each reference engine.
+	Boxes []boxAgreement `json:"boxes"`
+	// face count agreement of each pair of engines.
+	Counts []countAgreement `json:"counts"`
+}
+
+// boxAgreement is the box-level agreement of an engine against the reference engine on the images detected by both.
+type boxAgreement struct {
+	Reference      string  `json:"reference"`
+	Engine         string  `json:"engine"`
+	Images         int     `json:"images"`
+	ReferenceFaces int     `json:"reference_faces"`
+	Faces          int     `json:"faces"`
+	Matched        int     `json:"matched"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	F1             float64 `json:"f1"`
+	// mean IoU of the matched faces.
+	MeanIoU float64 `json:"mean_iou"`
+}
+
+func newEngineAgreement(rows []detectResultRow, engines []string, iou float64) engineAgreement {
+	ag := engineAgreement{
+		Engines: engines,
+		IoU:     iou,
+	}
+	for _, ref := range engines {
+		for _, e := range engines {
+			if e == ref {
+				continue
+			}
+			ag.Boxes = append(ag.Boxes, newBoxAgreement(rows, ref, e, iou))
+		}
+	}
+	for i, a := range engines {
+		for _, b := range engines[i+1:] {
+			ag.Counts = append(ag.Counts, newCountAgreement(rows, a, b))
+		}
+	}
+	return ag
+}
+
+func newBoxAgreement(rows []detectResultRow, ref, e string, iou float64) boxAgreement {
+	ag := boxAgreement{
+		Reference: ref,
+		Engine:    e,
+	}
+	iouSum := 0.0
+	for _, row := range rows {
+		refData, okRef := row.Results[ref]
+		data, ok := row.Results[e]
+		if !okRef || !ok {
+			continue
+		}
+		ag.Images++
+		ag.ReferenceFaces += len(refData.Faces)
+		ag.Faces += len(data.Faces)
+
+		matches, _, _ := matchFaces(data.Faces, refData.Faces, iou)
+		ag.Matched += len(matches)
+		for _, m := range matches {
+			iouSum += m.IoU
+		}
+	}
+
+	ag.Precision = ratio(ag.Matched, ag.Faces)
+	ag.Recall = ratio(ag.Matched, ag.ReferenceFaces)
+	ag.F1 = f1Score(ag.Precision, ag.Recall)
+	if ag.Matched != 0 {
+		ag.MeanIoU = iouSum / float64(ag.Matched)
+	}
+	return ag
+}
+
+// Box returns the box-level agreement of the engine against the reference.
+func (a engineAgreement) Box(ref, e string) (boxAgreement, bool) {
+	for _, b := range a.Boxes {
+		if b.Reference == ref && b.Engine == e {
+			return b, true
+		}
+	}
+	return boxAgreement{}, false
+}
+
+// Count returns the face count agreement of the pair.
+func (a engineAgreement) Count(e1, e2 string) (countAgreement, bool) {
+	for _, c := range a.Counts {
+		if (c.A == e1 && c.B == e2) || (c.A == e2 && c.B == e1) {
+			return c, true
+		}
+	}
+	return countAgreement{}, false
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func f1Score(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+// newConsensusResult returns the faces found by at least minVotes engines as the result of the name.
+// The confidence of the face is the ratio of the engines which found it.
+// It returns error when any of the engines does not have the result.
+func newConsensusResult(row detectResultRow, engines []string, name string, iou float64, minVotes int) (engine.FaceResult, error) {
+	if row.Error != "" {
+		return engine.FaceResult{}, errors.New(row.Error)
+	}
+
+	results := make([]engine.FaceResult, 0, len(engines))
+	for _, e := range engines {
+		data, ok := row.Results[e]
+		if !ok {
+			return engine.FaceResult{}, fmt.Errorf("result of engine '%s' is missing", e)
+		}
+		data.EngineName = e
+		results = append(results, data)
+	}
+
+	fused := fuseFaces(results, iou, minVotes)
+	faces := make([]engine.FaceData, len(fused))
+	for i, f := range fused {
+		faces[i] = f.FaceData
+		faces[i].Confidence = float64(f.Votes()) / float64(len(engines))
+	}
+	return engine.FaceResult{
+		EngineName: name,
+		Faces:      faces,
+	}, nil
+}
+
+// writeConsensusTSV writes the consensus labels in the format of detector's output, and returns the number of faces.
+// The count column is the number of consensus faces, to use the file as ground truth.
+func writeConsensusTSV(file string, result *detectResult, engines []string, name string, iou float64, minVotes int) (int, error) {
+	f, err := NewFileHandler(file)
+	if err != nil {
+		return 0, err
+	}
+
+	lines := make([]string, 0, len(result.Rows)+1)
+	lines = append(lines, strings.Join([]string{colPath, colCount, name + colSuffixCount, name + colSuffixDetail, colError}, "\t"))
+	total := 0
+	for _, row := range result.Rows {
+		r, err := newConsensusResult(row, engines, name, iou, minVotes)
+		if err != nil {
+			errMsg := strings.Join(strings.Fields(err.Error()), " ")
+			lines = append(lines, strings.Join([]string{row.Path, "", "", "", errMsg}, "\t"))
+			continue
+		}
+		total += len(r.Faces)
+		lines = append(lines, strings.Join([]string{row.Path, strconv.Itoa(len(r.Faces)), r.ShowOutput(), ""}, "\t"))
+	}
+	return total, f.WriteAll(lines)
+}
@@ -0,0 +1,161 @@
+package fda
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+const defaultLabelTemplate = `{{if gt .Confidence 0.0}}[{{printf "%.2f" .Confidence}}]{{end}}` +
+	`{{if or (gt .PercentWidth 0.0) (gt .PercentHeight 0.0)}} [W:{{printf "%.2f" .PercentWidth}},H:{{printf "%.2f" .PercentHeight}}]{{end}}`
+
+var defaultFont *truetype.Font
+
+func init() {
+	f, err := truetype.Parse(gobold.TTF)
+	if err != nil {
+		panic("[ERROR] cannot load font gobold.TTF")
+	}
+	defaultFont = f
+}
+
+// annotateStyle is styling options of annotation.
+type annotateStyle struct {
+	// line width in pixels. (0 means relative to image size)
+	LineWidth int
+	// font size of label. (0 means relative to image size)
+	FontSize float64
+	// multiplier of the relative line width and font size.
+	Scale float64
+
+	BoxColor   color.Color
+	TextColor  color.Color
+	TitleColor color.Color
+	// translucent fill color of face box. (nil means no fill)
+	FillColor color.Color
+	// background color of label. (nil means no background)
+	LabelBackground color.Color
+
+	font  *truetype.Font
+	label *template.Template
+
+	mu    sync.Mutex
+	faces map[float64]font.Face
+}
+
+func newAnnotateStyle() *annotateStyle {
+	return &annotateStyle{
+		Scale:      1,
+		BoxColor:   colorRed,
+		TextColor:  colorRed,
+		TitleColor: colorBlue,
+		font:       defaultFont,
+		label:      template.Must(template.New("label").Parse(defaultLabelTemplate)),
+		faces:      make(map[float64]font.Face),
+	}
+}
+
+// setFontFile loads TTF font file.
+func (s *annotateStyle) setFontFile(path string) error {
+	byt, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := truetype.Parse(byt)
+	if err != nil {
+		return err
+	}
+	s.font = f
+	return nil
+}
+
+// setLabelTemplate sets text/template of label.
+func (s *annotateStyle) setLabelTemplate(text string) error {
+	tmpl, err := template.New("label").Parse(text)
+	if err != nil {
+		return err
+	}
+	s.label = tmpl
+	return nil
+}
+
+// getLineWidth returns the line width for the image.
+func (s *annotateStyle) getLineWidth(bounds image.Rectangle) int {
+	if s.LineWidth > 0 {
+		return s.LineWidth
+	}
+
+	w := int(math.Round(float64(shortSide(bounds)) / 250 * s.Scale))
+	if w < 1 {
+		return 1
+	}
+	return w
+}
+
+// getFontSize returns the font size of label for the image.
+func (s *annotateStyle) getFontSize(bounds image.Rectangle) float64 {
+	if s.FontSize > 0 {
+		return s.FontSize
+	}
+
+	size := math.Round(float64(shortSide(bounds)) / 40 * s.Scale)
+	if size < 8 {
+		return 8
+	}
+	return size
+}
+
+// getFontFace returns cached font.Face of the size.
+func (s *annotateStyle) getFontFace(size float64) font.Face {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.faces[size]; ok {
+		return f
+	}
+	f := truetype.NewFace(s.font, &truetype.Options{
+		Size: size,
+	})
+	s.faces[size] = f
+	return f
+}
+
+// getLabel returns the label text of the face.
+func (s *annotateStyle) getLabel(engineName string, index int, f engine.FaceData) (string, error) {
+	var buf bytes.Buffer
+	err := s.label.Execute(&buf, annotateLabel{
+		FaceData: f,
+		Engine:   engineName,
+		Index:    index,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// annotateLabel is data for the label template.
+type annotateLabel struct {
+	engine.FaceData
+	Engine string
+	Index  int
+}
+
+func shortSide(bounds image.Rectangle) int {
+	if bounds.Dx() < bounds.Dy() {
+		return bounds.Dx()
+	}
+	return bounds.Dy()
+}
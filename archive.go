@@ -0,0 +1,569 @@
+package fda
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// archiveSeparator separates the archive file and the entry name in the path. (e.g. images.zip!/train/001.jpg)
+const archiveSeparator = "!/"
+
+const (
+	// default max number of archive files kept opened.
+	maxCachedArchives = 64
+	// default max size of an entry read into memory.
+	defaultMaxArchiveEntrySize = 100 << 20
+
+	// max number and total size of the entries kept by the cursor of compressed tar,
+	// to read the entries requested slightly out of order without rewinding.
+	tarBufferEntries = 64
+	tarBufferSize    = 256 << 20
+)
+
+var archiveExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// isArchiveFile checks the file is zip or tar by the file extension.
+func isArchiveFile(filePath string) bool {
+	p := strings.ToLower(filePath)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(p, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitArchivePath splits the path like 'images.zip!/train/001.jpg' into the archive file and the entry name.
+func splitArchivePath(filePath string) (archivePath, name string, ok bool) {
+	i := strings.Index(filePath, archiveSeparator)
+	if i < 0 {
+		return "", "", false
+	}
+
+	archivePath = filePath[:i]
+	if !isArchiveFile(archivePath) {
+		return "", "", false
+	}
+	return archivePath, cleanEntryName(filePath[i+len(archiveSeparator):]), true
+}
+
+func isArchivePath(filePath string) bool {
+	_, _, ok := splitArchivePath(filePath)
+	return ok
+}
+
+func joinArchivePath(archivePath, name string) string {
+	return archivePath + archiveSeparator + name
+}
+
+// cleanEntryName removes './' and '/' from the head of the entry name.
+func cleanEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// archiveFile is zip or tar file which contains image files.
+type archiveFile interface {
+	// Walk calls fn with each regular file in the order of the archive.
+	Walk(fn func(name string, info os.FileInfo, r io.Reader) error) error
+	ReadFile(name string) ([]byte, os.FileInfo, error)
+	Close() error
+}
+
+// openArchive opens the archive file. ReadFile returns error for the entry larger than maxEntrySize. (0 means no limit)
+func openArchive(filePath string, maxEntrySize int64) (archiveFile, error) {
+	p := strings.ToLower(filePath)
+	switch {
+	case strings.HasSuffix(p, ".zip"):
+		return openZipArchive(filePath, maxEntrySize)
+	case strings.HasSuffix(p, ".tar"):
+		return openTarArchive(filePath, false, maxEntrySize)
+	case strings.HasSuffix(p, ".tar.gz"), strings.HasSuffix(p, ".tgz"):
+		return openTarArchive(filePath, true, maxEntrySize)
+	}
+	return nil, fmt.Errorf("unsupported archive file: [%s]", filePath)
+}
+
+// checkEntrySize returns error when the size is larger than the limit. (0 means no limit)
+func checkEntrySize(name string, size, maxSize int64) error {
+	if maxSize > 0 && size > maxSize {
+		return fmt.Errorf("too large entry size=[%d] entry=[%s]", size, name)
+	}
+	return nil
+}
+
+// readEntry reads the entry data up to the limit, since the size in the header may be wrong.
+func readEntry(name string, r io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && int64(len(byt)) > maxSize {
+		return nil, fmt.Errorf("too large entry size=[>%d] entry=[%s]", maxSize, name)
+	}
+	return byt, nil
+}
+
+type zipArchive struct {
+	r            *zip.ReadCloser
+	files        map[string]*zip.File
+	maxEntrySize int64
+}
+
+func openZipArchive(filePath string, maxEntrySize int64) (*zipArchive, error) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		if f.FileInfo().Mode().IsRegular() {
+			files[cleanEntryName(f.Name)] = f
+		}
+	}
+	return &zipArchive{
+		r:            r,
+		files:        files,
+		maxEntrySize: maxEntrySize,
+	}, nil
+}
+
+func (a *zipArchive) Walk(fn func(name string, info os.FileInfo, r io.Reader) error) error {
+	for _, f := range a.r.File {
+		if !f.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = fn(cleanEntryName(f.Name), f.FileInfo(), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *zipArchive) ReadFile(name string) ([]byte, os.FileInfo, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("entry not found: [%s]", name)
+	}
+	if err := checkEntrySize(name, int64(f.UncompressedSize64), a.maxEntrySize); err != nil {
+		return nil, nil, err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rc.Close()
+
+	byt, err := readEntry(name, rc, a.maxEntrySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return byt, f.FileInfo(), nil
+}
+
+func (a *zipArchive) Close() error {
+	return a.r.Close()
+}
+
+// tarArchive reads tar file by the index of entry offsets,
+// or reads compressed tar file sequentially since it cannot seek.
+type tarArchive struct {
+	path         string
+	compressed   bool
+	maxEntrySize int64
+
+	// for uncompressed tar.
+	f     *os.File
+	index map[string]tarEntry
+
+	// for compressed tar.
+	mu     sync.Mutex
+	cursor *tarCursor
+}
+
+type tarEntry struct {
+	offset int64
+	info   os.FileInfo
+}
+
+func openTarArchive(filePath string, compressed bool, maxEntrySize int64) (*tarArchive, error) {
+	a := &tarArchive{
+		path:         filePath,
+		compressed:   compressed,
+		maxEntrySize: maxEntrySize,
+	}
+	if compressed {
+		return a, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	index, err := indexTar(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	a.f = f
+	a.index = index
+	return a, nil
+}
+
+// indexTar reads the headers and records the offset of the data.
+// tar.Reader skips the data by Seek, so it does not read whole file.
+func indexTar(f *os.File) (map[string]tarEntry, error) {
+	index := make(map[string]tarEntry)
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return index, nil
+		case err != nil:
+			return nil, err
+		}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		index[cleanEntryName(h.Name)] = tarEntry{
+			offset: offset,
+			info:   h.FileInfo(),
+		}
+	}
+}
+
+func (a *tarArchive) Walk(fn func(name string, info os.FileInfo, r io.Reader) error) error {
+	c, err := newTarCursor(a.path, a.compressed)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for {
+		h, err := c.tr.Next()
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if err := fn(cleanEntryName(h.Name), h.FileInfo(), c.tr); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *tarArchive) ReadFile(name string) ([]byte, os.FileInfo, error) {
+	if a.compressed {
+		return a.readFileSequentially(name)
+	}
+
+	e, ok := a.index[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("entry not found: [%s]", name)
+	}
+	if err := checkEntrySize(name, e.info.Size(), a.maxEntrySize); err != nil {
+		return nil, nil, err
+	}
+	byt := make([]byte, e.info.Size())
+	if _, err := a.f.ReadAt(byt, e.offset); err != nil {
+		return nil, nil, err
+	}
+	return byt, e.info, nil
+}
+
+// readFileSequentially continues to read from the last position.
+// The recently passed entries are kept in the buffer, since the concurrent workers request the entries almost in order but not exactly.
+// It rewinds only when the entry is already passed and not in the buffer, so random access is slow.
+func (a *tarArchive) readFileSequentially(name string) ([]byte, os.FileInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if c := a.cursor; c != nil {
+		if c.lastName == name {
+			return c.lastData, c.lastInfo, nil
+		}
+		if e, ok := c.takeBuffered(name); ok {
+			return e.data, e.info, nil
+		}
+		if _, ok := c.passed[name]; ok {
+			c.Close()
+			a.cursor = nil
+		}
+	}
+	if a.cursor == nil {
+		c, err := newTarCursor(a.path, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.maxEntrySize = a.maxEntrySize
+		a.cursor = c
+	}
+
+	byt, info, err := a.cursor.find(name)
+	if err != nil {
+		a.cursor.Close()
+		a.cursor = nil
+	}
+	return byt, info, err
+}
+
+func (a *tarArchive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cursor != nil {
+		a.cursor.Close()
+		a.cursor = nil
+	}
+	if a.f != nil {
+		return a.f.Close()
+	}
+	return nil
+}
+
+// tarCursor is a reading position of tar file.
+type tarCursor struct {
+	f  *os.File
+	gz *gzip.Reader
+	tr *tar.Reader
+
+	maxEntrySize int64
+	passed       map[string]struct{}
+	lastName     string
+	lastData     []byte
+	lastInfo     os.FileInfo
+
+	// passed entries in the order of the archive, which are not requested yet.
+	buffered     map[string]tarBufferedEntry
+	bufferOrder  []string
+	bufferedSize int64
+}
+
+type tarBufferedEntry struct {
+	data []byte
+	info os.FileInfo
+}
+
+func newTarCursor(filePath string, compressed bool) (*tarCursor, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &tarCursor{
+		f:        f,
+		passed:   make(map[string]struct{}),
+		buffered: make(map[string]tarBufferedEntry),
+	}
+	if !compressed {
+		c.tr = tar.NewReader(f)
+		return c, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	c.gz = gz
+	c.tr = tar.NewReader(gz)
+	return c, nil
+}
+
+func (c *tarCursor) find(name string) ([]byte, os.FileInfo, error) {
+	for {
+		h, err := c.tr.Next()
+		switch {
+		case err == io.EOF:
+			return nil, nil, fmt.Errorf("entry not found: [%s]", name)
+		case err != nil:
+			return nil, nil, err
+		}
+
+		entryName := cleanEntryName(h.Name)
+		c.passed[entryName] = struct{}{}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if entryName != name {
+			if err := c.buffer(entryName, h); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if err := checkEntrySize(name, h.Size, c.maxEntrySize); err != nil {
+			return nil, nil, err
+		}
+		byt, err := readEntry(name, c.tr, c.maxEntrySize)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.lastName = name
+		c.lastData = byt
+		c.lastInfo = h.FileInfo()
+		return c.lastData, c.lastInfo, nil
+	}
+}
+
+// buffer keeps the passed entry, and drops the oldest entries over the limits.
+// The entry larger than the limits is not kept.
+func (c *tarCursor) buffer(name string, h *tar.Header) error {
+	if checkEntrySize(name, h.Size, c.maxEntrySize) != nil || h.Size > tarBufferSize {
+		return nil
+	}
+	byt, err := readEntry(name, c.tr, c.maxEntrySize)
+	if err != nil {
+		return err
+	}
+
+	if old, ok := c.buffered[name]; ok {
+		c.bufferedSize -= int64(len(old.data))
+	} else {
+		c.bufferOrder = append(c.bufferOrder, name)
+	}
+	c.buffered[name] = tarBufferedEntry{data: byt, info: h.FileInfo()}
+	c.bufferedSize += int64(len(byt))
+
+	// the names already taken are also removed from the head.
+	for len(c.bufferOrder) > 0 {
+		oldest := c.bufferOrder[0]
+		e, ok := c.buffered[oldest]
+		if ok && len(c.buffered) <= tarBufferEntries && c.bufferedSize <= tarBufferSize {
+			break
+		}
+		c.bufferOrder = c.bufferOrder[1:]
+		if ok {
+			c.bufferedSize -= int64(len(e.data))
+			delete(c.buffered, oldest)
+		}
+	}
+	return nil
+}
+
+// takeBuffered returns the buffered entry and removes it from the buffer.
+func (c *tarCursor) takeBuffered(name string) (tarBufferedEntry, bool) {
+	e, ok := c.buffered[name]
+	if !ok {
+		return e, false
+	}
+	delete(c.buffered, name)
+	c.bufferedSize -= int64(len(e.data))
+	c.lastName = name
+	c.lastData = e.data
+	c.lastInfo = e.info
+	return e, true
+}
+
+func (c *tarCursor) Close() error {
+	if c.gz != nil {
+		c.gz.Close()
+	}
+	return c.f.Close()
+}
+
+// archiveCache keeps the opened archive files to read the entries repeatedly.
+type archiveCache struct {
+	// max number of archive files kept opened.
+	max int
+	// max size of an entry. (0 means no limit)
+	maxEntrySize int64
+
+	mu   sync.Mutex
+	list map[string]archiveFile
+}
+
+func newArchiveCache(max int, maxEntrySize int64) *archiveCache {
+	if max < 0 {
+		max = 0
+	}
+	return &archiveCache{
+		max:          max,
+		maxEntrySize: maxEntrySize,
+		list:         make(map[string]archiveFile),
+	}
+}
+
+// archives is shared by the commands reading the image paths in archive files without Pipeline.
+var archives = newArchiveCache(maxCachedArchives, defaultMaxArchiveEntrySize)
+
+// ReadFile reads the entry from the path like 'images.zip!/train/001.jpg'.
+func (c *archiveCache) ReadFile(filePath string) ([]byte, os.FileInfo, error) {
+	archivePath, name, ok := splitArchivePath(filePath)
+	if !ok {
+		return nil, nil, fmt.Errorf("not archive path: [%s]", filePath)
+	}
+
+	a, cached, err := c.get(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cached {
+		defer a.Close()
+	}
+	return a.ReadFile(name)
+}
+
+func (c *archiveCache) get(archivePath string) (a archiveFile, cached bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if a, ok := c.list[archivePath]; ok {
+		return a, true, nil
+	}
+
+	a, err = openArchive(archivePath, c.maxEntrySize)
+	if err != nil {
+		return nil, false, err
+	}
+	// too many archives are opened and closed each time.
+	if len(c.list) >= c.max {
+		return a, false, nil
+	}
+	c.list[archivePath] = a
+	return a, true, nil
+}
+
+// Close closes the cached archive files.
+func (c *archiveCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for p, a := range c.list {
+		if e := a.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(c.list, p)
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package fda
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testArchiveEntries are the entries of the test archives, and the last one is larger than the others.
+var testArchiveEntries = []struct {
+	name string
+	data string
+}{
+	{"img/000.jpg", "000"},
+	{"img/001.jpg", "001"},
+	{"img/002.jpg", "002"},
+	{"img/003.jpg", "003"},
+	{"img/large.jpg", strings.Repeat("x", 100)},
+}
+
+func writeTestTar(t *testing.T, path string, compressed bool) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if compressed {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	for _, e := range testArchiveEntries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func writeTestZip(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	defer zw.Close()
+	for _, e := range testArchiveEntries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestArchiveReadFileMaxEntrySize(t *testing.T) {
+	dir, cleanup := newTestTempDir(t)
+	defer cleanup()
+
+	writeTestZip(t, filepath.Join(dir, "a.zip"))
+	writeTestTar(t, filepath.Join(dir, "a.tar"), false)
+	writeTestTar(t, filepath.Join(dir, "a.tar.gz"), true)
+
+	for _, name := range []string{"a.zip", "a.tar", "a.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			a, err := openArchive(filepath.Join(dir, name), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.Close()
+
+			byt, _, err := a.ReadFile("img/001.jpg")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(byt) != "001" {
+				t.Errorf("data = %s, want 001", byt)
+			}
+			if _, _, err := a.ReadFile("img/large.jpg"); err == nil || !strings.Contains(err.Error(), "too large") {
+				t.Errorf("err = %v, want too large error", err)
+			}
+		})
+	}
+}
+
+func TestTarArchiveReadFileOutOfOrder(t *testing.T) {
+	dir, cleanup := newTestTempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "a.tar.gz")
+	writeTestTar(t, path, true)
+
+	a, err := openTarArchive(path, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	read := func(i int) {
+		t.Helper()
+		name := fmt.Sprintf("img/%03d.jpg", i)
+		byt, _, err := a.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("%03d", i); string(byt) != want {
+			t.Errorf("data of %s = %s, want %s", name, byt, want)
+		}
+	}
+
+	read(1)
+	cursor := a.cursor
+	// the passed entries are read from the buffer without rewinding.
+	for _, i := range []int{0, 3, 2} {
+		read(i)
+	}
+	if a.cursor != cursor {
+		t.Error("cursor is rewound, want to read the passed entries from the buffer")
+	}
+
+	// the entry taken from the buffer is read again by rewinding.
+	read(0)
+	if a.cursor == cursor {
+		t.Error("cursor is not rewound, want to read the entry from the start")
+	}
+}
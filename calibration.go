@@ -0,0 +1,390 @@
+package fda
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// methods of confidence calibration.
+const (
+	calibrationPlatt    = "platt"
+	calibrationIsotonic = "isotonic"
+)
+
+// number of the bins to compute expected calibration error.
+const calibrationBins = 10
+
+// calibration is the calibration models of the engines fitted against the ground truth.
+type calibration struct {
+	Truth   string              `json:"truth"`
+	IoU     float64             `json:"iou"`
+	Engines []engineCalibration `json:"engines"`
+}
+
+// Get returns the calibration of the engine.
+func (c calibration) Get(e string) (engineCalibration, bool) {
+	for _, ec := range c.Engines {
+		if ec.Engine == e {
+			return ec, true
+		}
+	}
+	return engineCalibration{}, false
+}
+
+// engineCalibration maps the confidence of an engine into the probability of matching the ground truth.
+type engineCalibration struct {
+	Engine    string `json:"engine"`
+	Method    string `json:"method"`
+	Samples   int    `json:"samples"`
+	Positives int    `json:"positives"`
+
+	// parameters of platt scaling: 1 / (1 + exp(A * confidence + B))
+	A float64 `json:"a,omitempty"`
+	B float64 `json:"b,omitempty"`
+	// points of isotonic regression in ascending order of confidence, which are linearly interpolated.
+	Confidences   []float64 `json:"confidences,omitempty"`
+	Probabilities []float64 `json:"probabilities,omitempty"`
+
+	// Brier score and expected calibration error on the samples before and after calibration.
+	BrierBefore float64 `json:"brier_before"`
+	BrierAfter  float64 `json:"brier_after"`
+	ECEBefore   float64 `json:"ece_before"`
+	ECEAfter    float64 `json:"ece_after"`
+}
+
+// fitCalibration fits the calibration of the engine by the detected faces matched with the ground truth.
+func fitCalibration(e, method string, scored []scoredFace) (engineCalibration, error) {
+	c := engineCalibration{
+		Engine:  e,
+		Method:  method,
+		Samples: len(scored),
+	}
+	for _, s := range scored {
+		if s.TP {
+			c.Positives++
+		}
+	}
+	if c.Samples == 0 {
+		return c, fmt.Errorf("no detected face to fit calibration: engine=[%s]", e)
+	}
+
+	switch method {
+	case calibrationPlatt:
+		c.A, c.B = fitPlatt(scored)
+	case calibrationIsotonic:
+		c.Confidences, c.Probabilities = fitIsotonic(scored)
+	default:
+		return c, fmt.Errorf("unknown calibration method: [%s]", method)
+	}
+
+	before := make([]float64, len(scored))
+	after := make([]float64, len(scored))
+	for i, s := range scored {
+		before[i] = s.Confidence
+		after[i] = c.Apply(s.Confidence)
+	}
+	c.BrierBefore = brierScore(before, scored)
+	c.BrierAfter = brierScore(after, scored)
+	c.ECEBefore = expectedCalibrationError(before, scored)
+	c.ECEAfter = expectedCalibrationError(after, scored)
+	return c, nil
+}
+
+// Apply returns the calibrated confidence.
+func (c engineCalibration) Apply(confidence float64) float64 {
+	switch c.Method {
+	case calibrationPlatt:
+		return sigmoid(-(c.A*confidence + c.B))
+	case calibrationIsotonic:
+		return interpolate(c.Confidences, c.Probabilities, confidence)
+	}
+	return confidence
+}
+
+// ApplyResult returns the result whose confidence is calibrated.
+func (c engineCalibration) ApplyResult(r engine.FaceResult) engine.FaceResult {
+	faces := make([]engine.FaceData, len(r.Faces))
+	for i, f := range r.Faces {
+		faces[i] = f
+		faces[i].Confidence = c.Apply(f.Confidence)
+	}
+	r.Faces = faces
+	return r
+}
+
+// fitPlatt fits the parameters of platt scaling by Newton's method with backtracking line search.
+// (Lin, Lin and Weng, "A note on Platt's probabilistic outputs for support vector machines")
+func fitPlatt(scored []scoredFace) (a, b float64) {
+	const (
+		maxIter = 100
+		minStep = 1e-10
+		sigma   = 1e-12
+		eps     = 1e-5
+	)
+
+	prior1, prior0 := 0.0, 0.0
+	for _, s := range scored {
+		if s.TP {
+			prior1++
+		} else {
+			prior0++
+		}
+	}
+	// the targets are smoothed to avoid overfitting.
+	hiTarget := (prior1 + 1) / (prior1 + 2)
+	loTarget := 1 / (prior0 + 2)
+	targets := make([]float64, len(scored))
+	for i, s := range scored {
+		targets[i] = loTarget
+		if s.TP {
+			targets[i] = hiTarget
+		}
+	}
+
+	loss := func(a, b float64) float64 {
+		v := 0.0
+		for i, s := range scored {
+			fApB := s.Confidence*a + b
+			if fApB >= 0 {
+				v += targets[i]*fApB + math.Log1p(math.Exp(-fApB))
+			} else {
+				v += (targets[i]-1)*fApB + math.Log1p(math.Exp(fApB))
+			}
+		}
+		return v
+	}
+
+	a, b = 0, math.Log((prior0+1)/(prior1+1))
+	fval := loss(a, b)
+	for iter := 0; iter < maxIter; iter++ {
+		h11, h22, h21, g1, g2 := sigma, sigma, 0.0, 0.0, 0.0
+		for i, s := range scored {
+			// p is the probability of positive, and q = 1 - p.
+			p := sigmoid(-(s.Confidence*a + b))
+			q := 1 - p
+			d2 := p * q
+			h11 += s.Confidence * s.Confidence * d2
+			h22 += d2
+			h21 += s.Confidence * d2
+			d1 := targets[i] - p
+			g1 += s.Confidence * d1
+			g2 += d1
+		}
+		if math.Abs(g1) < eps && math.Abs(g2) < eps {
+			break
+		}
+
+		det := h11*h22 - h21*h21
+		dA := -(h22*g1 - h21*g2) / det
+		dB := -(-h21*g1 + h11*g2) / det
+		gd := g1*dA + g2*dB
+
+		step := 1.0
+		for ; step >= minStep; step /= 2 {
+			newA := a + step*dA
+			newB := b + step*dB
+			if newF := loss(newA, newB); newF < fval+0.0001*step*gd {
+				a, b, fval = newA, newB, newF
+				break
+			}
+		}
+		if step < minStep {
+			break
+		}
+	}
+	return a, b
+}
+
+// fitIsotonic fits the non-decreasing step function by pool adjacent violators algorithm,
+// and returns the points of both ends of each step.
+func fitIsotonic(scored []scoredFace) (confidences, probabilities []float64) {
+	type block struct {
+		Min    float64
+		Max    float64
+		Sum    float64
+		Weight float64
+	}
+	sorted := make([]scoredFace, len(scored))
+	copy(sorted, scored)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Confidence < sorted[j].Confidence
+	})
+
+	var blocks []block
+	for _, s := range sorted {
+		v := 0.0
+		if s.TP {
+			v = 1
+		}
+		// the faces of the same confidence are in the same block.
+		if n := len(blocks); n != 0 && blocks[n-1].Max == s.Confidence {
+			blocks[n-1].Sum += v
+			blocks[n-1].Weight++
+		} else {
+			blocks = append(blocks, block{Min: s.Confidence, Max: s.Confidence, Sum: v, Weight: 1})
+		}
+
+		for n := len(blocks); n > 1; n = len(blocks) {
+			prev, last := blocks[n-2], blocks[n-1]
+			if prev.Sum/prev.Weight < last.Sum/last.Weight {
+				break
+			}
+			blocks[n-2] = block{Min: prev.Min, Max: last.Max, Sum: prev.Sum + last.Sum, Weight: prev.Weight + last.Weight}
+			blocks = blocks[:n-1]
+		}
+	}
+
+	for _, b := range blocks {
+		p := b.Sum / b.Weight
+		confidences = append(confidences, b.Min)
+		probabilities = append(probabilities, p)
+		if b.Max != b.Min {
+			confidences = append(confidences, b.Max)
+			probabilities = append(probabilities, p)
+		}
+	}
+	return confidences, probabilities
+}
+
+// interpolate returns the linearly interpolated value at x, which is clipped by the both ends.
+func interpolate(xs, ys []float64, x float64) float64 {
+	n := len(xs)
+	switch {
+	case n == 0:
+		return x
+	case x <= xs[0]:
+		return ys[0]
+	case x >= xs[n-1]:
+		return ys[n-1]
+	}
+
+	i := sort.SearchFloat64s(xs, x)
+	if xs[i] == x {
+		return ys[i]
+	}
+	ratio := (x - xs[i-1]) / (xs[i] - xs[i-1])
+	return ys[i-1] + (ys[i]-ys[i-1])*ratio
+}
+
+func sigmoid(v float64) float64 {
+	if v >= 0 {
+		return 1 / (1 + math.Exp(-v))
+	}
+	e := math.Exp(v)
+	return e / (1 + e)
+}
+
+// brierScore returns the mean squared error of the probabilities.
+func brierScore(probabilities []float64, scored []scoredFace) float64 {
+	if len(scored) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for i, s := range scored {
+		d := probabilities[i]
+		if s.TP {
+			d -= 1
+		}
+		sum += d * d
+	}
+	return sum / float64(len(scored))
+}
+
+// expectedCalibrationError returns the weighted mean of the gap between the mean probability and the ratio of true positive,
+// on the equal width bins of the probability.
+func expectedCalibrationError(probabilities []float64, scored []scoredFace) float64 {
+	if len(scored) == 0 {
+		return 0
+	}
+	var sums, positives, counts [calibrationBins]float64
+	for i, s := range scored {
+		p := math.Max(0, math.Min(1, probabilities[i]))
+		bin := int(p * calibrationBins)
+		if bin == calibrationBins {
+			bin--
+		}
+		sums[bin] += p
+		counts[bin]++
+		if s.TP {
+			positives[bin]++
+		}
+	}
+
+	ece := 0.0
+	for i := range counts {
+		if counts[i] == 0 {
+			continue
+		}
+		ece += math.Abs(sums[i]-positives[i]) / float64(len(scored))
+	}
+	return ece
+}
+
+// readCalibration reads the calibration file.
+func readCalibration(file string) (calibration, error) {
+	var c calibration
+	byt, err := ioutil.ReadFile(file)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(byt, &c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// writeCalibration writes the calibration file.
+func writeCalibration(file string, c calibration) error {
+	f, err := NewFileHandler(file)
+	if err != nil {
+		return err
+	}
+	byt, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return f.WriteAll([]string{string(byt)})
+}
+
+// writeCalibratedTSV writes the result whose confidence of the engines in the calibration is calibrated.
+// The columns of the result are kept as they are.
+func writeCalibratedTSV(file string, result *detectResult, c calibration) error {
+	f, err := NewFileHandler(file)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, 0, len(result.Rows)+1)
+	lines = append(lines, strings.Join(result.Header, "\t"))
+	for _, row := range result.Rows {
+		cols := make([]string, len(result.Header))
+		for i, h := range result.Header {
+			cols[i] = row.Line[h]
+			if !strings.HasSuffix(h, colSuffixDetail) {
+				continue
+			}
+			e := strings.TrimSuffix(h, colSuffixDetail)
+			data, ok := row.Results[e]
+			if !ok {
+				continue
+			}
+			ec, ok := c.Get(e)
+			if !ok {
+				continue
+			}
+			byt, err := json.Marshal(ec.ApplyResult(data))
+			if err != nil {
+				return err
+			}
+			cols[i] = string(byt)
+		}
+		lines = append(lines, strings.Join(cols, "\t"))
+	}
+	return f.WriteAll(lines)
+}
@@ -0,0 +1,154 @@
+package fda
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newPlattScoredFaces returns the faces on the confidence grid, whose ratio of true positive is 1 / (1 + exp(a * confidence + b)).
+func newPlattScoredFaces(a, b float64) []scoredFace {
+	const perPoint = 1000
+	var scored []scoredFace
+	for i := 0; i <= 10; i++ {
+		confidence := float64(i) / 10
+		positives := int(math.Round(sigmoid(-(a*confidence + b)) * perPoint))
+		for j := 0; j < perPoint; j++ {
+			scored = append(scored, scoredFace{Confidence: confidence, TP: j < positives})
+		}
+	}
+	return scored
+}
+
+func TestFitPlatt(t *testing.T) {
+	tests := []struct {
+		a float64
+		b float64
+	}{
+		{a: -4, b: 2},
+		{a: -8, b: 3},
+		{a: 2, b: -1},
+	}
+	for _, tt := range tests {
+		a, b := fitPlatt(newPlattScoredFaces(tt.a, tt.b))
+		if math.Abs(a-tt.a) > 0.05 || math.Abs(b-tt.b) > 0.05 {
+			t.Errorf("fitPlatt = (%f, %f), want (%f, %f)", a, b, tt.a, tt.b)
+		}
+	}
+}
+
+func TestFitIsotonic(t *testing.T) {
+	scored := []scoredFace{
+		{Confidence: 0.9, TP: true},
+		{Confidence: 0.1, TP: false},
+		{Confidence: 0.2, TP: true},
+		{Confidence: 0.3, TP: false},
+		{Confidence: 0.5, TP: true},
+		{Confidence: 0.5, TP: false},
+		{Confidence: 0.6, TP: false},
+		{Confidence: 0.8, TP: true},
+	}
+	confidences, probabilities := fitIsotonic(scored)
+
+	// 0.2, 0.3 and the tie of 0.5 are pooled into 0.5, then 0.6 into 0.4. 0.8 and 0.9 are pooled into 1.
+	wantConfidences := []float64{0.1, 0.2, 0.6, 0.8, 0.9}
+	wantProbabilities := []float64{0, 0.4, 0.4, 1, 1}
+	if !reflect.DeepEqual(confidences, wantConfidences) {
+		t.Errorf("confidences = %v, want %v", confidences, wantConfidences)
+	}
+	if len(probabilities) != len(wantProbabilities) {
+		t.Fatalf("probabilities = %v, want %v", probabilities, wantProbabilities)
+	}
+	for i := range probabilities {
+		if !floatEqual(probabilities[i], wantProbabilities[i]) {
+			t.Errorf("probabilities = %v, want %v", probabilities, wantProbabilities)
+			break
+		}
+	}
+
+	// the calibrated confidence never decreases.
+	c := engineCalibration{Method: calibrationIsotonic, Confidences: confidences, Probabilities: probabilities}
+	prev := -1.0
+	for i := 0; i <= 100; i++ {
+		p := c.Apply(float64(i) / 100)
+		if p < prev {
+			t.Fatalf("Apply(%f) = %f, which is lower than %f", float64(i)/100, p, prev)
+		}
+		prev = p
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	xs := []float64{0.2, 0.4, 0.8}
+	ys := []float64{0.1, 0.5, 0.9}
+	tests := []struct {
+		x    float64
+		want float64
+	}{
+		{x: 0, want: 0.1},
+		{x: 0.2, want: 0.1},
+		{x: 0.3, want: 0.3},
+		{x: 0.4, want: 0.5},
+		{x: 0.6, want: 0.7},
+		{x: 0.8, want: 0.9},
+		{x: 1, want: 0.9},
+	}
+	for _, tt := range tests {
+		if got := interpolate(xs, ys, tt.x); !floatEqual(got, tt.want) {
+			t.Errorf("interpolate(%v) = %v, want %v", tt.x, got, tt.want)
+		}
+	}
+	if got := interpolate(nil, nil, 0.3); got != 0.3 {
+		t.Errorf("interpolate without points = %v, want 0.3", got)
+	}
+}
+
+func TestExpectedCalibrationError(t *testing.T) {
+	scored := []scoredFace{
+		{Confidence: 0.95, TP: true},
+		{Confidence: 0.95, TP: false},
+		{Confidence: 0.15, TP: false},
+		{Confidence: 0.15, TP: false},
+	}
+	tests := []struct {
+		name          string
+		probabilities []float64
+		want          float64
+	}{
+		// the bin of 0.9 has the gap |1.9 - 1| and the bin of 0.1 has |0.3 - 0|.
+		{name: "raw", probabilities: []float64{0.95, 0.95, 0.15, 0.15}, want: (0.9 + 0.3) / 4},
+		{name: "calibrated", probabilities: []float64{0.5, 0.5, 0, 0}, want: 0},
+		// the probabilities out of 0-1 are clipped.
+		{name: "clipped", probabilities: []float64{1.5, 1.5, -1, -1}, want: 1.0 / 4},
+	}
+	for _, tt := range tests {
+		if got := expectedCalibrationError(tt.probabilities, scored); !floatEqual(got, tt.want) {
+			t.Errorf("%s: expectedCalibrationError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadDetectResultOverScale(t *testing.T) {
+	dir, cleanup := newTestTempDir(t)
+	defer cleanup()
+	file := filepath.Join(dir, "output.tsv")
+	lines := []string{
+		"path\told:detail\tnew:detail",
+		`a.jpg	{"faces":[{"confidence":0.5}]}	{"faces":[{"confidence":0.5}]}`,
+		`b.jpg	{"faces":[{"confidence":98.5}]}	{"faces":[{"confidence":1}]}`,
+	}
+	if err := ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := readDetectResult(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.getOverScaleEngines(); !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("getOverScaleEngines = %v, want [old]", got)
+	}
+}
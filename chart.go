@@ -0,0 +1,94 @@
+package fda
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+)
+
+// size of precision-recall chart in pixels.
+const (
+	chartWidth        = 900
+	chartHeight       = 600
+	chartFontSize     = 14
+	chartMarginLeft   = 70
+	chartMarginRight  = 300
+	chartMarginTop    = 50
+	chartMarginBottom = 60
+)
+
+var colorChartGrid = color.RGBA{220, 220, 220, 255}
+
+// savePRChart draws the precision-recall curve of each engine into the image file.
+// The point of the best F1 is marked by a square.
+func savePRChart(path string, evals []engineEval) error {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.ZP, draw.Src)
+
+	plot := image.Rect(chartMarginLeft, chartMarginTop, chartWidth-chartMarginRight, chartHeight-chartMarginBottom)
+	toPoint := func(recall, precision float64) image.Point {
+		return image.Pt(
+			plot.Min.X+int(recall*float64(plot.Dx())+0.5),
+			plot.Max.Y-int(precision*float64(plot.Dy())+0.5),
+		)
+	}
+
+	face := truetype.NewFace(defaultFont, &truetype.Options{Size: chartFontSize})
+	ascent := face.Metrics().Ascent.Ceil()
+	drawCenteredString(img, plot.Min.X+plot.Dx()/2, chartMarginTop/2, color.Black, face, "Precision-Recall curve")
+
+	// grid and tick labels
+	for i := 0; i <= 5; i++ {
+		v := float64(i) / 5
+		label := fmt.Sprintf("%.1f", v)
+		labelWidth := font.MeasureString(face, label).Ceil()
+
+		x := toPoint(v, 0).X
+		drawLine(img, image.Pt(x, plot.Min.Y), image.Pt(x, plot.Max.Y), colorChartGrid, 1)
+		drawString(img, image.Pt(x-labelWidth/2, plot.Max.Y+ascent+6), color.Black, face, label)
+
+		y := toPoint(0, v).Y
+		drawLine(img, image.Pt(plot.Min.X, y), image.Pt(plot.Max.X, y), colorChartGrid, 1)
+		drawString(img, image.Pt(plot.Min.X-labelWidth-8, y+ascent/2), color.Black, face, label)
+	}
+	drawRectBounds(img, plot, color.Black, 1)
+	drawCenteredString(img, plot.Min.X+plot.Dx()/2, chartHeight-chartMarginBottom/3, color.Black, face, "Recall")
+	drawString(img, image.Pt(4, plot.Min.Y-8), color.Black, face, "Precision")
+
+	for i, ev := range evals {
+		c, err := parseHexColor(reportColor(i))
+		if err != nil {
+			return err
+		}
+
+		if len(ev.Curve) != 0 {
+			prev := toPoint(0, ev.Curve[0].Precision)
+			for _, p := range ev.Curve {
+				pt := toPoint(p.Recall, p.Precision)
+				drawLine(img, prev, pt, c, 2)
+				prev = pt
+			}
+			best := toPoint(ev.Best.Recall, ev.Best.Precision)
+			drawRectBounds(img, image.Rect(best.X-4, best.Y-4, best.X+4, best.Y+4), c, 2)
+		}
+
+		// legend
+		y := plot.Min.Y + i*(ascent+12) + ascent/2
+		x := plot.Max.X + 16
+		drawLine(img, image.Pt(x, y), image.Pt(x+24, y), c, 3)
+		legend := fmt.Sprintf("%s AP:%.3f F1:%.3f@%s", ev.Engine, ev.AP, ev.Best.F1, formatStatsFloat(ev.Best.Threshold))
+		drawString(img, image.Pt(x+32, y+ascent/2), color.Black, face, legend)
+	}
+	return saveImage(path, img)
+}
+
+// drawCenteredString draws the string whose center is the point.
+func drawCenteredString(img *image.RGBA, x, y int, c color.Color, f font.Face, s string) {
+	width := font.MeasureString(f, s).Ceil()
+	ascent := f.Metrics().Ascent.Ceil()
+	drawString(img, image.Pt(x-width/2, y+ascent/2), c, f, s)
+}
@@ -0,0 +1,143 @@
+// Package client is the Go client of gRPC API served by 'grpc' command.
+package client
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+	"github.com/evalphobia/face-detect-annotator/pb"
+)
+
+// Client calls the Detector service.
+type Client struct {
+	conn *grpc.ClientConn
+	api  pb.DetectorClient
+}
+
+// New connects to the server. It uses insecure connection when no option is given.
+func New(addr string, opts ...grpc.DialOption) (*Client, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithInsecure()}
+	}
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		conn: conn,
+		api:  pb.NewDetectorClient(conn),
+	}, nil
+}
+
+// Response is the detection results of an image.
+type Response struct {
+	ID string
+	// results in the order of the engines.
+	Results []engine.FaceResult
+	// error messages of the failed engines by the engine name.
+	EngineErrors map[string]string
+	// error of the request in the stream.
+	Err error
+}
+
+func newResponse(resp *pb.DetectResponse) *Response {
+	r := &Response{
+		ID:           resp.GetId(),
+		Results:      make([]engine.FaceResult, len(resp.GetResults())),
+		EngineErrors: resp.GetEngineErrors(),
+	}
+	for i, res := range resp.GetResults() {
+		r.Results[i] = res.ToEngine()
+	}
+	if msg := resp.GetError(); msg != "" {
+		r.Err = errors.New(msg)
+	}
+	return r
+}
+
+// DetectImage detects faces from the image data by the engines. (empty means all engines)
+func (c *Client) DetectImage(ctx context.Context, image []byte, engines ...string) (*Response, error) {
+	return c.Detect(ctx, NewImageRequest("", image, engines...))
+}
+
+// DetectURL detects faces from the image of http(s) URL by the engines. (empty means all engines)
+func (c *Client) DetectURL(ctx context.Context, url string, engines ...string) (*Response, error) {
+	return c.Detect(ctx, NewURLRequest("", url, engines...))
+}
+
+// Detect sends the request.
+func (c *Client) Detect(ctx context.Context, req *pb.DetectRequest) (*Response, error) {
+	resp, err := c.api.Detect(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return newResponse(resp), nil
+}
+
+// Engines returns the names of initialized engines on the server.
+func (c *Client) Engines(ctx context.Context) ([]string, error) {
+	resp, err := c.api.ListEngines(ctx, &pb.ListEnginesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetEngines(), nil
+}
+
+// Stream opens the stream to send many images in a connection.
+func (c *Client) Stream(ctx context.Context) (*Stream, error) {
+	s, err := c.api.DetectStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{s: s}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Stream sends the requests and receives the responses.
+// Send and Recv can be called from different goroutines, and the responses may be received in a different order.
+type Stream struct {
+	s pb.Detector_DetectStreamClient
+}
+
+// Send sends the request. Use NewImageRequest or NewURLRequest to create it.
+func (s *Stream) Send(req *pb.DetectRequest) error {
+	return s.s.Send(req)
+}
+
+// CloseSend notifies the server that no more requests are sent.
+func (s *Stream) CloseSend() error {
+	return s.s.CloseSend()
+}
+
+// Recv receives the response. It returns io.EOF after all responses are received.
+func (s *Stream) Recv() (*Response, error) {
+	resp, err := s.s.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return newResponse(resp), nil
+}
+
+// NewImageRequest creates the request of image data.
+func NewImageRequest(id string, image []byte, engines ...string) *pb.DetectRequest {
+	return &pb.DetectRequest{
+		Id:      id,
+		Source:  &pb.DetectRequest_Image{Image: image},
+		Engines: engines,
+	}
+}
+
+// NewURLRequest creates the request of http(s) URL.
+func NewURLRequest(id, url string, engines ...string) *pb.DetectRequest {
+	return &pb.DetectRequest{
+		Id:      id,
+		Source:  &pb.DetectRequest_Url{Url: url},
+		Engines: engines,
+	}
+}
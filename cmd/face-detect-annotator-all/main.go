@@ -10,6 +10,7 @@ import (
 	"github.com/evalphobia/face-detect-annotator/engine/pigo"
 	"github.com/evalphobia/face-detect-annotator/engine/rekognition"
 	"github.com/evalphobia/face-detect-annotator/engine/tensorflow"
+	"github.com/evalphobia/face-detect-annotator/sink/sqlite"
 )
 
 func main() {
@@ -22,5 +23,6 @@ func main() {
 		&dlib.DlibFaceDetector{},
 		&opencv.OpenCVFaceDetector{},
 		&tensorflow.TensorFlowFaceDetector{})
+	fda.AddOutputFormat(sqlite.OutputFormat, sqlite.NewSink)
 	fda.Run()
 }
@@ -13,6 +13,18 @@ func Run() {
 		cli.Tree(list),
 		cli.Tree(detector),
 		cli.Tree(annotator),
+		cli.Tree(reporter),
+		cli.Tree(cropper),
+		cli.Tree(redactor),
+		cli.Tree(watcher),
+		cli.Tree(server),
+		cli.Tree(grpcServer),
+		cli.Tree(benchmarker),
+		cli.Tree(differ),
+		cli.Tree(statistician),
+		cli.Tree(agreementReporter),
+		cli.Tree(evaluator),
+		cli.Tree(calibrator),
 	).Run(os.Args[1:]); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -0,0 +1,151 @@
+package fda
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mkideal/cli"
+)
+
+// agreement command
+type agreementT struct {
+	cli.Helper
+	Input     string  `cli:"*i,input" usage:"detector's output tsv file --input='/path/to/output.tsv'"`
+	Output    string  `cli:"o,output" usage:"output file path (empty means stdout) --output='./agreement.md'"`
+	Format    string  `cli:"f,format" usage:"output format [text,json,markdown] --format='text'" dft:"text"`
+	Engines   string  `cli:"e,engine" usage:"comma separate engines to compare (empty means all engines) --engine='pigo,google'"`
+	IoU       float64 `cli:"iou" usage:"IoU threshold to match the same face --iou=0.5" dft:"0.5"`
+	Consensus string  `cli:"consensus" usage:"output tsv file path of consensus labels (empty means no file) --consensus='./consensus.tsv'"`
+	MinVotes  int     `cli:"k,min-votes" usage:"min number of engines which found the face for consensus labels --min-votes=2" dft:"2"`
+	Name      string  `cli:"name" usage:"engine name of consensus labels --name='consensus'" dft:"consensus"`
+}
+
+var agreementReporter = &cli.Command{
+	Name: "agreement",
+	Desc: "Compute agreement between engines and consensus labels from --input TSV file",
+	Argv: func() interface{} { return new(agreementT) },
+	Fn:   execAgreement,
+}
+
+func execAgreement(ctx *cli.Context) error {
+	argv := ctx.Argv().(*agreementT)
+
+	var render func(io.Writer, engineAgreement) error
+	switch argv.Format {
+	case tableFormatText:
+		render = renderAgreementText
+	case tableFormatJSON:
+		render = renderAgreementJSON
+	case tableFormatMarkdown:
+		render = renderAgreementMarkdown
+	default:
+		return fmt.Errorf("unknown format: [%s]", argv.Format)
+	}
+
+	result, err := readDetectResult(argv.Input)
+	if err != nil {
+		return err
+	}
+	engines := result.Engines
+	if argv.Engines != "" {
+		engines = nil
+		for _, e := range strings.Split(argv.Engines, ",") {
+			e = strings.TrimSpace(e)
+			if !hasEngine(result.Engines, e) {
+				return fmt.Errorf("engine '%s' is not found in %+v", e, result.Engines)
+			}
+			engines = append(engines, e)
+		}
+	}
+	if len(engines) < 2 {
+		return fmt.Errorf("two or more engines are required: %+v", engines)
+	}
+
+	if argv.Consensus != "" {
+		if hasEngine(engines, argv.Name) {
+			return fmt.Errorf("--name '%s' is used by the engine", argv.Name)
+		}
+		if argv.MinVotes < 1 || argv.MinVotes > len(engines) {
+			return fmt.Errorf("--min-votes must be between 1 and %d: [%d]", len(engines), argv.MinVotes)
+		}
+		faces, err := writeConsensusTSV(argv.Consensus, result, engines, argv.Name, argv.IoU, argv.MinVotes)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[INFO] consensus faces: %d\n", faces)
+	}
+
+	ag := newEngineAgreement(result.Rows, engines, argv.IoU)
+	return writeOutput(argv.Output, func(w io.Writer) error {
+		return render(w, ag)
+	})
+}
+
+func renderAgreementJSON(w io.Writer, ag engineAgreement) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ag)
+}
+
+func renderAgreementText(w io.Writer, ag engineAgreement) error {
+	writeTablesText(w, newAgreementTables(ag))
+	return nil
+}
+
+func renderAgreementMarkdown(w io.Writer, ag engineAgreement) error {
+	fmt.Fprintf(w, "# Engine agreement\n\nIoU threshold: %s\n", strconv.FormatFloat(ag.IoU, 'f', -1, 64))
+	writeTablesMarkdown(w, newAgreementTables(ag))
+	return nil
+}
+
+func newAgreementTables(ag engineAgreement) []statsTable {
+	boxes := statsTable{
+		Title:  "Box agreement (engine against reference)",
+		Header: []string{"reference", "engine", "images", "reference_faces", "faces", "matched", "precision", "recall", "f1", "mean_iou"},
+	}
+	for _, b := range ag.Boxes {
+		boxes.Rows = append(boxes.Rows, []string{
+			b.Reference,
+			b.Engine,
+			strconv.Itoa(b.Images),
+			strconv.Itoa(b.ReferenceFaces),
+			strconv.Itoa(b.Faces),
+			strconv.Itoa(b.Matched),
+			formatStatsFloat(b.Precision),
+			formatStatsFloat(b.Recall),
+			formatStatsFloat(b.F1),
+			formatStatsFloat(b.MeanIoU),
+		})
+	}
+
+	header := append([]string{""}, ag.Engines...)
+	f1 := statsTable{
+		Title:  "F1 matrix (row engine against column engine as reference)",
+		Header: header,
+	}
+	counts := statsTable{
+		Title:  "Face count agreement matrix",
+		Header: header,
+	}
+	for _, e := range ag.Engines {
+		f1Row := []string{e}
+		countRow := []string{e}
+		for _, ref := range ag.Engines {
+			if e == ref {
+				f1Row = append(f1Row, "-")
+				countRow = append(countRow, "-")
+				continue
+			}
+			b, _ := ag.Box(ref, e)
+			f1Row = append(f1Row, formatStatsFloat(b.F1))
+			c, _ := ag.Count(e, ref)
+			countRow = append(countRow, formatPercent(c.Matched, c.Images))
+		}
+		f1.Rows = append(f1.Rows, f1Row)
+		counts.Rows = append(counts.Rows, countRow)
+	}
+	return []statsTable{boxes, f1, counts}
+}
@@ -8,49 +8,38 @@ import (
 	"image/draw"
 	"image/jpeg"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 
-	"github.com/golang/freetype/truetype"
 	"github.com/mkideal/cli"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/gobold"
 	"golang.org/x/image/math/fixed"
 
 	"github.com/evalphobia/face-detect-annotator/engine"
 )
 
 var (
-	colorRed  = color.RGBA{255, 0, 0, 255}
-	colorBlue = color.RGBA{0, 0, 255, 255}
+	colorRed   = color.RGBA{255, 0, 0, 255}
+	colorGreen = color.RGBA{0, 255, 0, 255}
+	colorBlue  = color.RGBA{0, 0, 255, 255}
+	colorCyan  = color.RGBA{0, 255, 255, 255}
 )
 
-var (
-	fontFace18 font.Face
-	fontFace36 font.Face
-)
-
-func init() {
-	f, err := truetype.Parse(gobold.TTF)
-	if err != nil {
-		panic("[ERROR] cannot load font gobold.TTF")
-	}
-
-	fontFace18 = truetype.NewFace(f, &truetype.Options{
-		Size: 18,
-	})
-
-	fontFace36 = truetype.NewFace(f, &truetype.Options{
-		Size: 36,
-	})
-}
-
 // annotator command
 type annotatorT struct {
 	cli.Helper
-	Input string `cli:"*i,input" usage:"detector's output tsv file --input='/path/to/output.tsv'"`
+	Input           string  `cli:"*i,input" usage:"detector's output tsv file --input='/path/to/output.tsv'"`
+	LineWidth       int     `cli:"line-width" usage:"line width in pixels (0 means relative to image size) --line-width=2" dft:"0"`
+	FontSize        float64 `cli:"font-size" usage:"font size of label (0 means relative to image size) --font-size=18" dft:"0"`
+	Scale           float64 `cli:"scale" usage:"multiplier of the relative line width and font size --scale=1.5" dft:"1"`
+	Font            string  `cli:"font" usage:"TTF font file path --font='/path/to/font.ttf'"`
+	Label           string  `cli:"label" usage:"text/template of label [.Engine,.Index,.Confidence,.RawConfidence,.PercentWidth,.PercentHeight,.X,.Y,.Width,.Height] --label='#{{.Index}} {{.Engine}}'"`
+	BoxColor        string  `cli:"box-color" usage:"color of face box in hex --box-color='ff0000'" dft:"ff0000"`
+	TextColor       string  `cli:"text-color" usage:"color of label in hex --text-color='ff0000'" dft:"ff0000"`
+	FillColor       string  `cli:"fill-color" usage:"translucent fill color of face box in hex with alpha --fill-color='ff000040'"`
+	LabelBackground string  `cli:"label-bg" usage:"background color of label in hex --label-bg='ffffffc0'"`
 }
 
 var annotator = &cli.Command{
@@ -73,22 +62,21 @@ func execAnnotator(ctx *cli.Context) error {
 		return err
 	}
 
-	const colSuffix = ":detail"
-	var engines []string
-	for _, h := range f.header {
-		if strings.HasSuffix(h, colSuffix) {
-			engines = append(engines, strings.TrimSuffix(h, colSuffix))
-		}
+	style, err := newAnnotateStyleFromArgv(argv)
+	if err != nil {
+		return err
 	}
+
+	engines := getEnginesFromHeader(f.header)
 	fmt.Printf("engines:%+v\n", engines)
 
 	for _, line := range lines {
 		rawJsonData := make([]string, len(engines))
 		for i, e := range engines {
-			rawJsonData[i] = line[e+colSuffix]
+			rawJsonData[i] = line[e+colSuffixDetail]
 		}
-		imgPath := line["path"]
-		err := annotateImage(imgPath, rawJsonData...)
+		imgPath := line[colPath]
+		err := annotateImage(imgPath, style, rawJsonData...)
 		if err != nil {
 			fmt.Printf("[ERROR] path:%s\terr:%s\n", imgPath, err.Error())
 		}
@@ -96,8 +84,49 @@ func execAnnotator(ctx *cli.Context) error {
 	return nil
 }
 
-func annotateImage(path string, targets ...string) error {
-	file, err := os.Open(path)
+func newAnnotateStyleFromArgv(argv *annotatorT) (*annotateStyle, error) {
+	style := newAnnotateStyle()
+	style.LineWidth = argv.LineWidth
+	style.FontSize = argv.FontSize
+	if argv.Scale > 0 {
+		style.Scale = argv.Scale
+	}
+
+	if argv.Font != "" {
+		if err := style.setFontFile(argv.Font); err != nil {
+			return nil, err
+		}
+	}
+	if argv.Label != "" {
+		if err := style.setLabelTemplate(argv.Label); err != nil {
+			return nil, err
+		}
+	}
+
+	colors := []struct {
+		hex string
+		dst *color.Color
+	}{
+		{argv.BoxColor, &style.BoxColor},
+		{argv.TextColor, &style.TextColor},
+		{argv.FillColor, &style.FillColor},
+		{argv.LabelBackground, &style.LabelBackground},
+	}
+	for _, c := range colors {
+		if c.hex == "" {
+			continue
+		}
+		v, err := parseHexColor(c.hex)
+		if err != nil {
+			return nil, err
+		}
+		*c.dst = v
+	}
+	return style, nil
+}
+
+func annotateImage(path string, style *annotateStyle, targets ...string) error {
+	file, err := openImageFile(path)
 	if err != nil {
 		return err
 	}
@@ -108,7 +137,33 @@ func annotateImage(path string, targets ...string) error {
 		return err
 	}
 
+	canvas, err := renderAnnotation(path, srcImg, style, targets...)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(getAnnotatedPath(path))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	err = jpeg.Encode(out, canvas, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return nil
+}
+
+// renderAnnotation draws the faces of each result on the image, and stacks them vertically.
+func renderAnnotation(path string, srcImg image.Image, style *annotateStyle, targets ...string) (*image.RGBA, error) {
 	bounds := srcImg.Bounds()
+	lineWidth := style.getLineWidth(bounds)
+	fontSize := style.getFontSize(bounds)
+	labelFace := style.getFontFace(fontSize)
+	titleFace := style.getFontFace(fontSize * 2)
+
 	images := make([]image.Image, len(targets))
 	for i, rawJsonBody := range targets {
 		img := image.NewRGBA(bounds)
@@ -121,27 +176,32 @@ func annotateImage(path string, targets ...string) error {
 			fmt.Printf("[ERROR] JSON path:%s\t\terr:%s\n", path, err.Error())
 			continue
 		}
-		drawString(img, image.Pt(10, 40), colorBlue, fontFace36, data.EngineName)
+		drawString(img, image.Pt(lineWidth*5, int(fontSize*2.4)), style.TitleColor, titleFace, data.EngineName)
 
 		if !data.HasFaces() {
 			continue
 		}
-		for _, f := range data.Faces {
-			drawRectBounds(img, image.Rect(f.X, f.Y, f.MaxX(), f.MaxY()), colorRed)
-			var strList []string
-			if f.Confidence > 0 {
-				score := strconv.FormatFloat(f.Confidence, 'f', 2, 64)
-				strList = append(strList, fmt.Sprintf("[%s%%]", score))
+		for j, f := range data.Faces {
+			rect := image.Rect(f.X, f.Y, f.MaxX(), f.MaxY())
+			if style.FillColor != nil {
+				draw.Draw(img, rect, image.NewUniform(style.FillColor), image.ZP, draw.Over)
+			}
+			drawRectBounds(img, rect, style.BoxColor, lineWidth)
+			if f.HasLandmarks() {
+				drawLandmarks(img, f.Landmarks, colorCyan, lineWidth)
 			}
-			if f.PercentWidth > 0 || f.PercentHeight > 0 {
-				pw := strconv.FormatFloat(f.PercentWidth, 'f', 2, 64)
-				ph := strconv.FormatFloat(f.PercentHeight, 'f', 2, 64)
-				strList = append(strList, fmt.Sprintf("[W:%s,H:%s]", pw, ph))
+			if f.HasPose() {
+				drawPoseAxis(img, f, lineWidth)
 			}
-			if len(strList) != 0 {
-				drawString(img, image.Pt(f.X, f.Y-8), colorRed, fontFace18, strings.Join(strList, " "))
 
+			label, err := style.getLabel(data.EngineName, j, f)
+			if err != nil {
+				return nil, err
+			}
+			if label == "" {
+				continue
 			}
+			drawLabel(img, rect, label, style, labelFace, lineWidth)
 		}
 	}
 
@@ -153,23 +213,15 @@ func annotateImage(path string, targets ...string) error {
 		bounds.Max.Y = height * (i + 1)
 		draw.Draw(canvas, bounds, img, image.Pt(0, 0), draw.Src)
 	}
-
-	out, err := os.Create(getAnnotatedPath(path))
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	err = jpeg.Encode(out, canvas, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return nil
+	return canvas, nil
 }
 
 func getAnnotatedPath(p string) string {
 	const prefix = "_annotated_"
+	// save the entry of archive file next to the archive file.
+	if archivePath, name, ok := splitArchivePath(p); ok {
+		p = archivePath + "_" + strings.Replace(name, "/", "_", -1)
+	}
 	dir, file := filepath.Split(p)
 	return filepath.Join(dir, prefix+file)
 }
@@ -185,23 +237,125 @@ func drawString(img *image.RGBA, p image.Point, c color.Color, f font.Face, s st
 	d.DrawString(s)
 }
 
-func drawRectBounds(img *image.RGBA, r image.Rectangle, c color.Color) {
+// drawLabel draws the label above the face box, or inside of the box when there is no space above.
+func drawLabel(img *image.RGBA, rect image.Rectangle, label string, style *annotateStyle, face font.Face, lineWidth int) {
+	metrics := face.Metrics()
+	ascent := metrics.Ascent.Ceil()
+	descent := metrics.Descent.Ceil()
+	width := font.MeasureString(face, label).Ceil()
+
+	pad := lineWidth
+	baseline := rect.Min.Y - lineWidth - pad - descent
+	if baseline-ascent-pad < img.Bounds().Min.Y {
+		baseline = rect.Min.Y + lineWidth + pad + ascent
+	}
+	x := rect.Min.X
+
+	if style.LabelBackground != nil {
+		bg := image.Rect(x-pad, baseline-ascent-pad, x+width+pad, baseline+descent+pad)
+		draw.Draw(img, bg, image.NewUniform(style.LabelBackground), image.ZP, draw.Over)
+	}
+	drawString(img, image.Pt(x, baseline), style.TextColor, face, label)
+}
+
+// drawRectBounds draws the lines of the rectangle with the width, along the bounds.
+func drawRectBounds(img *image.RGBA, r image.Rectangle, c color.Color, width int) {
 	minX, maxX := r.Min.X, r.Max.X
 	minY, maxY := r.Min.Y, r.Max.Y
 
-	// write lines of top and bottom
-	for x := minX; x <= maxX; x++ {
-		img.Set(x, minY-1, c)
-		img.Set(x, minY, c)
-		img.Set(x, maxY, c)
-		img.Set(x, maxY+1, c)
+	for t := 0; t < width; t++ {
+		d := t - width/2
+
+		// write lines of top and bottom
+		for x := minX - width/2; x <= maxX+width/2; x++ {
+			img.Set(x, minY+d, c)
+			img.Set(x, maxY-d, c)
+		}
+
+		// write lines of left and right
+		for y := minY; y <= maxY; y++ {
+			img.Set(minX+d, y, c)
+			img.Set(maxX-d, y, c)
+		}
+	}
+}
+
+func drawLandmarks(img *image.RGBA, landmarks []engine.Landmark, c color.Color, lineWidth int) {
+	r := lineWidth + 1
+	for _, l := range landmarks {
+		x, y := int(l.X), int(l.Y)
+		draw.Draw(img, image.Rect(x-r, y-r, x+r+1, y+r+1), image.NewUniform(c), image.ZP, draw.Src)
+	}
+}
+
+// drawPoseAxis draws the axes of head pose from the center of the face.
+// X axis is red, Y axis is green and Z axis (the direction of the face) is blue.
+func drawPoseAxis(img *image.RGBA, f engine.FaceData, lineWidth int) {
+	const toRadian = math.Pi / 180
+	pitch := f.Pose.Pitch * toRadian
+	yaw := -f.Pose.Yaw * toRadian
+	roll := f.Pose.Roll * toRadian
+
+	size := float64(f.Width) / 2
+	cx := float64(f.X) + float64(f.Width)/2
+	cy := float64(f.Y) + float64(f.Height)/2
+	center := image.Pt(int(cx), int(cy))
+
+	xAxis := image.Pt(
+		int(cx+size*(math.Cos(yaw)*math.Cos(roll))),
+		int(cy+size*(math.Cos(pitch)*math.Sin(roll)+math.Cos(roll)*math.Sin(pitch)*math.Sin(yaw))),
+	)
+	yAxis := image.Pt(
+		int(cx+size*(-math.Cos(yaw)*math.Sin(roll))),
+		int(cy+size*(math.Cos(pitch)*math.Cos(roll)-math.Sin(pitch)*math.Sin(yaw)*math.Sin(roll))),
+	)
+	zAxis := image.Pt(
+		int(cx+size*(math.Sin(yaw))),
+		int(cy+size*(-math.Cos(yaw)*math.Sin(pitch))),
+	)
+
+	drawLine(img, center, xAxis, colorRed, lineWidth)
+	drawLine(img, center, yAxis, colorGreen, lineWidth)
+	drawLine(img, center, zAxis, colorBlue, lineWidth)
+}
+
+// drawLine draws line with the width by Bresenham's algorithm.
+func drawLine(img *image.RGBA, p1, p2 image.Point, c color.Color, width int) {
+	dx := p2.X - p1.X
+	if dx < 0 {
+		dx = -dx
+	}
+	dy := -(p2.Y - p1.Y)
+	if dy > 0 {
+		dy = -dy
+	}
+	sx, sy := 1, 1
+	if p1.X > p2.X {
+		sx = -1
+	}
+	if p1.Y > p2.Y {
+		sy = -1
 	}
 
-	// write lines of left and right
-	for y := minY; y <= maxY; y++ {
-		img.Set(minX-1, y, c)
-		img.Set(minX, y, c)
-		img.Set(maxX, y, c)
-		img.Set(maxX+1, y, c)
+	x, y := p1.X, p1.Y
+	e := dx + dy
+	for {
+		for t := 0; t < width; t++ {
+			d := t - width/2
+			img.Set(x+d, y, c)
+			img.Set(x, y+d, c)
+		}
+		if x == p2.X && y == p2.Y {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y += sy
+		}
 	}
 }
@@ -0,0 +1,380 @@
+package fda
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkideal/cli"
+	"github.com/pkg/errors"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// bench command
+type benchT struct {
+	cli.Helper
+	Input        string `cli:"*i,input" usage:"image file, image dir or csv list path --input='/path/to/image_dir'"`
+	Output       string `cli:"o,output" usage:"output CSV file path (empty means no file) --output='./bench.csv'"`
+	UseAllEngine bool   `cli:"a,all" usage:"use all engines"`
+	Engines      string `cli:"e,engine" usage:"comma separate Face Detect Engines --engine='opencv,dlib,pigo,tensorflow,rekognition,google,azure,face++'" dft:"opencv,dlib,pigo,tensorflow"`
+	Type         string `cli:"t,type" usage:"comma separate file extensions of image dir --type='jpg,jpeg,png,gif'" dft:"jpg,jpeg,png,gif"`
+	Limit        int    `cli:"limit" usage:"max number of images to use (0 means all) --limit=100" dft:"0"`
+	Concurrency  string `cli:"c,concurrency" usage:"comma separate numbers of concurrent calls --concurrency='1,4,8'" dft:"1"`
+	Resolution   string `cli:"r,resolution" usage:"comma separate longer sides of downscaled images in pixels (0 means original) --resolution='0,1024,512'" dft:"0"`
+	Repeat       int    `cli:"n,repeat" usage:"number of passes over the images in each setting --repeat=3" dft:"3"`
+	Warmup       int    `cli:"warmup" usage:"number of calls excluded from the measurement in each setting --warmup=3" dft:"1"`
+}
+
+var benchmarker = &cli.Command{
+	Name: "bench",
+	Desc: "Benchmark the speed of engines by concurrency and image resolution",
+	Argv: func() interface{} { return new(benchT) },
+	Fn:   execBench,
+}
+
+func execBench(ctx *cli.Context) error {
+	argv := ctx.Argv().(*benchT)
+	conf := NewConfig(argv.UseAllEngine)
+	conf.setInputPath(argv.Input)
+	for _, e := range strings.Split(argv.Engines, ",") {
+		if err := conf.setUseEngineFromName(e); err != nil {
+			return errors.Wrap(err, "[ERROR] setUseEngineFromName")
+		}
+	}
+
+	levels, err := parseIntList(argv.Concurrency, 1)
+	if err != nil {
+		return errors.Wrap(err, "[ERROR] --concurrency")
+	}
+	resolutions, err := parseIntList(argv.Resolution, 0)
+	if err != nil {
+		return errors.Wrap(err, "[ERROR] --resolution")
+	}
+	if argv.Repeat < 1 {
+		return fmt.Errorf("[ERROR] --repeat must be 1 or more: [%d]", argv.Repeat)
+	}
+
+	paths, err := getBenchPaths(conf, strings.Split(argv.Type, ","))
+	if err != nil {
+		return err
+	}
+	if argv.Limit > 0 && len(paths) > argv.Limit {
+		paths = paths[:argv.Limit]
+	}
+	images := loadBenchImages(paths)
+	if len(images) == 0 {
+		return fmt.Errorf("[ERROR] no image is found: [%s]", argv.Input)
+	}
+	fmt.Printf("[INFO] images: %d\n", len(images))
+
+	engines, err := initEngines(conf, enabledEngines)
+	if err != nil {
+		return errors.Wrap(err, "[ERROR] initEngines")
+	}
+
+	var w *CSVWriter
+	if argv.Output != "" {
+		if w, err = NewCSVWriter(argv.Output); err != nil {
+			return err
+		}
+		defer w.Close()
+		if err := w.Write(benchHeader); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(strings.Join(benchHeader, "\t"))
+	for _, size := range resolutions {
+		variants := resizeBenchImages(images, size)
+		for _, e := range engines {
+			for _, n := range levels {
+				r := runBench(e, variants, n, argv.Repeat, argv.Warmup)
+				r.Resolution = size
+				fmt.Println(strings.Join(r.Row(), "\t"))
+				if w == nil {
+					continue
+				}
+				if err := w.Write(r.Row()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseIntList parses comma separated numbers, which must be min or more.
+func parseIntList(s string, min int) ([]int, error) {
+	var list []int
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		if n < min {
+			return nil, fmt.Errorf("must be %d or more: [%d]", min, n)
+		}
+		list = append(list, n)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("empty value: [%s]", s)
+	}
+	return list, nil
+}
+
+// getBenchPaths returns the image paths from the csv list, the image dir or the image file.
+func getBenchPaths(conf Config, types []string) ([]string, error) {
+	if conf.isCSVFilePath() {
+		f, err := NewCSVHandler(conf.InputPath)
+		if err != nil {
+			return nil, err
+		}
+		lines, err := f.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		paths := make([]string, 0, len(lines))
+		for _, line := range lines {
+			paths = append(paths, line[colPath])
+		}
+		return paths, nil
+	}
+
+	info, err := os.Stat(conf.InputPath)
+	if err != nil || !info.IsDir() {
+		return []string{conf.InputPath}, nil
+	}
+
+	var mu sync.Mutex
+	var paths []string
+	err = WalkFiles(conf.InputPath, WalkOption{Types: types}, func(path string) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, path)
+	})
+	// keep the same image set for --limit.
+	sort.Strings(paths)
+	return paths, err
+}
+
+// benchImage is an image data used in the benchmark.
+type benchImage struct {
+	// name for the extension of the temporary file.
+	name   string
+	data   []byte
+	width  int
+	height int
+}
+
+// loadBenchImages reads the images into the memory, not to measure the time of reading files.
+func loadBenchImages(paths []string) []benchImage {
+	fetcher := newURLFetcher(defaultFetchConcurrency, defaultFetchTimeout, defaultMaxDownloadSize, "")
+	images := make([]benchImage, 0, len(paths))
+	for _, path := range paths {
+		img, err := readBenchImage(path, fetcher)
+		if err != nil {
+			fmt.Printf("[WARN] skipped path:%s\terr:%s\n", path, err.Error())
+			continue
+		}
+		images = append(images, img)
+	}
+	return images
+}
+
+func readBenchImage(path string, fetcher *urlFetcher) (benchImage, error) {
+	var byt []byte
+	var err error
+	name := filepath.Base(path)
+	if isURLPath(path) {
+		name = "image" + getURLExt(path)
+		byt, err = fetcher.Fetch(path)
+	} else {
+		f, openErr := openImageFile(path)
+		if openErr != nil {
+			return benchImage{}, openErr
+		}
+		defer f.Close()
+		byt, err = ioutil.ReadAll(f)
+	}
+	if err != nil {
+		return benchImage{}, err
+	}
+
+	conf, _, err := image.DecodeConfig(bytes.NewReader(byt))
+	if err != nil {
+		return benchImage{}, err
+	}
+	return benchImage{
+		name:   name,
+		data:   byt,
+		width:  conf.Width,
+		height: conf.Height,
+	}, nil
+}
+
+// resizeBenchImages returns the images downscaled to the size of the longer side in JPEG.
+// The images smaller than the size are used as they are.
+func resizeBenchImages(images []benchImage, size int) []benchImage {
+	if size == 0 {
+		return images
+	}
+
+	list := make([]benchImage, 0, len(images))
+	for _, img := range images {
+		if img.width <= size && img.height <= size {
+			list = append(list, img)
+			continue
+		}
+
+		src, _, err := image.Decode(bytes.NewReader(img.data))
+		if err != nil {
+			fmt.Printf("[WARN] skipped path:%s\terr:%s\n", img.name, err.Error())
+			continue
+		}
+		dst := resizeImage(src, size)
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, dst, &jpeg.Options{Quality: 95}); err != nil {
+			fmt.Printf("[WARN] skipped path:%s\terr:%s\n", img.name, err.Error())
+			continue
+		}
+		list = append(list, benchImage{
+			name:   strings.TrimSuffix(img.name, filepath.Ext(img.name)) + ".jpg",
+			data:   buf.Bytes(),
+			width:  dst.Bounds().Dx(),
+			height: dst.Bounds().Dy(),
+		})
+	}
+	return list
+}
+
+var benchHeader = []string{
+	"engine",
+	"concurrency",
+	"resolution",
+	"images",
+	"calls",
+	"errors",
+	"mean_ms",
+	"p50_ms",
+	"p95_ms",
+	"p99_ms",
+	"images_per_sec",
+}
+
+// benchResult is the measurement of an engine in a setting.
+type benchResult struct {
+	latencySummary
+	Concurrency int
+	// longer side of the images in pixels. (0 means original)
+	Resolution int
+	Images     int
+	Errors     int
+}
+
+// Row returns the line of the output.
+func (r benchResult) Row() []string {
+	resolution := "original"
+	if r.Resolution > 0 {
+		resolution = strconv.Itoa(r.Resolution)
+	}
+	return []string{
+		r.Engine,
+		strconv.Itoa(r.Concurrency),
+		resolution,
+		strconv.Itoa(r.Images),
+		strconv.Itoa(r.Count + r.Errors),
+		strconv.Itoa(r.Errors),
+		formatMsec(r.Mean),
+		formatMsec(r.P50),
+		formatMsec(r.P95),
+		formatMsec(r.P99),
+		strconv.FormatFloat(r.Throughput, 'f', 2, 64),
+	}
+}
+
+// runBench detects the images repeatedly by the engine with the concurrency.
+// The warm-up calls are done before the measurement.
+func runBench(e engine.Engine, images []benchImage, concurrency, repeat, warmup int) benchResult {
+	for i := 0; i < warmup && len(images) != 0; i++ {
+		benchDetect(e, images[i%len(images)])
+	}
+
+	jobs := make(chan benchImage)
+	go func() {
+		defer close(jobs)
+		for i := 0; i < repeat; i++ {
+			for _, img := range images {
+				jobs <- img
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var samples []time.Duration
+	var errCount int
+	var lastErr error
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for img := range jobs {
+				d, err := benchDetect(e, img)
+				mu.Lock()
+				if err != nil {
+					errCount++
+					lastErr = err
+				} else {
+					samples = append(samples, d)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if lastErr != nil {
+		fmt.Printf("[WARN] engine:%s\terrors:%d\terr:%s\n", e.String(), errCount, lastErr.Error())
+	}
+	summary := summarizeLatencies(e.String(), samples)
+	// only this engine runs in the elapsed time, so the throughput includes the concurrency.
+	if elapsed > 0 {
+		summary.Throughput = float64(len(samples)) / elapsed.Seconds()
+	}
+	return benchResult{
+		latencySummary: summary,
+		Concurrency:    concurrency,
+		Images:         len(images),
+		Errors:         errCount,
+	}
+}
+
+// benchDetect returns the elapsed time of the detection in the same way as detect command.
+func benchDetect(e engine.Engine, img benchImage) (time.Duration, error) {
+	in := newDetectInputFromBytes(img.name, img.data)
+	defer in.Close()
+	if err := in.prepare(e); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	_, err := in.Detect(e)
+	return time.Since(start), err
+}
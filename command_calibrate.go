@@ -0,0 +1,124 @@
+package fda
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mkideal/cli"
+)
+
+// calibrate command
+type calibrateT struct {
+	cli.Helper
+	Input       string  `cli:"*i,input" usage:"detector's output tsv file --input='/path/to/output.tsv'"`
+	Truth       string  `cli:"g,gt" usage:"ground truth file of --gt-format (empty means --input) --gt='/path/to/consensus.tsv'"`
+	TruthFormat string  `cli:"gt-format" usage:"format of --gt file [tsv,wider] --gt-format='wider'" dft:"tsv"`
+	TruthEngine string  `cli:"gt-engine" usage:"engine name of ground truth in --gt file (empty means the first engine) --gt-engine='consensus'"`
+	Engines     string  `cli:"e,engine" usage:"comma separate engines to calibrate (empty means all engines except ground truth) --engine='pigo,tensorflow'"`
+	IoU         float64 `cli:"iou" usage:"IoU threshold to match the ground truth --iou=0.5" dft:"0.5"`
+	Method      string  `cli:"m,method" usage:"calibration method [platt,isotonic] --method='platt'" dft:"platt"`
+	Save        string  `cli:"save" usage:"output JSON file path of fitted calibration (empty means no file) --save='./calibration.json'"`
+	Load        string  `cli:"load" usage:"JSON file path of calibration to apply without fitting --load='./calibration.json'"`
+	Output      string  `cli:"o,output" usage:"output tsv file path of calibrated confidence (empty means no file) --output='./calibrated.tsv'"`
+}
+
+var calibrator = &cli.Command{
+	Name: "calibrate",
+	Desc: "Calibrate confidence of engines in --input TSV file against ground truth",
+	Argv: func() interface{} { return new(calibrateT) },
+	Fn:   execCalibrate,
+}
+
+func execCalibrate(ctx *cli.Context) error {
+	argv := ctx.Argv().(*calibrateT)
+	switch argv.Method {
+	case calibrationPlatt, calibrationIsotonic:
+	default:
+		return fmt.Errorf("unknown calibration method: [%s]", argv.Method)
+	}
+
+	result, err := readDetectResult(argv.Input)
+	if err != nil {
+		return err
+	}
+
+	var c calibration
+	if argv.Load != "" {
+		if c, err = readCalibration(argv.Load); err != nil {
+			return err
+		}
+		for _, ec := range c.Engines {
+			if !hasEngine(result.Engines, ec.Engine) {
+				fmt.Printf("[WARN] engine '%s' of calibration is not found in %+v\n", ec.Engine, result.Engines)
+			}
+		}
+	} else {
+		if c, err = fitCalibrations(result, argv); err != nil {
+			return err
+		}
+	}
+
+	if argv.Save != "" {
+		if err := writeCalibration(argv.Save, c); err != nil {
+			return err
+		}
+	}
+	if argv.Output != "" {
+		if err := writeCalibratedTSV(argv.Output, result, c); err != nil {
+			return err
+		}
+	}
+	writeTablesText(os.Stdout, newCalibrationTables(c))
+	return nil
+}
+
+func fitCalibrations(result *detectResult, argv *calibrateT) (calibration, error) {
+	truth, truthEngine, err := loadEvalTruth(result, argv.Truth, argv.TruthFormat, argv.TruthEngine)
+	if err != nil {
+		return calibration{}, err
+	}
+	engines, err := getEvalEngines(result, argv.Engines, truthEngine, argv.Truth == "")
+	if err != nil {
+		return calibration{}, err
+	}
+
+	c := calibration{
+		Truth: truthEngine,
+		IoU:   argv.IoU,
+	}
+	for _, e := range engines {
+		images := removeIgnoredFaces(getEvalImages(result.Rows, truth, nil, e), argv.IoU)
+		ec, err := fitCalibration(e, argv.Method, getScoredFaces(images, argv.IoU))
+		if err != nil {
+			fmt.Printf("[WARN] %s\n", err.Error())
+			continue
+		}
+		c.Engines = append(c.Engines, ec)
+	}
+	return c, nil
+}
+
+func newCalibrationTables(c calibration) []statsTable {
+	t := statsTable{
+		Title:  "Calibration against " + c.Truth,
+		Header: []string{"engine", "method", "samples", "positives", "brier_before", "brier_after", "ece_before", "ece_after"},
+	}
+	for _, ec := range c.Engines {
+		t.Rows = append(t.Rows, []string{
+			ec.Engine,
+			ec.Method,
+			strconv.Itoa(ec.Samples),
+			strconv.Itoa(ec.Positives),
+			formatCalibrationFloat(ec.BrierBefore),
+			formatCalibrationFloat(ec.BrierAfter),
+			formatCalibrationFloat(ec.ECEBefore),
+			formatCalibrationFloat(ec.ECEAfter),
+		})
+	}
+	return []statsTable{t}
+}
+
+func formatCalibrationFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
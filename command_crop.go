@@ -0,0 +1,191 @@
+package fda
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mkideal/cli"
+	xdraw "golang.org/x/image/draw"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// crop command
+type cropT struct {
+	cli.Helper
+	Input         string  `cli:"*i,input" usage:"detector's output or ground truth tsv file --input='/path/to/output.tsv'"`
+	Output        string  `cli:"*o,output" usage:"output directory path of cropped images --output='./crop'" dft:"./crop"`
+	Engine        string  `cli:"*e,engine" usage:"engine name to crop faces --engine='pigo'"`
+	Margin        float64 `cli:"m,margin" usage:"margin ratio of face size added to each side --margin=0.2" dft:"0"`
+	Square        bool    `cli:"square" usage:"expand the crop area to square"`
+	Size          int     `cli:"s,size" usage:"output size of the longer side in pixels (0 means no resize) --size=128" dft:"0"`
+	MinConfidence float64 `cli:"min-confidence" usage:"skip faces below the confidence between 0 and 1 --min-confidence=0.5" dft:"0"`
+	MinSize       int     `cli:"min-size" usage:"skip faces whose width or height is below the pixels --min-size=20" dft:"0"`
+}
+
+var cropper = &cli.Command{
+	Name: "crop",
+	Desc: "Crop faces of image from --input TSV file",
+	Argv: func() interface{} { return new(cropT) },
+	Fn:   execCrop,
+}
+
+func execCrop(ctx *cli.Context) error {
+	argv := ctx.Argv().(*cropT)
+
+	result, err := readDetectResult(argv.Input)
+	if err != nil {
+		return err
+	}
+	if !hasEngine(result.Engines, argv.Engine) {
+		return fmt.Errorf("engine '%s' is not found in %+v", argv.Engine, result.Engines)
+	}
+
+	if err := os.MkdirAll(argv.Output, 0755); err != nil {
+		return err
+	}
+
+	c := faceCropper{
+		dir:           argv.Output,
+		margin:        argv.Margin,
+		square:        argv.Square,
+		size:          argv.Size,
+		minConfidence: argv.MinConfidence,
+		minSize:       argv.MinSize,
+	}
+
+	manifest := [][]string{{"crop", "path", "engine", "index", "x", "y", "width", "height", "confidence"}}
+	for i, row := range result.Rows {
+		data, ok := row.Results[argv.Engine]
+		if !ok || !data.HasFaces() {
+			continue
+		}
+
+		records, err := c.Crop(i+1, row.Path, argv.Engine, data.Faces)
+		if err != nil {
+			fmt.Printf("[ERROR] path:%s\terr:%s\n", row.Path, err.Error())
+			continue
+		}
+		manifest = append(manifest, records...)
+	}
+
+	w, err := NewFileHandler(filepath.Join(argv.Output, "manifest.csv"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[INFO] cropped faces: %d\n", len(manifest)-1)
+	return w.WriteCSV(manifest)
+}
+
+// faceCropper crops faces from image and saves them as JPEG files.
+type faceCropper struct {
+	dir           string
+	margin        float64
+	square        bool
+	size          int
+	minConfidence float64
+	minSize       int
+}
+
+// Crop saves cropped faces and returns manifest records.
+func (c faceCropper) Crop(number int, path, engineName string, faces []engine.FaceData) ([][]string, error) {
+	src, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var records [][]string
+	for i, f := range faces {
+		if c.isSkipped(f) {
+			continue
+		}
+
+		img := c.cropImage(src, f)
+		name := fmt.Sprintf("%06d_%s_%02d.jpg", number, engineName, i)
+		if err := saveJPEG(filepath.Join(c.dir, name), img); err != nil {
+			return records, err
+		}
+
+		records = append(records, []string{
+			name,
+			path,
+			engineName,
+			strconv.Itoa(i),
+			strconv.Itoa(f.X),
+			strconv.Itoa(f.Y),
+			strconv.Itoa(f.Width),
+			strconv.Itoa(f.Height),
+			strconv.FormatFloat(f.Confidence, 'f', -1, 64),
+		})
+	}
+	return records, nil
+}
+
+func (c faceCropper) isSkipped(f engine.FaceData) bool {
+	switch {
+	case f.Width <= 0 || f.Height <= 0,
+		f.Confidence < c.minConfidence,
+		f.Width < c.minSize || f.Height < c.minSize:
+		return true
+	}
+	return false
+}
+
+// cropImage crops the face area with margin.
+// The area outside of the source image is filled with black.
+func (c faceCropper) cropImage(src image.Image, f engine.FaceData) image.Image {
+	r := expandRect(faceRect(f), c.margin)
+	if c.square {
+		r = squareRect(r)
+	}
+	r = r.Add(src.Bounds().Min)
+
+	img := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	visible := r.Intersect(src.Bounds())
+	xdraw.Draw(img, visible.Sub(r.Min), src, visible.Min, xdraw.Src)
+	if c.size <= 0 {
+		return img
+	}
+
+	return resizeImage(img, c.size)
+}
+
+func faceRect(f engine.FaceData) image.Rectangle {
+	return image.Rect(f.X, f.Y, f.MaxX(), f.MaxY())
+}
+
+// expandRect expands the rectangle by the ratio of its size on each side.
+func expandRect(r image.Rectangle, ratio float64) image.Rectangle {
+	if ratio == 0 {
+		return r
+	}
+	dx := int(float64(r.Dx()) * ratio)
+	dy := int(float64(r.Dy()) * ratio)
+	return image.Rect(r.Min.X-dx, r.Min.Y-dy, r.Max.X+dx, r.Max.Y+dy)
+}
+
+// squareRect expands the shorter side of the rectangle around its center.
+func squareRect(r image.Rectangle) image.Rectangle {
+	w, h := r.Dx(), r.Dy()
+	switch {
+	case w > h:
+		d := w - h
+		return image.Rect(r.Min.X, r.Min.Y-d/2, r.Max.X, r.Max.Y+(d-d/2))
+	case h > w:
+		d := h - w
+		return image.Rect(r.Min.X-d/2, r.Min.Y, r.Max.X+(d-d/2), r.Max.Y)
+	}
+	return r
+}
+
+func hasEngine(engines []string, name string) bool {
+	for _, e := range engines {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,10 @@
 package fda
 
 import (
+	"context"
 	"fmt"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/evalphobia/face-detect-annotator/engine"
 	"github.com/mkideal/cli"
@@ -13,10 +14,20 @@ import (
 // detector command
 type detectorT struct {
 	cli.Helper
-	Input        string `cli:"*i,input" usage:"image dir path --input='/path/to/image_dir'"`
-	Output       string `cli:"*o,output" usage:"output TSV file path --output='./output.tsv'" dft:"./output.tsv"`
-	UseAllEngine bool   `cli:"a,all" usage:"use all engines"`
-	Engines      string `cli:"e,engine" usage:"comma separate Face Detect Engines --engine='opencv,dlib,pigo,tensorflow,rekognition,google,azure,face++'" dft:"opencv,dlib,pigo,tensorflow"`
+	Input            string `cli:"*i,input" usage:"image dir path --input='/path/to/image_dir'"`
+	Output           string `cli:"*o,output" usage:"output file path --output='./output.tsv'" dft:"./output.tsv"`
+	OutputFormat     string `cli:"output-format" usage:"output format [tsv,jsonl,csv,sqlite] --output-format='tsv'" dft:"tsv"`
+	UseAllEngine     bool   `cli:"a,all" usage:"use all engines"`
+	Engines          string `cli:"e,engine" usage:"comma separate Face Detect Engines --engine='opencv,dlib,pigo,tensorflow,rekognition,google,azure,face++'" dft:"opencv,dlib,pigo,tensorflow"`
+	Dedup            bool   `cli:"dedup" usage:"skip the duplicate images by sha256 column of csv list"`
+	MinWidth         int    `cli:"min-width" usage:"skip the images smaller than the width column of csv list --min-width=100" dft:"0"`
+	MinHeight        int    `cli:"min-height" usage:"skip the images smaller than the height column of csv list --min-height=100" dft:"0"`
+	Format           string `cli:"format" usage:"comma separate image formats to use by format column of csv list --format='jpeg,png'"`
+	FetchConcurrency int    `cli:"fetch-concurrency" usage:"max number of concurrent downloads of URL --fetch-concurrency=4" dft:"4"`
+	FetchTimeout     int    `cli:"fetch-timeout" usage:"timeout of downloading URL in seconds --fetch-timeout=30" dft:"30"`
+	MaxDownloadSize  string `cli:"max-download-size" usage:"max file size of downloading URL --max-download-size='20MB'" dft:"20MB"`
+	CacheDir         string `cli:"cache-dir" usage:"dir to cache the downloaded images --cache-dir='./cache'"`
+	Warmup           int    `cli:"warmup" usage:"number of the first images of each engine excluded from the latency summary --warmup=3" dft:"0"`
 }
 
 var detector = &cli.Command{
@@ -26,97 +37,102 @@ var detector = &cli.Command{
 	Fn:   execDetector,
 }
 
-var (
-	baseDir    string
-	pathPrefix string
-)
-
 func execDetector(ctx *cli.Context) error {
 	argv := ctx.Argv().(*detectorT)
 	conf := NewConfig(argv.UseAllEngine)
 	conf.setInputPath(argv.Input)
 	conf.setOutputPath(argv.Output)
+	conf.setMetaFilter(newImageMetaFilter(argv.Dedup, argv.MinWidth, argv.MinHeight, argv.Format))
+
+	maxDownloadSize, err := parseByteSize(argv.MaxDownloadSize)
+	if err != nil {
+		return err
+	}
+	conf.setOutputFormat(argv.OutputFormat)
+	conf.setWarmup(argv.Warmup)
+
 	for _, e := range strings.Split(argv.Engines, ",") {
 		if err := conf.setUseEngineFromName(e); err != nil {
 			return errors.Wrap(err, "[ERROR] setUseEngineFromName")
 		}
 	}
 
-	engines, err := initEngines(conf, enabledEngines)
-	if err != nil {
-		return errors.Wrap(err, "[ERROR] initEngines")
+	opts := []Option{
+		WithConfig(conf),
+		WithEngineRegistry(enabledEngines...),
+		WithURLFetch(argv.FetchConcurrency, time.Duration(argv.FetchTimeout)*time.Second, maxDownloadSize, argv.CacheDir),
 	}
-
 	switch {
 	case conf.isCSVFilePath():
-		return detectFromCSV(engines, conf)
+		return detectFromCSV(conf, opts)
 	default:
-		return detectFromImage(engines, conf)
+		return detectFromImage(conf, opts)
 	}
 }
 
-func detectFromImage(engines []engine.Engine, conf Config) error {
-	for _, e := range engines {
-		faceResult, err := e.Detect(conf.InputPath)
-		if err != nil {
+func detectFromImage(conf Config, opts []Option) error {
+	p, err := NewPipeline(opts...)
+	if err != nil {
+		return errors.Wrap(err, "[ERROR] NewPipeline")
+	}
+
+	r := p.Detect(conf.InputPath)
+	if r.Error != nil {
+		return fmt.Errorf("[ERROR] %s\n", r.Error.Error())
+	}
+	for i, e := range p.Engines() {
+		if err := r.Errors[i]; err != nil {
 			return fmt.Errorf("[ERROR] %s\n", err.Error())
 		}
-		fmt.Printf("%s\t%s\n", e, faceResult.ShowOutput())
+		fmt.Printf("%s\t%s\n", e, r.Results[i].ShowOutput())
 	}
 	return nil
 }
 
-func detectFromCSV(engines []engine.Engine, conf Config) error {
+func detectFromCSV(conf Config, opts []Option) error {
 	f, err := NewCSVHandler(conf.InputPath)
 	if err != nil {
 		return err
 	}
 
-	w, err := NewFileHandler(conf.OutputPath)
+	lines, err := f.ReadAll()
 	if err != nil {
 		return err
 	}
+	lines = conf.metaFilter.Filter(lines)
 
-	maxReq := make(chan struct{}, 10)
-
-	lines, err := f.ReadAll()
+	sink, err := newSink(conf.OutputFormat, conf.OutputPath)
 	if err != nil {
 		return err
 	}
 
-	result := make([]string, len(lines))
-	var wg sync.WaitGroup
-	for i, line := range lines {
-		wg.Add(1)
-		go func(i int, line map[string]string) {
-			maxReq <- struct{}{}
-			defer func() {
-				<-maxReq
-				wg.Done()
-			}()
-
-			fmt.Printf("exec #: [%d]\n", i)
-			imgPath := line["path"]
-
-			row := make([]string, len(engines)+2)
-			row[0] = imgPath
-			row[1] = line["count"]
-			for i, e := range engines {
-				faceResult, err := e.Detect(imgPath)
-				if err != nil {
-					fmt.Printf("[ERROR] %s\n", err.Error())
-					continue
-				}
-				row[i+2] = faceResult.ShowOutput()
-
-			}
-			result[i] = strings.Join(row, "\t")
-		}(i, line)
+	done := 0
+	var stats *latencyStats
+	opts = append(opts, WithConcurrency(10), WithSink(sink), WithCallback(func(r DetectResult) {
+		r.printErrors()
+		stats.Add(r)
+		fmt.Printf("done #: [%d]\n", done)
+		done++
+	}))
+	p, err := NewPipeline(opts...)
+	if err != nil {
+		return errors.Wrap(err, "[ERROR] NewPipeline")
 	}
-	wg.Wait()
+	stats = newLatencyStats(p.Engines(), conf.Warmup)
 
-	result = append([]string{getHeader(engines)}, result...)
-	return w.WriteAll(result)
+	inputs := make([]Input, len(lines))
+	for i, line := range lines {
+		inputs[i] = Input{
+			Path:  line[colPath],
+			Count: line[colCount],
+		}
+	}
+	if err := p.RunInputs(context.Background(), inputs); err != nil {
+		p.Close()
+		return err
+	}
+	stats.Print()
+	return p.Close()
 }
 
 func getHeader(engines []engine.Engine) string {
@@ -126,7 +142,8 @@ func getHeader(engines []engine.Engine) string {
 	}
 	for _, e := range engines {
 		s := e.String()
-		header = append(header, s+":count", s+":detail")
+		header = append(header, s+colSuffixCount, s+colSuffixDetail, s+colSuffixLatency)
 	}
+	header = append(header, colError)
 	return strings.Join(header, "\t")
 }
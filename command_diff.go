@@ -0,0 +1,389 @@
+package fda
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mkideal/cli"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// suffixes of the engine names in the TSV of changed images.
+const (
+	diffSuffixBase   = ".base"
+	diffSuffixTarget = ".target"
+)
+
+// diff command
+type diffT struct {
+	cli.Helper
+	Base     string  `cli:"*b,base" usage:"detector's output tsv file of the base run --base='/path/to/before.tsv'"`
+	Target   string  `cli:"*t,target" usage:"detector's output tsv file to compare with the base --target='/path/to/after.tsv'"`
+	Output   string  `cli:"o,output" usage:"output tsv file path of the changed images for annotate command (empty means no file) --output='./diff.tsv'"`
+	Engines  string  `cli:"e,engine" usage:"comma separate engines to compare (empty means all engines in both files) --engine='pigo'"`
+	IoU      float64 `cli:"iou" usage:"IoU threshold to match the same face --iou=0.5" dft:"0.5"`
+	MovedIoU float64 `cli:"moved-iou" usage:"matched faces below the IoU are reported as moved --moved-iou=0.8" dft:"0.8"`
+}
+
+var differ = &cli.Command{
+	Name: "diff",
+	Desc: "Compare two detector's output TSV files and report the changes",
+	Argv: func() interface{} { return new(diffT) },
+	Fn:   execDiff,
+}
+
+func execDiff(ctx *cli.Context) error {
+	argv := ctx.Argv().(*diffT)
+
+	base, err := readDetectResult(argv.Base)
+	if err != nil {
+		return err
+	}
+	target, err := readDetectResult(argv.Target)
+	if err != nil {
+		return err
+	}
+	engines, err := getDiffEngines(base.Engines, target.Engines, argv.Engines)
+	if err != nil {
+		return err
+	}
+
+	targetRows := make(map[string]detectResultRow, len(target.Rows))
+	for _, row := range target.Rows {
+		if _, ok := targetRows[row.Path]; !ok {
+			targetRows[row.Path] = row
+		}
+	}
+
+	summaries := make([]*engineDiff, len(engines))
+	for i, e := range engines {
+		summaries[i] = &engineDiff{Engine: e}
+	}
+
+	var changed []diffRow
+	seen := make(map[string]bool, len(base.Rows))
+	onlyBase := 0
+	fmt.Println(strings.Join([]string{"engine", "path", "base", "target", "appeared", "disappeared", "moved"}, "\t"))
+	for _, row := range base.Rows {
+		if seen[row.Path] {
+			continue
+		}
+		seen[row.Path] = true
+		t, ok := targetRows[row.Path]
+		if !ok {
+			onlyBase++
+			continue
+		}
+
+		r := diffRow{
+			Base:   row,
+			Target: t,
+			Diffs:  make([]imageDiff, len(engines)),
+		}
+		for i, e := range engines {
+			d := diffImage(e, row, t, argv.IoU, argv.MovedIoU)
+			r.Diffs[i] = d
+			summaries[i].Add(d)
+			if d.Changed() {
+				fmt.Println(strings.Join(d.Columns(row.Path), "\t"))
+			}
+		}
+		if r.Changed() {
+			changed = append(changed, r)
+		}
+	}
+	onlyTarget := 0
+	for path := range targetRows {
+		if !seen[path] {
+			onlyTarget++
+		}
+	}
+
+	fmt.Printf("\n[INFO] images only in base: %d\timages only in target: %d\tchanged images: %d\n", onlyBase, onlyTarget, len(changed))
+	fmt.Println(strings.Join(engineDiffHeader, "\t"))
+	for _, s := range summaries {
+		fmt.Println(strings.Join(s.Columns(), "\t"))
+	}
+
+	if argv.Output == "" {
+		return nil
+	}
+	return writeDiffTSV(argv.Output, engines, changed)
+}
+
+// getDiffEngines returns the engines to compare.
+func getDiffEngines(base, target []string, names string) ([]string, error) {
+	var engines []string
+	if names == "" {
+		for _, e := range base {
+			if hasEngine(target, e) {
+				engines = append(engines, e)
+				continue
+			}
+			fmt.Printf("[WARN] engine '%s' is not found in target\n", e)
+		}
+		for _, e := range target {
+			if !hasEngine(base, e) {
+				fmt.Printf("[WARN] engine '%s' is not found in base\n", e)
+			}
+		}
+	} else {
+		for _, e := range strings.Split(names, ",") {
+			e = strings.TrimSpace(e)
+			if !hasEngine(base, e) || !hasEngine(target, e) {
+				return nil, fmt.Errorf("engine '%s' is not found in both files: base=%+v target=%+v", e, base, target)
+			}
+			engines = append(engines, e)
+		}
+	}
+	if len(engines) == 0 {
+		return nil, fmt.Errorf("no common engine: base=%+v target=%+v", base, target)
+	}
+	return engines, nil
+}
+
+// imageDiff is the changes of an engine on an image.
+type imageDiff struct {
+	Engine string
+	// nil means the detection was failed.
+	Base   *engine.FaceResult
+	Target *engine.FaceResult
+
+	Matched     int
+	Appeared    int
+	Disappeared int
+	Moved       int
+	// sum of IoU of the matched faces.
+	IoUSum float64
+}
+
+// diffImage matches the faces of the base and target by IoU.
+func diffImage(e string, base, target detectResultRow, iou, movedIoU float64) imageDiff {
+	d := imageDiff{
+		Engine: e,
+	}
+	if r, ok := base.Results[e]; ok {
+		d.Base = &r
+	}
+	if r, ok := target.Results[e]; ok {
+		d.Target = &r
+	}
+	if d.Base == nil || d.Target == nil {
+		return d
+	}
+
+	matches, onlyBase, onlyTarget := matchFaces(d.Base.Faces, d.Target.Faces, iou)
+	d.Matched = len(matches)
+	d.Disappeared = len(onlyBase)
+	d.Appeared = len(onlyTarget)
+	for _, m := range matches {
+		d.IoUSum += m.IoU
+		if m.IoU < movedIoU {
+			d.Moved++
+		}
+	}
+	return d
+}
+
+func (d imageDiff) IsFailedChanged() bool {
+	return (d.Base == nil) != (d.Target == nil)
+}
+
+func (d imageDiff) Changed() bool {
+	return d.IsFailedChanged() || d.Appeared != 0 || d.Disappeared != 0 || d.Moved != 0
+}
+
+// Columns returns the line of changed image.
+func (d imageDiff) Columns(path string) []string {
+	return []string{
+		d.Engine,
+		path,
+		formatDiffCount(d.Base),
+		formatDiffCount(d.Target),
+		strconv.Itoa(d.Appeared),
+		strconv.Itoa(d.Disappeared),
+		strconv.Itoa(d.Moved),
+	}
+}
+
+// String returns the short description of the changes.
+func (d imageDiff) String() string {
+	return fmt.Sprintf("%s count:%s->%s appeared:%d disappeared:%d moved:%d",
+		d.Engine, formatDiffCount(d.Base), formatDiffCount(d.Target), d.Appeared, d.Disappeared, d.Moved)
+}
+
+func formatDiffCount(r *engine.FaceResult) string {
+	if r == nil {
+		return "failed"
+	}
+	return strconv.Itoa(len(r.Faces))
+}
+
+// engineDiff is the aggregated changes of an engine.
+type engineDiff struct {
+	Engine       string
+	Images       int
+	Changed      int
+	CountChanged int
+	Increased    int
+	Decreased    int
+	BaseFaces    int
+	TargetFaces  int
+	Matched      int
+	Appeared     int
+	Disappeared  int
+	Moved        int
+	IoUSum       float64
+	NewlyFailed  int
+	Recovered    int
+}
+
+var engineDiffHeader = []string{
+	"engine",
+	"images",
+	"changed",
+	"count_changed",
+	"increased",
+	"decreased",
+	"base_faces",
+	"target_faces",
+	"delta",
+	"appeared",
+	"disappeared",
+	"moved",
+	"mean_iou",
+	"newly_failed",
+	"recovered",
+}
+
+// Add aggregates the changes of an image.
+// The faces are counted only when the detections of both runs are succeeded.
+func (s *engineDiff) Add(d imageDiff) {
+	s.Images++
+	if d.Changed() {
+		s.Changed++
+	}
+	switch {
+	case d.Base != nil && d.Target == nil:
+		s.NewlyFailed++
+		return
+	case d.Base == nil && d.Target != nil:
+		s.Recovered++
+		return
+	case d.Base == nil && d.Target == nil:
+		return
+	}
+
+	baseCount, targetCount := len(d.Base.Faces), len(d.Target.Faces)
+	switch {
+	case targetCount > baseCount:
+		s.CountChanged++
+		s.Increased++
+	case targetCount < baseCount:
+		s.CountChanged++
+		s.Decreased++
+	}
+	s.BaseFaces += baseCount
+	s.TargetFaces += targetCount
+	s.Matched += d.Matched
+	s.Appeared += d.Appeared
+	s.Disappeared += d.Disappeared
+	s.Moved += d.Moved
+	s.IoUSum += d.IoUSum
+}
+
+// Columns returns the line of summary.
+func (s *engineDiff) Columns() []string {
+	meanIoU := 0.0
+	if s.Matched != 0 {
+		meanIoU = s.IoUSum / float64(s.Matched)
+	}
+	return []string{
+		s.Engine,
+		strconv.Itoa(s.Images),
+		strconv.Itoa(s.Changed),
+		strconv.Itoa(s.CountChanged),
+		strconv.Itoa(s.Increased),
+		strconv.Itoa(s.Decreased),
+		strconv.Itoa(s.BaseFaces),
+		strconv.Itoa(s.TargetFaces),
+		fmt.Sprintf("%+d", s.TargetFaces-s.BaseFaces),
+		strconv.Itoa(s.Appeared),
+		strconv.Itoa(s.Disappeared),
+		strconv.Itoa(s.Moved),
+		strconv.FormatFloat(meanIoU, 'f', 3, 64),
+		strconv.Itoa(s.NewlyFailed),
+		strconv.Itoa(s.Recovered),
+	}
+}
+
+// diffRow is the changes of all engines on an image.
+type diffRow struct {
+	Base   detectResultRow
+	Target detectResultRow
+	Diffs  []imageDiff
+}
+
+func (r diffRow) Changed() bool {
+	for _, d := range r.Diffs {
+		if d.Changed() {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDiffTSV writes the changed images in the format of detector's output.
+// The results of each engine are written as '<engine>.base' and '<engine>.target' to annotate them side by side.
+func writeDiffTSV(file string, engines []string, rows []diffRow) error {
+	f, err := NewFileHandler(file)
+	if err != nil {
+		return err
+	}
+
+	header := []string{colPath, colCount}
+	for _, e := range engines {
+		for _, suffix := range []string{diffSuffixBase, diffSuffixTarget} {
+			header = append(header, e+suffix+colSuffixCount, e+suffix+colSuffixDetail)
+		}
+	}
+	header = append(header, "changes", colError)
+
+	lines := make([]string, 0, len(rows)+1)
+	lines = append(lines, strings.Join(header, "\t"))
+	for _, r := range rows {
+		line := []string{r.Base.Path, r.Base.Count}
+		var changes []string
+		for _, d := range r.Diffs {
+			line = append(line, renamedResultColumns(d.Base, d.Engine+diffSuffixBase)...)
+			line = append(line, renamedResultColumns(d.Target, d.Engine+diffSuffixTarget)...)
+			if d.Changed() {
+				changes = append(changes, d.String())
+			}
+		}
+		errMsg := r.Target.Error
+		if errMsg == "" {
+			errMsg = r.Base.Error
+		}
+		line = append(line, strings.Join(changes, ", "), errMsg)
+		lines = append(lines, strings.Join(line, "\t"))
+	}
+	return f.WriteAll(lines)
+}
+
+// renamedResultColumns returns the count and detail columns of the result with the engine name.
+func renamedResultColumns(r *engine.FaceResult, name string) []string {
+	if r == nil {
+		return []string{"", ""}
+	}
+	renamed := *r
+	renamed.EngineName = name
+	byt, err := json.Marshal(renamed)
+	if err != nil {
+		return []string{"", ""}
+	}
+	return []string{strconv.Itoa(len(r.Faces)), string(byt)}
+}
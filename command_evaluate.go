@@ -0,0 +1,363 @@
+package fda
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mkideal/cli"
+)
+
+// evaluate command
+type evaluateT struct {
+	cli.Helper
+	Input       string  `cli:"*i,input" usage:"detector's output tsv file --input='/path/to/output.tsv'"`
+	Truth       string  `cli:"g,gt" usage:"ground truth file of --gt-format (empty means --input) --gt='/path/to/consensus.tsv'"`
+	TruthFormat string  `cli:"gt-format" usage:"format of --gt file [tsv,wider] --gt-format='wider'" dft:"tsv"`
+	TruthEngine string  `cli:"gt-engine" usage:"engine name of ground truth in --gt file (empty means the first engine) --gt-engine='consensus'"`
+	Engines     string  `cli:"e,engine" usage:"comma separate engines to evaluate (empty means all engines except ground truth) --engine='pigo,tensorflow'"`
+	IoU         float64 `cli:"iou" usage:"IoU threshold to match the ground truth --iou=0.5" dft:"0.5"`
+	Output      string  `cli:"o,output" usage:"output file path (empty means stdout) --output='./evaluation.md'"`
+	Format      string  `cli:"f,format" usage:"output format [text,json,markdown] --format='text'" dft:"text"`
+	Curve       string  `cli:"curve" usage:"output CSV file path of threshold sweep (empty means no file) --curve='./pr_curve.csv'"`
+	Chart       string  `cli:"chart" usage:"output PNG file path of precision-recall chart (empty means no file) --chart='./pr_curve.png'"`
+	List        string  `cli:"list" usage:"list csv file with metadata columns to filter the images (empty means no metadata) --list='/path/to/list.csv'"`
+	Dedup       bool    `cli:"dedup" usage:"skip the duplicate images by sha256 column of --list"`
+	MinWidth    int     `cli:"min-width" usage:"skip the images smaller than the width column of --list --min-width=100" dft:"0"`
+	MinHeight   int     `cli:"min-height" usage:"skip the images smaller than the height column of --list --min-height=100" dft:"0"`
+	ImageFormat string  `cli:"image-format" usage:"comma separate image formats to use by format column of --list --image-format='jpeg,png'"`
+	Slices      string  `cli:"slice" usage:"comma separate slices to evaluate [size,resolution,attribute] (empty means no slice) --slice='size,attribute'" dft:"size,resolution,attribute"`
+}
+
+// formats of ground truth file.
+const (
+	truthFormatTSV   = "tsv"
+	truthFormatWider = "wider"
+)
+
+var evaluator = &cli.Command{
+	Name: "evaluate",
+	Desc: "Evaluate engines of --input TSV file against ground truth",
+	Argv: func() interface{} { return new(evaluateT) },
+	Fn:   execEvaluate,
+}
+
+func execEvaluate(ctx *cli.Context) error {
+	argv := ctx.Argv().(*evaluateT)
+
+	var render func(io.Writer, evaluation) error
+	switch argv.Format {
+	case tableFormatText:
+		render = renderEvaluationText
+	case tableFormatJSON:
+		render = renderEvaluationJSON
+	case tableFormatMarkdown:
+		render = renderEvaluationMarkdown
+	default:
+		return fmt.Errorf("unknown format: [%s]", argv.Format)
+	}
+
+	result, err := readDetectResult(argv.Input)
+	if err != nil {
+		return err
+	}
+	if argv.List != "" {
+		if err := setListMeta(result.Rows, argv.List); err != nil {
+			return err
+		}
+	}
+	result.Rows = newImageMetaFilter(argv.Dedup, argv.MinWidth, argv.MinHeight, argv.ImageFormat).FilterRows(result.Rows)
+
+	truth, truthEngine, err := loadEvalTruth(result, argv.Truth, argv.TruthFormat, argv.TruthEngine)
+	if err != nil {
+		return err
+	}
+
+	slices, err := getEvalSlices(parseSliceNames(argv.Slices), truth.Faces)
+	if err != nil {
+		return err
+	}
+
+	engines, err := getEvalEngines(result, argv.Engines, truthEngine, argv.Truth == "")
+	if err != nil {
+		return err
+	}
+
+	sizes := getImageSizes(result.Rows, truth.Faces)
+	fillTruthPercent(truth.Faces, sizes)
+
+	ev := evaluation{
+		Truth: truthEngine,
+		IoU:   argv.IoU,
+	}
+	for _, e := range engines {
+		images := getEvalImages(result.Rows, truth, sizes, e)
+		ev.Engines = append(ev.Engines, evaluateEngine(e, images, argv.IoU))
+		for _, s := range slices {
+			ev.Slices = append(ev.Slices, evaluateSlice(e, images, s, argv.IoU)...)
+		}
+	}
+
+	if argv.Curve != "" {
+		if err := writeCurveCSV(argv.Curve, ev.Engines); err != nil {
+			return err
+		}
+	}
+	if argv.Chart != "" {
+		if err := savePRChart(argv.Chart, ev.Engines); err != nil {
+			return err
+		}
+	}
+	return writeOutput(argv.Output, func(w io.Writer) error {
+		return render(w, ev)
+	})
+}
+
+// setListMeta sets the metadata columns of the list csv file into the lines of the rows by the path.
+func setListMeta(rows []detectResultRow, file string) error {
+	f, err := NewCSVHandler(file)
+	if err != nil {
+		return err
+	}
+	lines, err := f.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	meta := make(map[string]map[string]string, len(lines))
+	for _, line := range lines {
+		meta[line[colPath]] = line
+	}
+	for _, row := range rows {
+		line, ok := meta[row.Path]
+		if !ok {
+			continue
+		}
+		for _, col := range imageMetaHeader {
+			if _, ok := row.Line[col]; !ok && line[col] != "" {
+				row.Line[col] = line[col]
+			}
+		}
+	}
+	return nil
+}
+
+// loadEvalTruth returns the ground truth faces by the path of the rows, and the engine name of the ground truth.
+// The empty file means the ground truth engine in the result.
+func loadEvalTruth(result *detectResult, file, format, truthEngine string) (evalTruth, string, error) {
+	var truth evalTruth
+	switch format {
+	case truthFormatTSV:
+		truthResult := result
+		if file != "" {
+			var err error
+			if truthResult, err = readDetectResult(file); err != nil {
+				return truth, "", err
+			}
+		}
+
+		switch {
+		case truthEngine == "" && file == "":
+			return truth, "", fmt.Errorf("--gt-engine is required when --gt is empty")
+		case truthEngine == "" && len(truthResult.Engines) != 0:
+			truthEngine = truthResult.Engines[0]
+		case !hasEngine(truthResult.Engines, truthEngine):
+			return truth, "", fmt.Errorf("ground truth engine '%s' is not found in %+v", truthEngine, truthResult.Engines)
+		}
+		truth.Faces = getTruthFaces(truthResult, truthEngine)
+	case truthFormatWider:
+		if file == "" {
+			return truth, "", fmt.Errorf("--gt is required for --gt-format=wider")
+		}
+		annotation, err := readWiderAnnotation(file)
+		if err != nil {
+			return truth, "", err
+		}
+		truthEngine = widerEngineName
+		truth.Faces = getWiderTruthFaces(result.Rows, annotation.Faces)
+		truth.Ignore = getWiderTruthFaces(result.Rows, annotation.Invalid)
+	default:
+		return truth, "", fmt.Errorf("unknown ground truth format: [%s]", format)
+	}
+	if len(truth.Faces) == 0 {
+		return truth, "", fmt.Errorf("no ground truth is found for the images of --input")
+	}
+	return truth, truthEngine, nil
+}
+
+// getEvalEngines returns the comma separated engines, or all engines of the result.
+// The ground truth engine is excluded from all engines when it is in the result.
+func getEvalEngines(result *detectResult, names, truthEngine string, truthInResult bool) ([]string, error) {
+	var engines []string
+	if names != "" {
+		for _, e := range strings.Split(names, ",") {
+			e = strings.TrimSpace(e)
+			if !hasEngine(result.Engines, e) {
+				return nil, fmt.Errorf("engine '%s' is not found in %+v", e, result.Engines)
+			}
+			engines = append(engines, e)
+		}
+	} else {
+		for _, e := range result.Engines {
+			if truthInResult && e == truthEngine {
+				continue
+			}
+			engines = append(engines, e)
+		}
+	}
+	if len(engines) == 0 {
+		return nil, fmt.Errorf("no engine to evaluate: %+v", result.Engines)
+	}
+	return engines, nil
+}
+
+// evaluation is the result of evaluate command.
+type evaluation struct {
+	// engine name of the ground truth.
+	Truth   string       `json:"truth"`
+	IoU     float64      `json:"iou"`
+	Engines []engineEval `json:"engines"`
+	// evaluation of each engine on each bucket of the slices.
+	Slices []sliceEval `json:"slices,omitempty"`
+}
+
+func renderEvaluationJSON(w io.Writer, ev evaluation) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ev)
+}
+
+func renderEvaluationText(w io.Writer, ev evaluation) error {
+	writeTablesText(w, newEvaluationTables(ev))
+	return nil
+}
+
+func renderEvaluationMarkdown(w io.Writer, ev evaluation) error {
+	fmt.Fprintf(w, "# Evaluation\n\nGround truth: %s\n\nIoU threshold: %s\n", ev.Truth, strconv.FormatFloat(ev.IoU, 'f', -1, 64))
+	writeTablesMarkdown(w, newEvaluationTables(ev))
+	return nil
+}
+
+func newEvaluationTables(ev evaluation) []statsTable {
+	summary := statsTable{
+		Title: "Evaluation against " + ev.Truth,
+		Header: []string{
+			"engine", "images", "truth_faces", "faces", "ap",
+			"precision", "recall", "f1",
+			"best_threshold", "best_precision", "best_recall", "best_f1",
+		},
+	}
+	for _, e := range ev.Engines {
+		summary.Rows = append(summary.Rows, []string{
+			e.Engine,
+			strconv.Itoa(e.Images),
+			strconv.Itoa(e.TruthFaces),
+			strconv.Itoa(e.Faces),
+			formatStatsFloat(e.AP),
+			formatStatsFloat(e.All.Precision),
+			formatStatsFloat(e.All.Recall),
+			formatStatsFloat(e.All.F1),
+			formatStatsFloat(e.Best.Threshold),
+			formatStatsFloat(e.Best.Precision),
+			formatStatsFloat(e.Best.Recall),
+			formatStatsFloat(e.Best.F1),
+		})
+	}
+	tables := []statsTable{summary}
+
+	// the tables of each slice, ordered by bucket and engine.
+	var sliceNames []string
+	bySlice := make(map[string][]sliceEval)
+	for _, s := range ev.Slices {
+		if _, ok := bySlice[s.Slice]; !ok {
+			sliceNames = append(sliceNames, s.Slice)
+		}
+		bySlice[s.Slice] = append(bySlice[s.Slice], s)
+	}
+	for _, name := range sliceNames {
+		var buckets []string
+		byBucket := make(map[string][]sliceEval)
+		for _, s := range bySlice[name] {
+			if _, ok := byBucket[s.Bucket]; !ok {
+				buckets = append(buckets, s.Bucket)
+			}
+			byBucket[s.Bucket] = append(byBucket[s.Bucket], s)
+		}
+
+		t := statsTable{
+			Title:  "Evaluation by " + name,
+			Header: []string{"bucket", "engine", "images", "truth_faces", "tp", "fp", "fn", "precision", "recall", "f1"},
+		}
+		for _, b := range buckets {
+			if isEmptyBucket(byBucket[b]) {
+				continue
+			}
+			for _, s := range byBucket[b] {
+				fp := strconv.Itoa(s.FP)
+				if s.Precision == nil {
+					fp = "-"
+				}
+				t.Rows = append(t.Rows, []string{
+					s.Bucket,
+					s.Engine,
+					strconv.Itoa(s.Images),
+					strconv.Itoa(s.TruthFaces),
+					strconv.Itoa(s.TP),
+					fp,
+					strconv.Itoa(s.FN),
+					formatOptionalFloat(s.Precision),
+					formatStatsFloat(s.Recall),
+					formatOptionalFloat(s.F1),
+				})
+			}
+		}
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+// isEmptyBucket returns true when no engine has the faces in the bucket.
+func isEmptyBucket(evals []sliceEval) bool {
+	for _, s := range evals {
+		if s.TruthFaces != 0 || s.FP != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// formatOptionalFloat returns "-" for the value which is not available.
+func formatOptionalFloat(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return formatStatsFloat(*v)
+}
+
+// writeCurveCSV writes the threshold sweep of each engine.
+func writeCurveCSV(file string, evals []engineEval) error {
+	f, err := NewFileHandler(file)
+	if err != nil {
+		return err
+	}
+
+	records := [][]string{{"engine", "threshold", "tp", "fp", "fn", "precision", "recall", "f1", "fp_per_image"}}
+	for _, ev := range evals {
+		for _, p := range ev.Curve {
+			records = append(records, []string{
+				ev.Engine,
+				formatFloat(p.Threshold),
+				strconv.Itoa(p.TP),
+				strconv.Itoa(p.FP),
+				strconv.Itoa(p.FN),
+				formatFloat(p.Precision),
+				formatFloat(p.Recall),
+				formatFloat(p.F1),
+				formatFloat(p.FPPerImage),
+			})
+		}
+	}
+	return f.WriteCSV(records)
+}
@@ -0,0 +1,283 @@
+package fda
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mkideal/cli"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+	"github.com/evalphobia/face-detect-annotator/pb"
+)
+
+// grpc command
+type grpcT struct {
+	cli.Helper
+	Addr             string `cli:"addr" usage:"address to listen --addr=':9090'" dft:":9090"`
+	UseAllEngine     bool   `cli:"a,all" usage:"use all engines"`
+	Engines          string `cli:"e,engine" usage:"comma separate Face Detect Engines --engine='opencv,dlib,pigo,tensorflow,rekognition,google,azure,face++'" dft:"opencv,dlib,pigo,tensorflow"`
+	Concurrency      int    `cli:"c,concurrency" usage:"number of images detected concurrently in each stream --concurrency=4" dft:"4"`
+	MaxUploadSize    string `cli:"max-upload-size" usage:"max size of request message --max-upload-size='20MB'" dft:"20MB"`
+	FetchConcurrency int    `cli:"fetch-concurrency" usage:"max number of concurrent downloads of URL --fetch-concurrency=4" dft:"4"`
+	FetchTimeout     int    `cli:"fetch-timeout" usage:"timeout of downloading URL in seconds --fetch-timeout=30" dft:"30"`
+	MaxDownloadSize  string `cli:"max-download-size" usage:"max file size of downloading URL --max-download-size='20MB'" dft:"20MB"`
+	AllowPrivateURL  bool   `cli:"allow-private-url" usage:"allow downloading URL of private, loopback and link-local addresses"`
+	ShutdownTimeout  int    `cli:"shutdown-timeout" usage:"seconds to wait the requests in progress on shutdown --shutdown-timeout=30" dft:"30"`
+}
+
+var grpcServer = &cli.Command{
+	Name: "grpc",
+	Desc: "Serve gRPC API to detect faces",
+	Argv: func() interface{} { return new(grpcT) },
+	Fn:   execGRPC,
+}
+
+func execGRPC(ctx *cli.Context) error {
+	argv := ctx.Argv().(*grpcT)
+	conf := NewConfig(argv.UseAllEngine)
+	for _, e := range strings.Split(argv.Engines, ",") {
+		if err := conf.setUseEngineFromName(e); err != nil {
+			return errors.Wrap(err, "[ERROR] setUseEngineFromName")
+		}
+	}
+
+	maxUploadSize, err := parseByteSize(argv.MaxUploadSize)
+	if err != nil {
+		return err
+	}
+	maxDownloadSize, err := parseByteSize(argv.MaxDownloadSize)
+	if err != nil {
+		return err
+	}
+	fetcher := newURLFetcher(argv.FetchConcurrency, time.Duration(argv.FetchTimeout)*time.Second, maxDownloadSize, "")
+	if !argv.AllowPrivateURL {
+		fetcher.denyPrivateNetwork()
+	}
+
+	lis, err := net.Listen("tcp", argv.Addr)
+	if err != nil {
+		return err
+	}
+
+	var opts []grpc.ServerOption
+	if maxUploadSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(maxUploadSize)))
+	}
+	srv := grpc.NewServer(opts...)
+	d := newGRPCDetector(fetcher, argv.Concurrency)
+	pb.RegisterDetectorServer(srv, d)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+
+	errCh := make(chan error, 2)
+	go func() {
+		errCh <- srv.Serve(lis)
+	}()
+	fmt.Printf("[INFO] listening: %s\n", argv.Addr)
+
+	// initialize the engines after listening, to respond to the health check during the initialization.
+	go func() {
+		engines, err := initEngines(conf, enabledEngines)
+		if err != nil {
+			errCh <- errors.Wrap(err, "[ERROR] initEngines")
+			return
+		}
+		d.setEngines(engines)
+		healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		fmt.Printf("[INFO] ready\n")
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	select {
+	case err := <-errCh:
+		srv.Stop()
+		return err
+	case <-sig:
+	}
+
+	fmt.Printf("[INFO] shutting down...\n")
+	healthServer.Shutdown()
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Duration(argv.ShutdownTimeout) * time.Second):
+		srv.Stop()
+	}
+	return nil
+}
+
+// grpcDetector implements pb.DetectorServer.
+type grpcDetector struct {
+	fetcher *urlFetcher
+	// number of images detected concurrently in each stream.
+	concurrency int
+
+	mu sync.RWMutex
+	// nil means the engines are not initialized yet.
+	engines []engine.Engine
+}
+
+func newGRPCDetector(fetcher *urlFetcher, concurrency int) *grpcDetector {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &grpcDetector{
+		fetcher:     fetcher,
+		concurrency: concurrency,
+	}
+}
+
+func (d *grpcDetector) setEngines(engines []engine.Engine) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.engines = engines
+}
+
+func (d *grpcDetector) getEngines() ([]engine.Engine, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.engines == nil {
+		return nil, status.Error(codes.Unavailable, "engines are not initialized yet")
+	}
+	return d.engines, nil
+}
+
+func (d *grpcDetector) ListEngines(ctx context.Context, req *pb.ListEnginesRequest) (*pb.ListEnginesResponse, error) {
+	engines, err := d.getEngines()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ListEnginesResponse{}
+	for _, e := range engines {
+		resp.Engines = append(resp.Engines, e.String())
+	}
+	return resp, nil
+}
+
+func (d *grpcDetector) Detect(ctx context.Context, req *pb.DetectRequest) (*pb.DetectResponse, error) {
+	engines, err := d.getEngines()
+	if err != nil {
+		return nil, err
+	}
+	return d.detect(engines, req)
+}
+
+// DetectStream detects the images concurrently, and sends the responses in the order of completion.
+// The error of each request is set into the response, not to stop the stream.
+func (d *grpcDetector) DetectStream(stream pb.Detector_DetectStreamServer) error {
+	engines, err := d.getEngines()
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var sendMu sync.Mutex
+	var sendErr error
+	sem := make(chan struct{}, d.concurrency)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(req *pb.DetectRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			resp, err := d.detect(engines, req)
+			if err != nil {
+				resp = &pb.DetectResponse{
+					Id:    req.GetId(),
+					Error: status.Convert(err).Message(),
+				}
+			}
+
+			sendMu.Lock()
+			defer sendMu.Unlock()
+			if sendErr != nil {
+				return
+			}
+			sendErr = stream.Send(resp)
+		}(req)
+	}
+
+	wg.Wait()
+	return sendErr
+}
+
+func (d *grpcDetector) detect(allEngines []engine.Engine, req *pb.DetectRequest) (*pb.DetectResponse, error) {
+	engines, err := selectEngines(allEngines, strings.Join(req.GetEngines(), ","))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var in *detectInput
+	switch src := req.GetSource().(type) {
+	case *pb.DetectRequest_Image:
+		in = newDetectInputFromBytes(req.GetName(), src.Image)
+	case *pb.DetectRequest_Url:
+		// do not read local files and S3 objects of the server.
+		if !isURLPath(src.Url) {
+			return nil, status.Errorf(codes.InvalidArgument, "url must be http(s): [%s]", src.Url)
+		}
+		if in, err = newDetectInput(src.Url, imageReader{fetcher: d.fetcher}); err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "image or url is required")
+	}
+	defer in.Close()
+
+	resp := &pb.DetectResponse{
+		Id: req.GetId(),
+	}
+	for _, e := range engines {
+		faceResult, err := in.Detect(e)
+		if err != nil {
+			fmt.Printf("[ERROR] engine:%s\terr:%s\n", e.String(), err.Error())
+			if resp.EngineErrors == nil {
+				resp.EngineErrors = make(map[string]string)
+			}
+			resp.EngineErrors[e.String()] = err.Error()
+			continue
+		}
+		resp.Results = append(resp.Results, pb.NewFaceResult(faceResult))
+	}
+	if len(resp.Results) == 0 {
+		return nil, status.Errorf(codes.Internal, "all engines failed: %v", resp.EngineErrors)
+	}
+	return resp, nil
+}
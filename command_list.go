@@ -2,10 +2,12 @@ package fda
 
 import (
 	"fmt"
-	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/mkideal/cli"
 )
@@ -13,11 +15,31 @@ import (
 // list command
 type listT struct {
 	cli.Helper
-	Input          string `cli:"*i,input" usage:"image dir path --input='/path/to/image_dir'"`
+	Input          string `cli:"*i,input" usage:"image dir, archive file or S3 path --input='/path/to/image_dir' or --input='s3://bucket/prefix'"`
 	Output         string `cli:"*o,output" usage:"output CSV file path --output='./list.csv'" dft:"./list.csv"`
 	IncludeAllType bool   `cli:"a,all" usage:"use all files"`
 	Type           string `cli:"t,type" usage:"comma separate file extensions --type='jpg,jpeg,png,gif'" dft:"jpg,jpeg,png,gif"`
 	PathPrefix     string `cli:"d,prefix" usage:"prefix for file path --prefix='/tmp'" dft:""`
+	Sniff          bool   `cli:"sniff" usage:"check actual image format from file header instead of file extension"`
+	Validate       bool   `cli:"validate" usage:"decode whole image to skip broken files"`
+	Meta           bool   `cli:"m,meta" usage:"add metadata columns [width,height,format,size,sha256,mtime]"`
+	Include        string `cli:"include" usage:"comma separate glob patterns of files to include --include='*.jpg,train/*/*'"`
+	Exclude        string `cli:"exclude" usage:"comma separate glob patterns of files and dirs to exclude --exclude='_annotated_*'"`
+	MinSize        string `cli:"min-size" usage:"minimum file size --min-size='10KB'"`
+	MaxSize        string `cli:"max-size" usage:"maximum file size --max-size='20MB'"`
+	MinWidth       int    `cli:"min-width" usage:"minimum image width in pixels --min-width=100" dft:"0"`
+	MaxWidth       int    `cli:"max-width" usage:"maximum image width in pixels --max-width=4000" dft:"0"`
+	MinHeight      int    `cli:"min-height" usage:"minimum image height in pixels --min-height=100" dft:"0"`
+	MaxHeight      int    `cli:"max-height" usage:"maximum image height in pixels --max-height=4000" dft:"0"`
+	MaxDepth       int    `cli:"max-depth" usage:"max depth of directory (0 means no limit) --max-depth=1" dft:"0"`
+	FollowSymlink  bool   `cli:"follow-symlink" usage:"follow symbolic links of directories"`
+	Archive        bool   `cli:"archive" usage:"list images inside zip and tar files in --input dir"`
+	Sample         string `cli:"sample" usage:"number or percentage of files to sample randomly --sample=100 or --sample='10%'"`
+	Seed           int64  `cli:"seed" usage:"random seed for sampling and shuffling --seed=42" dft:"0"`
+	Stratify       bool   `cli:"stratify" usage:"sample files from each subdirectory in proportion to its size"`
+	Shuffle        bool   `cli:"shuffle" usage:"shuffle the order of files by --seed"`
+	Shards         int    `cli:"shards" usage:"split output into the number of CSV files by hash of path --shards=4" dft:"0"`
+	Concurrency    int    `cli:"c,concurrency" usage:"number of directories read concurrently --concurrency=8" dft:"8"`
 }
 
 var list = &cli.Command{
@@ -30,44 +52,227 @@ var list = &cli.Command{
 func execList(ctx *cli.Context) error {
 	argv := ctx.Argv().(*listT)
 
-	f, err := NewFileHandler(argv.Output)
+	opt, err := newWalkOption(argv)
 	if err != nil {
 		return err
 	}
+	opt.OnError = func(path string, err error) {
+		fmt.Printf("[WARN] skipped path:%s\terr:%s\n", path, err.Error())
+	}
+
+	count, percent, err := parseSampleSize(argv.Sample)
+	if err != nil {
+		return err
+	}
+	sampler := listSampler{
+		Seed:     argv.Seed,
+		Count:    count,
+		Percent:  percent,
+		Stratify: argv.Stratify,
+		Shuffle:  argv.Shuffle,
+	}
 
-	types := newFileType(strings.Split(argv.Type, ","))
+	l := fileLister{
+		pathPrefix: argv.PathPrefix,
+		types:      newFileType(strings.Split(argv.Type, ",")),
+		sniff:      argv.Sniff,
+		validate:   argv.Validate,
+		meta:       argv.Meta,
+	}
 	if argv.IncludeAllType {
-		types.setIncludeAll(argv.IncludeAllType)
+		l.types.setIncludeAll(true)
+	}
+
+	out, err := newListOutput(argv.Output, argv.Shards, l.Header())
+	if err != nil {
+		return err
+	}
+
+	dir := argv.Input
+	if !isS3Path(dir) {
+		dir = filepath.Clean(dir)
+	}
+	if !sampler.isActive() {
+		// stream the paths into the output as discovered.
+		err = WalkFiles(dir, opt, func(file string) {
+			if row, ok := l.Row(file); ok {
+				out.Write(row)
+			}
+		})
+		if err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
 	}
 
-	pathPrefix = argv.PathPrefix
-	baseDir = fmt.Sprintf("%s/", filepath.Clean(argv.Input))
-	result := getFilesFromDir(baseDir, types)
-	result = append([]string{"path"}, result...)
-	return f.WriteAll(result)
+	// sampling needs the whole files in the fixed order.
+	var mu sync.Mutex
+	var targets []string
+	err = WalkFiles(dir, opt, func(file string) {
+		if !l.IsTarget(file) {
+			return
+		}
+		mu.Lock()
+		targets = append(targets, file)
+		mu.Unlock()
+	})
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	sort.Strings(targets)
+	for _, file := range sampler.Apply(dir, targets) {
+		if row, ok := l.RowWithoutCheck(file); ok {
+			out.Write(row)
+		}
+	}
+	return out.Close()
 }
 
-func getFilesFromDir(dir string, types fileType) []string {
-	files, err := ioutil.ReadDir(dir)
+func newWalkOption(argv *listT) (WalkOption, error) {
+	minSize, err := parseByteSize(argv.MinSize)
+	if err != nil {
+		return WalkOption{}, err
+	}
+	maxSize, err := parseByteSize(argv.MaxSize)
 	if err != nil {
-		panic(err)
+		return WalkOption{}, err
 	}
 
-	var paths []string
-	for _, file := range files {
-		fileName := file.Name()
-		if file.IsDir() {
-			paths = append(paths, getFilesFromDir(filepath.Join(dir, fileName), types)...)
-			continue
+	opt := WalkOption{
+		Include:       splitPatterns(argv.Include),
+		Exclude:       splitPatterns(argv.Exclude),
+		MinSize:       minSize,
+		MaxSize:       maxSize,
+		MinWidth:      argv.MinWidth,
+		MaxWidth:      argv.MaxWidth,
+		MinHeight:     argv.MinHeight,
+		MaxHeight:     argv.MaxHeight,
+		MaxDepth:      argv.MaxDepth,
+		FollowSymlink: argv.FollowSymlink,
+		Archive:       argv.Archive,
+		S3Endpoint:    os.Getenv(keyConfigS3Endpoint),
+		Concurrency:   argv.Concurrency,
+	}
+	// the extensions are checked by the content later when sniffing.
+	if !argv.Sniff && !argv.IncludeAllType {
+		opt.Types = strings.Split(argv.Type, ",")
+	}
+	return opt, nil
+}
+
+// fileLister checks the found files and creates the rows of list CSV.
+type fileLister struct {
+	pathPrefix string
+	types      fileType
+	sniff      bool
+	validate   bool
+	meta       bool
+}
+
+func (l fileLister) Header() []string {
+	header := []string{colPath}
+	if l.meta {
+		header = append(header, imageMetaHeader...)
+	}
+	return header
+}
+
+// IsTarget checks the actual image format and validates the image.
+func (l fileLister) IsTarget(file string) bool {
+	if l.sniff {
+		_, format, err := sniffImage(file)
+		if err != nil {
+			fmt.Printf("[WARN] skipped path:%s\terr:%s\n", file, err.Error())
+			return false
+		}
+		if !l.types.isTargetFormat(format) {
+			return false
 		}
+	}
+	if l.validate {
+		if err := validateImage(file); err != nil {
+			fmt.Printf("[WARN] skipped path:%s\terr:%s\n", file, err.Error())
+			return false
+		}
+	}
+	return true
+}
 
-		if !types.isTarget(fileName) {
-			continue
+// Row returns the row of the file when the file is target.
+func (l fileLister) Row(file string) ([]string, bool) {
+	if !l.IsTarget(file) {
+		return nil, false
+	}
+	return l.RowWithoutCheck(file)
+}
+
+// RowWithoutCheck returns the row of the file.
+func (l fileLister) RowWithoutCheck(file string) ([]string, bool) {
+	row := []string{file}
+	if !isS3Path(file) {
+		row[0] = path.Join(l.pathPrefix, filepath.ToSlash(file))
+	}
+	if !l.meta {
+		return row, true
+	}
+
+	meta, err := readImageMeta(file)
+	if err != nil {
+		fmt.Printf("[WARN] skipped path:%s\terr:%s\n", file, err.Error())
+		return nil, false
+	}
+	return append(row, meta.Columns()...), true
+}
+
+// listOutput writes the rows into CSV file, or the shard files by hash of path.
+type listOutput struct {
+	writers []*CSVWriter
+}
+
+func newListOutput(output string, shards int, header []string) (*listOutput, error) {
+	files := []string{output}
+	if shards > 1 {
+		files = make([]string, shards)
+		for i := range files {
+			files[i] = getShardPath(output, i)
 		}
+	}
 
-		path := path.Join(pathPrefix, dir, fileName)
-		paths = append(paths, path)
+	o := &listOutput{}
+	for _, file := range files {
+		w, err := NewCSVWriter(file)
+		if err != nil {
+			o.Close()
+			return nil, err
+		}
+		o.writers = append(o.writers, w)
+		if err := w.Write(header); err != nil {
+			o.Close()
+			return nil, err
+		}
 	}
+	return o, nil
+}
 
-	return paths
+func (o *listOutput) Write(row []string) {
+	w := o.writers[0]
+	if len(o.writers) > 1 {
+		w = o.writers[getShardIndex(row[0], len(o.writers))]
+	}
+	if err := w.Write(row); err != nil {
+		fmt.Printf("[ERROR] path:%s\terr:%s\n", row[0], err.Error())
+	}
+}
+
+func (o *listOutput) Close() error {
+	var firstErr error
+	for _, w := range o.writers {
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
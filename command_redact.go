@@ -0,0 +1,369 @@
+package fda
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mkideal/cli"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+const (
+	redactMethodBlur     = "blur"
+	redactMethodPixelate = "pixelate"
+	redactMethodFill     = "fill"
+
+	redactSourceFused = "fused"
+	redactSourceUnion = "union"
+
+	// status of each image in redaction log.
+	redactStatusRedacted = "redacted"
+	redactStatusNoFaces  = "no_faces"
+	redactStatusSkipped  = "skipped"
+	// the face region is entirely outside the image.
+	redactStatusOutOfBounds = "out_of_bounds"
+)
+
+// redact command
+type redactT struct {
+	cli.Helper
+	Input    string  `cli:"*i,input" usage:"detector's output tsv file --input='/path/to/output.tsv'"`
+	Output   string  `cli:"*o,output" usage:"output directory path of redacted images --output='./redact'" dft:"./redact"`
+	Engines  string  `cli:"e,engine" usage:"comma separate engine names to use faces (empty means all engines) --engine='pigo,google'"`
+	Fuse     bool    `cli:"fuse" usage:"use fused faces of the engines instead of the union of them"`
+	MinVotes int     `cli:"min-votes" usage:"minimum number of engines to use fused face --min-votes=2" dft:"1"`
+	IoU      float64 `cli:"iou" usage:"IoU threshold to fuse faces --iou=0.3" dft:"0.3"`
+	Method   string  `cli:"m,method" usage:"redaction method [blur,pixelate,fill] --method='blur'" dft:"blur"`
+	Expand   float64 `cli:"expand" usage:"expand ratio of face size added to each side --expand=0.1" dft:"0.1"`
+	Strength int     `cli:"strength" usage:"blur radius or pixel block size (0 means auto) --strength=16" dft:"0"`
+	Color    string  `cli:"color" usage:"opaque fill color in hex --color='000000'" dft:"000000"`
+}
+
+var redactor = &cli.Command{
+	Name: "redact",
+	Desc: "Redact faces of image from --input TSV file",
+	Argv: func() interface{} { return new(redactT) },
+	Fn:   execRedact,
+}
+
+func execRedact(ctx *cli.Context) error {
+	argv := ctx.Argv().(*redactT)
+
+	result, err := readDetectResult(argv.Input)
+	if err != nil {
+		return err
+	}
+
+	engines := result.Engines
+	if argv.Engines != "" {
+		engines = strings.Split(argv.Engines, ",")
+		for _, e := range engines {
+			if !hasEngine(result.Engines, e) {
+				return fmt.Errorf("engine '%s' is not found in %+v", e, result.Engines)
+			}
+		}
+	}
+	fmt.Printf("engines:%+v\n", engines)
+
+	fill, err := parseHexColor(argv.Color)
+	if err != nil {
+		return err
+	}
+	// translucent fill does not obscure the face.
+	if fill.A != 0xff {
+		return fmt.Errorf("fill color must be opaque: [%s]", argv.Color)
+	}
+	r := faceRedactor{
+		method:   argv.Method,
+		expand:   argv.Expand,
+		strength: argv.Strength,
+		color:    fill,
+	}
+	switch r.method {
+	case redactMethodBlur, redactMethodPixelate, redactMethodFill:
+	default:
+		return fmt.Errorf("unknown method: [%s]", r.method)
+	}
+
+	if err := os.MkdirAll(argv.Output, 0755); err != nil {
+		return err
+	}
+
+	source := redactSourceUnion
+	if argv.Fuse {
+		source = redactSourceFused
+	}
+	if len(engines) == 1 {
+		source = engines[0]
+	}
+
+	// a row per face of the redacted images, and a row per image without redacted faces.
+	auditLog := [][]string{{"path", "output", "status", "error", "source", "engines", "index", "x", "y", "width", "height", "method"}}
+	redacted := 0
+	for i, row := range result.Rows {
+		faces, err := getRedactFaces(row, engines, argv.Fuse, argv.IoU, argv.MinVotes)
+		if err != nil {
+			fmt.Printf("[ERROR] skipped path:%s\terr:%s\n", row.Path, err.Error())
+			auditLog = append(auditLog, newRedactSkippedLog(row.Path, source, r.method, err))
+			continue
+		}
+
+		output := filepath.Join(argv.Output, getRedactedFileName(i+1, row.Path))
+		regions, err := r.Redact(row.Path, output, faces)
+		if err != nil {
+			fmt.Printf("[ERROR] skipped path:%s\terr:%s\n", row.Path, err.Error())
+			auditLog = append(auditLog, newRedactSkippedLog(row.Path, source, r.method, err))
+			continue
+		}
+
+		if len(regions) == 0 {
+			auditLog = append(auditLog, []string{row.Path, output, redactStatusNoFaces, "", source, "", "", "", "", "", "", r.method})
+			continue
+		}
+		for j, rect := range regions {
+			status := redactStatusRedacted
+			if rect.Empty() {
+				fmt.Printf("[WARN] face is out of image path:%s\tface:%s\n", row.Path, faces[j].FaceData.String())
+				status = redactStatusOutOfBounds
+				rect = faceRect(faces[j].FaceData)
+			} else {
+				redacted++
+			}
+			auditLog = append(auditLog, []string{
+				row.Path,
+				output,
+				status,
+				"",
+				source,
+				strings.Join(faces[j].Engines, "|"),
+				strconv.Itoa(j),
+				strconv.Itoa(rect.Min.X),
+				strconv.Itoa(rect.Min.Y),
+				strconv.Itoa(rect.Dx()),
+				strconv.Itoa(rect.Dy()),
+				r.method,
+			})
+		}
+	}
+
+	w, err := NewFileHandler(filepath.Join(argv.Output, "redaction_log.csv"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[INFO] redacted faces: %d\n", redacted)
+	return w.WriteCSV(auditLog)
+}
+
+func newRedactSkippedLog(path, source, method string, err error) []string {
+	errMsg := strings.Join(strings.Fields(err.Error()), " ")
+	return []string{path, "", redactStatusSkipped, errMsg, source, "", "", "", "", "", "", method}
+}
+
+// getRedactFaces returns the faces to redact.
+// It returns error when any of the engines does not have the result, to avoid leaking the faces.
+func getRedactFaces(row detectResultRow, engines []string, fuse bool, iou float64, minVotes int) ([]fusedFace, error) {
+	results := make([]engine.FaceResult, 0, len(engines))
+	for _, e := range engines {
+		data, ok := row.Results[e]
+		if !ok {
+			return nil, fmt.Errorf("result of engine '%s' is missing", e)
+		}
+		data.EngineName = e
+		results = append(results, data)
+	}
+
+	if fuse {
+		return fuseFaces(results, iou, minVotes), nil
+	}
+
+	var faces []fusedFace
+	for _, r := range results {
+		for _, f := range r.Faces {
+			faces = append(faces, fusedFace{
+				FaceData: f,
+				Engines:  []string{r.EngineName},
+				Members:  []engine.FaceData{f},
+			})
+		}
+	}
+	return faces, nil
+}
+
+func getRedactedFileName(number int, path string) string {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	if strings.ToLower(ext) != ".png" {
+		base = strings.TrimSuffix(base, ext) + ".jpg"
+	}
+	return fmt.Sprintf("%06d_%s", number, base)
+}
+
+// faceRedactor obscures the face regions of image.
+type faceRedactor struct {
+	method   string
+	expand   float64
+	strength int
+	color    color.Color
+}
+
+// Redact saves the redacted image and returns the redacted regions clipped to the image bounds.
+// The region is empty when the face is entirely outside the image.
+func (r faceRedactor) Redact(path, output string, faces []fusedFace) ([]image.Rectangle, error) {
+	src, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	img := toRGBA(src)
+	regions := make([]image.Rectangle, len(faces))
+	for i, f := range faces {
+		rect := expandRect(faceRect(f.FaceData), r.expand).Add(img.Bounds().Min).Intersect(img.Bounds())
+		regions[i] = rect
+		if rect.Empty() {
+			continue
+		}
+
+		switch r.method {
+		case redactMethodFill:
+			draw.Draw(img, rect, image.NewUniform(r.color), image.ZP, draw.Over)
+		case redactMethodPixelate:
+			pixelateRect(img, rect, r.getStrength(rect, 8))
+		default:
+			blurRect(img, rect, r.getStrength(rect, 4))
+		}
+	}
+
+	if err := saveImage(output, img); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+// getStrength returns the strength, or the size relative to the region when the strength is not set.
+func (r faceRedactor) getStrength(rect image.Rectangle, div int) int {
+	if r.strength > 0 {
+		return r.strength
+	}
+
+	size := rect.Dx()
+	if rect.Dy() > size {
+		size = rect.Dy()
+	}
+	if s := size / div; s > 1 {
+		return s
+	}
+	return 2
+}
+
+// pixelateRect fills each block of the region with the average color.
+func pixelateRect(img *image.RGBA, rect image.Rectangle, block int) {
+	for y := rect.Min.Y; y < rect.Max.Y; y += block {
+		for x := rect.Min.X; x < rect.Max.X; x += block {
+			b := image.Rect(x, y, x+block, y+block).Intersect(rect)
+
+			var sumR, sumG, sumB, sumA, n int
+			for by := b.Min.Y; by < b.Max.Y; by++ {
+				for bx := b.Min.X; bx < b.Max.X; bx++ {
+					c := img.RGBAAt(bx, by)
+					sumR += int(c.R)
+					sumG += int(c.G)
+					sumB += int(c.B)
+					sumA += int(c.A)
+					n++
+				}
+			}
+			avg := color.RGBA{uint8(sumR / n), uint8(sumG / n), uint8(sumB / n), uint8(sumA / n)}
+			draw.Draw(img, b, image.NewUniform(avg), image.ZP, draw.Src)
+		}
+	}
+}
+
+// blurRect blurs the region by three passes of box blur, which approximates gaussian blur.
+func blurRect(img *image.RGBA, rect image.Rectangle, radius int) {
+	for i := 0; i < 3; i++ {
+		boxBlur(img, rect, radius, true)
+		boxBlur(img, rect, radius, false)
+	}
+}
+
+func boxBlur(img *image.RGBA, rect image.Rectangle, radius int, horizontal bool) {
+	outer, inner := rect.Min.Y, rect.Max.Y
+	from, to := rect.Min.X, rect.Max.X
+	if !horizontal {
+		outer, inner = rect.Min.X, rect.Max.X
+		from, to = rect.Min.Y, rect.Max.Y
+	}
+	at := func(o, i int) int {
+		if horizontal {
+			return img.PixOffset(i, o)
+		}
+		return img.PixOffset(o, i)
+	}
+
+	line := make([]uint8, (to-from)*4)
+	for o := outer; o < inner; o++ {
+		// sliding window of [i-radius, i+radius]
+		var sum [4]int
+		n := 0
+		for k := from; k < to && k <= from+radius; k++ {
+			p := at(o, k)
+			for c := 0; c < 4; c++ {
+				sum[c] += int(img.Pix[p+c])
+			}
+			n++
+		}
+
+		for i := from; i < to; i++ {
+			for c := 0; c < 4; c++ {
+				line[(i-from)*4+c] = uint8(sum[c] / n)
+			}
+
+			if k := i - radius; k >= from {
+				p := at(o, k)
+				for c := 0; c < 4; c++ {
+					sum[c] -= int(img.Pix[p+c])
+				}
+				n--
+			}
+			if k := i + radius + 1; k < to {
+				p := at(o, k)
+				for c := 0; c < 4; c++ {
+					sum[c] += int(img.Pix[p+c])
+				}
+				n++
+			}
+		}
+		for i := from; i < to; i++ {
+			copy(img.Pix[at(o, i):at(o, i)+4], line[(i-from)*4:(i-from)*4+4])
+		}
+	}
+}
+
+func parseHexColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 && len(s) != 8 {
+		return color.NRGBA{}, errors.New("color must be hex format like 'ff0000' or 'ff000080'")
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+	if len(s) == 6 {
+		v = v<<8 | 0xff
+	}
+	return color.NRGBA{
+		R: uint8(v >> 24),
+		G: uint8(v >> 16),
+		B: uint8(v >> 8),
+		A: uint8(v),
+	}, nil
+}
@@ -0,0 +1,39 @@
+package fda
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+func TestFaceRedactorRedactBounds(t *testing.T) {
+	dir, cleanup := newTestTempDir(t)
+	defer cleanup()
+	input := filepath.Join(dir, "input.png")
+	if err := ioutil.WriteFile(input, newTestPNG(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	faces := []fusedFace{
+		{FaceData: engine.FaceData{X: -1, Y: -1, Width: 3, Height: 3}},
+		{FaceData: engine.FaceData{X: 10, Y: 10, Width: 2, Height: 2}},
+	}
+	r := faceRedactor{method: redactMethodFill, color: color.Black}
+	regions, err := r.Redact(input, filepath.Join(dir, "output.png"), faces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != len(faces) {
+		t.Fatalf("regions = %d, want %d", len(regions), len(faces))
+	}
+	if want := image.Rect(0, 0, 2, 2); regions[0] != want {
+		t.Errorf("clipped region = %v, want %v", regions[0], want)
+	}
+	if !regions[1].Empty() {
+		t.Errorf("region outside of image = %v, want empty", regions[1])
+	}
+}
@@ -0,0 +1,383 @@
+package fda
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mkideal/cli"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// report command
+type reportT struct {
+	cli.Helper
+	Input   string `cli:"*i,input" usage:"detector's output tsv file --input='/path/to/output.tsv'"`
+	Output  string `cli:"*o,output" usage:"output directory path of HTML report --output='./report'" dft:"./report"`
+	PerPage int    `cli:"n,per-page" usage:"number of images in a gallery page --per-page=50" dft:"50"`
+}
+
+var reporter = &cli.Command{
+	Name: "report",
+	Desc: "Create HTML comparison report from --input TSV file",
+	Argv: func() interface{} { return new(reportT) },
+	Fn:   execReport,
+}
+
+func execReport(ctx *cli.Context) error {
+	argv := ctx.Argv().(*reportT)
+
+	result, err := readDetectResult(argv.Input)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("engines:%+v\n", result.Engines)
+
+	r := newHTMLReport(argv.Output, argv.PerPage)
+	return r.Write(result)
+}
+
+// htmlReport creates a static HTML site from detector's output.
+type htmlReport struct {
+	dir     string
+	perPage int
+}
+
+func newHTMLReport(dir string, perPage int) htmlReport {
+	if perPage < 1 {
+		perPage = 50
+	}
+	return htmlReport{
+		dir:     dir,
+		perPage: perPage,
+	}
+}
+
+func (r htmlReport) Write(result *detectResult) error {
+	imgDir := filepath.Join(r.dir, "images")
+	if err := os.MkdirAll(imgDir, 0755); err != nil {
+		return err
+	}
+
+	items := make([]reportItem, len(result.Rows))
+	for i, row := range result.Rows {
+		items[i] = r.newItem(i, row, result.Engines)
+	}
+
+	pageSize := (len(items) + r.perPage - 1) / r.perPage
+	pages := make([]reportPageLink, pageSize)
+	for i := range pages {
+		pages[i] = reportPageLink{
+			Number: i + 1,
+			File:   reportPageFile(i + 1),
+		}
+	}
+
+	engines := make([]reportEngine, len(result.Engines))
+	for i, e := range result.Engines {
+		engines[i] = reportEngine{
+			Index: i,
+			Name:  e,
+			Color: reportColor(i),
+		}
+	}
+
+	index := reportIndex{
+		Title:     "Face Detection Report",
+		Engines:   engines,
+		Summary:   newReportSummary(result),
+		Agreement: newReportAgreement(result),
+		Pages:     pages,
+		Total:     len(items),
+	}
+	if err := r.writeTemplate("index.html", reportIndexTemplate, index); err != nil {
+		return err
+	}
+
+	for i := range pages {
+		from := i * r.perPage
+		to := from + r.perPage
+		if to > len(items) {
+			to = len(items)
+		}
+
+		page := reportPage{
+			Title:   fmt.Sprintf("Gallery %d/%d", i+1, pageSize),
+			Engines: engines,
+			Items:   items[from:to],
+			Pages:   pages,
+			Current: i + 1,
+		}
+		if err := r.writeTemplate(pages[i].File, reportPageTemplate, page); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("[INFO] report: %s\n", filepath.Join(r.dir, "index.html"))
+	return nil
+}
+
+func (r htmlReport) newItem(i int, row detectResultRow, engines []string) reportItem {
+	item := reportItem{
+		Number: i + 1,
+		Path:   row.Path,
+		Count:  row.Count,
+	}
+	if row.Error != "" {
+		item.Error = row.Error
+		return item
+	}
+
+	conf, _, err := sniffImage(row.Path)
+	if err != nil {
+		fmt.Printf("[ERROR] path:%s\terr:%s\n", row.Path, err.Error())
+		item.Error = err.Error()
+		return item
+	}
+
+	src := filepath.Join("images", fmt.Sprintf("%06d%s", i+1, strings.ToLower(filepath.Ext(row.Path))))
+	if err := copyFile(row.Path, filepath.Join(r.dir, src)); err != nil {
+		fmt.Printf("[ERROR] path:%s\terr:%s\n", row.Path, err.Error())
+		item.Error = err.Error()
+		return item
+	}
+
+	item.Src = filepath.ToSlash(src)
+	item.Width = conf.Width
+	item.Height = conf.Height
+	for j, e := range engines {
+		data, ok := row.Results[e]
+		o := reportOverlay{
+			Index:  j,
+			Engine: e,
+			Color:  reportColor(j),
+			Failed: !ok,
+		}
+		for _, f := range data.Faces {
+			o.Faces = append(o.Faces, reportFace{
+				FaceData: f,
+				Label:    reportFaceLabel(e, f),
+			})
+		}
+		item.Overlays = append(item.Overlays, o)
+	}
+	return item
+}
+
+func (r htmlReport) writeTemplate(name string, tmpl *template.Template, data interface{}) error {
+	fp, err := os.Create(filepath.Join(r.dir, name))
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	if err := tmpl.Execute(fp, data); err != nil {
+		return err
+	}
+	return fp.Sync()
+}
+
+func reportPageFile(n int) string {
+	return fmt.Sprintf("page-%04d.html", n)
+}
+
+func reportFaceLabel(engineName string, f engine.FaceData) string {
+	label := engineName
+	if f.Confidence > 0 {
+		label += fmt.Sprintf(" [%s]", strconv.FormatFloat(f.Confidence, 'f', 2, 64))
+	}
+	return label
+}
+
+var reportColors = []string{
+	"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#42d4f4", "#f032e6", "#bfef45", "#469990", "#9a6324",
+}
+
+func reportColor(i int) string {
+	return reportColors[i%len(reportColors)]
+}
+
+func copyFile(src, dst string) error {
+	in, err := openImageFile(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+type reportIndex struct {
+	Title     string
+	Engines   []reportEngine
+	Summary   []reportSummary
+	Agreement reportAgreement
+	Pages     []reportPageLink
+	Total     int
+}
+
+type reportPage struct {
+	Title   string
+	Engines []reportEngine
+	Items   []reportItem
+	Pages   []reportPageLink
+	Current int
+}
+
+type reportPageLink struct {
+	Number int
+	File   string
+}
+
+type reportEngine struct {
+	Index int
+	Name  string
+	Color string
+}
+
+type reportItem struct {
+	Number   int
+	Path     string
+	Count    string
+	Src      string
+	Width    int
+	Height   int
+	Error    string
+	Overlays []reportOverlay
+}
+
+type reportOverlay struct {
+	Index  int
+	Engine string
+	Color  string
+	Failed bool
+	Faces  []reportFace
+}
+
+type reportFace struct {
+	engine.FaceData
+	Label string
+}
+
+// reportSummary is a summary of an engine.
+type reportSummary struct {
+	Engine           string
+	Images           int
+	Errors           int
+	Faces            int
+	ImagesWithFaces  int
+	ExpectedImages   int
+	ExpectedMatched  int
+	LatencyCount     int
+	LatencyTotalMsec float64
+}
+
+func newReportSummary(result *detectResult) []reportSummary {
+	list := make([]reportSummary, len(result.Engines))
+	for i, e := range result.Engines {
+		s := reportSummary{Engine: e}
+		for _, row := range result.Rows {
+			if msec, err := strconv.ParseFloat(row.Line[e+colSuffixLatency], 64); err == nil {
+				s.LatencyCount++
+				s.LatencyTotalMsec += msec
+			}
+
+			data, ok := row.Results[e]
+			if !ok {
+				s.Errors++
+				continue
+			}
+			s.Images++
+			s.Faces += len(data.Faces)
+			if data.HasFaces() {
+				s.ImagesWithFaces++
+			}
+			if n, ok := row.ExpectedCount(); ok {
+				s.ExpectedImages++
+				if n == len(data.Faces) {
+					s.ExpectedMatched++
+				}
+			}
+		}
+		list[i] = s
+	}
+	return list
+}
+
+func (s reportSummary) FacesPerImage() string {
+	if s.Images == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(s.Faces)/float64(s.Images), 'f', 2, 64)
+}
+
+func (s reportSummary) ExpectedRate() string {
+	if s.ExpectedImages == 0 {
+		return "-"
+	}
+	return formatPercent(s.ExpectedMatched, s.ExpectedImages)
+}
+
+func (s reportSummary) MeanLatency() string {
+	if s.LatencyCount == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(s.LatencyTotalMsec/float64(s.LatencyCount), 'f', 1, 64) + " ms"
+}
+
+// reportAgreement is the rates of the same face count between engines.
+type reportAgreement struct {
+	Engines []string
+	Rows    []reportAgreementRow
+}
+
+type reportAgreementRow struct {
+	Engine string
+	Rates  []string
+}
+
+func newReportAgreement(result *detectResult) reportAgreement {
+	engines := result.Engines
+	rows := make([]reportAgreementRow, len(engines))
+	for i, a := range engines {
+		rates := make([]string, len(engines))
+		for j, b := range engines {
+			if i == j {
+				rates[j] = "-"
+				continue
+			}
+
+			ag := newCountAgreement(result.Rows, a, b)
+			rates[j] = formatPercent(ag.Matched, ag.Images)
+		}
+		rows[i] = reportAgreementRow{
+			Engine: a,
+			Rates:  rates,
+		}
+	}
+	return reportAgreement{
+		Engines: engines,
+		Rows:    rows,
+	}
+}
+
+func formatPercent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(n)*100/float64(total), 'f', 1, 64) + "%"
+}
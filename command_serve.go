@@ -0,0 +1,366 @@
+package fda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mkideal/cli"
+	"github.com/pkg/errors"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// serve command
+type serveT struct {
+	cli.Helper
+	Addr             string `cli:"addr" usage:"address to listen --addr=':8080'" dft:":8080"`
+	UseAllEngine     bool   `cli:"a,all" usage:"use all engines"`
+	Engines          string `cli:"e,engine" usage:"comma separate Face Detect Engines --engine='opencv,dlib,pigo,tensorflow,rekognition,google,azure,face++'" dft:"opencv,dlib,pigo,tensorflow"`
+	MaxUploadSize    string `cli:"max-upload-size" usage:"max size of request body --max-upload-size='20MB'" dft:"20MB"`
+	FetchConcurrency int    `cli:"fetch-concurrency" usage:"max number of concurrent downloads of URL --fetch-concurrency=4" dft:"4"`
+	FetchTimeout     int    `cli:"fetch-timeout" usage:"timeout of downloading URL in seconds --fetch-timeout=30" dft:"30"`
+	MaxDownloadSize  string `cli:"max-download-size" usage:"max file size of downloading URL --max-download-size='20MB'" dft:"20MB"`
+	AllowPrivateURL  bool   `cli:"allow-private-url" usage:"allow downloading URL of private, loopback and link-local addresses"`
+	ReadTimeout      int    `cli:"read-timeout" usage:"seconds to read the request including the body --read-timeout=60" dft:"60"`
+	IdleTimeout      int    `cli:"idle-timeout" usage:"seconds to keep the idle connection --idle-timeout=120" dft:"120"`
+	ShutdownTimeout  int    `cli:"shutdown-timeout" usage:"seconds to wait the requests in progress on shutdown --shutdown-timeout=30" dft:"30"`
+}
+
+// max time to read the request header, not to be occupied by slow clients.
+const serveReadHeaderTimeout = 10 * time.Second
+
+var server = &cli.Command{
+	Name: "serve",
+	Desc: "Serve HTTP API to detect faces",
+	Argv: func() interface{} { return new(serveT) },
+	Fn:   execServe,
+}
+
+func execServe(ctx *cli.Context) error {
+	argv := ctx.Argv().(*serveT)
+	conf := NewConfig(argv.UseAllEngine)
+	for _, e := range strings.Split(argv.Engines, ",") {
+		if err := conf.setUseEngineFromName(e); err != nil {
+			return errors.Wrap(err, "[ERROR] setUseEngineFromName")
+		}
+	}
+
+	maxUploadSize, err := parseByteSize(argv.MaxUploadSize)
+	if err != nil {
+		return err
+	}
+	maxDownloadSize, err := parseByteSize(argv.MaxDownloadSize)
+	if err != nil {
+		return err
+	}
+	fetcher := newURLFetcher(argv.FetchConcurrency, time.Duration(argv.FetchTimeout)*time.Second, maxDownloadSize, "")
+	if !argv.AllowPrivateURL {
+		fetcher.denyPrivateNetwork()
+	}
+
+	s := newDetectServer(fetcher, maxUploadSize)
+	srv := newHTTPServer(argv.Addr, s.Handler(), time.Duration(argv.ReadTimeout)*time.Second, time.Duration(argv.IdleTimeout)*time.Second)
+
+	errCh := make(chan error, 2)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	fmt.Printf("[INFO] listening: %s\n", argv.Addr)
+
+	// initialize the engines after listening, to respond to the health check during the initialization.
+	go func() {
+		engines, err := initEngines(conf, enabledEngines)
+		if err != nil {
+			errCh <- errors.Wrap(err, "[ERROR] initEngines")
+			return
+		}
+		s.setEngines(engines)
+		fmt.Printf("[INFO] ready\n")
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	select {
+	case err := <-errCh:
+		srv.Close()
+		return err
+	case <-sig:
+	}
+
+	fmt.Printf("[INFO] shutting down...\n")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(argv.ShutdownTimeout)*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// newHTTPServer returns the server with the timeouts, to avoid exhausting the connections by slow clients.
+// The write timeout is not set since the detection by cloud engines may take long.
+func newHTTPServer(addr string, h http.Handler, readTimeout, idleTimeout time.Duration) *http.Server {
+	readHeaderTimeout := serveReadHeaderTimeout
+	if readTimeout > 0 && readTimeout < readHeaderTimeout {
+		readHeaderTimeout = readTimeout
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
+
+// detectServer handles HTTP API requests by the initialized engines.
+type detectServer struct {
+	fetcher       *urlFetcher
+	maxUploadSize int64
+	style         *annotateStyle
+
+	mu sync.RWMutex
+	// nil means the engines are not initialized yet.
+	engines []engine.Engine
+}
+
+func newDetectServer(fetcher *urlFetcher, maxUploadSize int64) *detectServer {
+	return &detectServer{
+		fetcher:       fetcher,
+		maxUploadSize: maxUploadSize,
+		style:         newAnnotateStyle(),
+	}
+}
+
+func (s *detectServer) setEngines(engines []engine.Engine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.engines = engines
+}
+
+func (s *detectServer) getEngines() []engine.Engine {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.engines
+}
+
+func (s *detectServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReady)
+	mux.HandleFunc("/engines", s.handleEngines)
+	mux.HandleFunc("/detect", s.handleDetect)
+	mux.HandleFunc("/annotate", s.handleAnnotate)
+	return mux
+}
+
+// handleHealth responds while the process is running.
+func (s *detectServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady responds OK after the engines are initialized.
+func (s *detectServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.getEngines() == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "initializing"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+func (s *detectServer) handleEngines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	engines := s.getEngines()
+	names := make([]string, len(engines))
+	for i, e := range engines {
+		names[i] = e.String()
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"engines": names})
+}
+
+// detectResponse is the response of /detect.
+type detectResponse struct {
+	Results map[string]engine.FaceResult `json:"results"`
+	Errors  map[string]string            `json:"errors,omitempty"`
+}
+
+func (s *detectServer) handleDetect(w http.ResponseWriter, r *http.Request) {
+	engines, in, status, err := s.readRequest(w, r)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	defer in.Close()
+
+	resp, _ := detectInputByEngines(in, engines)
+	if len(resp.Results) == 0 {
+		writeJSON(w, http.StatusInternalServerError, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAnnotate responds the annotated image in JPEG.
+func (s *detectServer) handleAnnotate(w http.ResponseWriter, r *http.Request) {
+	engines, in, status, err := s.readRequest(w, r)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	defer in.Close()
+
+	resp, targets := detectInputByEngines(in, engines)
+	if len(targets) == 0 {
+		writeJSON(w, http.StatusInternalServerError, resp)
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(in.data))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	canvas, err := renderAnnotation(in.Path, img, s.style, targets...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	if err := jpeg.Encode(w, canvas, nil); err != nil {
+		fmt.Printf("[ERROR] annotate path:%s\terr:%s\n", in.Path, err.Error())
+	}
+}
+
+// readRequest returns the engines and the image from the request.
+// The image is uploaded file of 'image' field in multipart form, or 'url' parameter.
+// The engines are comma separated names of 'engine' parameter. (empty means all engines)
+func (s *detectServer) readRequest(w http.ResponseWriter, r *http.Request) ([]engine.Engine, *detectInput, int, error) {
+	if r.Method != http.MethodPost {
+		return nil, nil, http.StatusMethodNotAllowed, errors.New("method not allowed")
+	}
+
+	allEngines := s.getEngines()
+	if allEngines == nil {
+		return nil, nil, http.StatusServiceUnavailable, errors.New("engines are not initialized yet")
+	}
+
+	if s.maxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadSize)
+	}
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = r.ParseMultipartForm(32 << 20)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+
+	engines, err := selectEngines(allEngines, r.FormValue("engine"))
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+
+	if file, header, err := r.FormFile("image"); err == nil {
+		defer file.Close()
+		byt, err := ioutil.ReadAll(file)
+		if err != nil {
+			return nil, nil, http.StatusBadRequest, err
+		}
+		return engines, newDetectInputFromBytes(header.Filename, byt), 0, nil
+	}
+
+	u := r.FormValue("url")
+	switch {
+	case u == "":
+		return nil, nil, http.StatusBadRequest, errors.New("'image' file or 'url' is required")
+	case !isURLPath(u):
+		// do not read local files and S3 objects of the server.
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("url must be http(s): [%s]", u)
+	}
+	in, err := newDetectInput(u, imageReader{fetcher: s.fetcher})
+	if err != nil {
+		return nil, nil, http.StatusBadGateway, err
+	}
+	return engines, in, 0, nil
+}
+
+// selectEngines returns the engines by the comma separated names.
+func selectEngines(engines []engine.Engine, names string) ([]engine.Engine, error) {
+	if names == "" {
+		return engines, nil
+	}
+
+	var list []engine.Engine
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, e := range engines {
+			if e.String() == name {
+				list = append(list, e)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("engine '%s' is not initialized", name)
+		}
+	}
+	return list, nil
+}
+
+// detectInputByEngines detects faces by each engine, and returns the response and the JSON results for annotation.
+func detectInputByEngines(in *detectInput, engines []engine.Engine) (detectResponse, []string) {
+	resp := detectResponse{
+		Results: make(map[string]engine.FaceResult),
+	}
+	var targets []string
+	for _, e := range engines {
+		faceResult, err := in.Detect(e)
+		var byt []byte
+		if err == nil {
+			byt, err = json.Marshal(faceResult)
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] engine:%s\terr:%s\n", e.String(), err.Error())
+			if resp.Errors == nil {
+				resp.Errors = make(map[string]string)
+			}
+			resp.Errors[e.String()] = err.Error()
+			continue
+		}
+		resp.Results[e.String()] = faceResult
+		targets = append(targets, string(byt))
+	}
+	return resp, targets
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("[ERROR] write response err:%s\n", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
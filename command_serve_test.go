@@ -0,0 +1,186 @@
+package fda
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+func newTestDetectServer(t *testing.T, maxUploadSize int64) (*detectServer, *httptest.Server) {
+	t.Helper()
+	fetcher := newURLFetcher(1, time.Second, 0, "")
+	fetcher.denyPrivateNetwork()
+	s := newDetectServer(fetcher, maxUploadSize)
+	return s, httptest.NewServer(s.Handler())
+}
+
+func newUploadRequest(t *testing.T, rawURL string, img []byte, engines string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if engines != "" {
+		if err := mw.WriteField("engine", engines); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fw, err := mw.CreateFormFile("image", "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(img); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rawURL, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func doTestRequest(t *testing.T, req *http.Request) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	return resp, buf.Bytes()
+}
+
+func TestDetectServerReady(t *testing.T) {
+	s, ts := newTestDetectServer(t, 0)
+	defer ts.Close()
+	img := newTestPNG(t)
+
+	for _, path := range []string{"/readyz", "/healthz"} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		resp, _ := doTestRequest(t, req)
+		want := http.StatusServiceUnavailable
+		if path == "/healthz" {
+			want = http.StatusOK
+		}
+		if resp.StatusCode != want {
+			t.Errorf("status of %s before ready = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+	resp, _ := doTestRequest(t, newUploadRequest(t, ts.URL+"/detect", img, ""))
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status of /detect before ready = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	s.setEngines([]engine.Engine{testEngine{}})
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/readyz", nil)
+	if resp, _ := doTestRequest(t, req); resp.StatusCode != http.StatusOK {
+		t.Errorf("status of /readyz after ready = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/engines", nil)
+	resp, body := doTestRequest(t, req)
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != `{"engines":["test"]}` {
+		t.Errorf("/engines = %d %s, want 200 with test engine", resp.StatusCode, body)
+	}
+}
+
+func TestDetectServerDetect(t *testing.T) {
+	s, ts := newTestDetectServer(t, 1<<20)
+	defer ts.Close()
+	s.setEngines([]engine.Engine{testEngine{}})
+	img := newTestPNG(t)
+
+	// the image server is on loopback address, which is denied.
+	imgServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(img)
+	}))
+	defer imgServer.Close()
+	newURLRequest := func(u string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/detect", strings.NewReader(url.Values{"url": {u}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+	getRequest, _ := http.NewRequest(http.MethodGet, ts.URL+"/detect", nil)
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "upload", req: newUploadRequest(t, ts.URL+"/detect", img, ""), wantStatus: http.StatusOK, wantBody: `"test"`},
+		{name: "upload with engine", req: newUploadRequest(t, ts.URL+"/detect", img, "test"), wantStatus: http.StatusOK, wantBody: `"test"`},
+		{name: "unknown engine", req: newUploadRequest(t, ts.URL+"/detect", img, "unknown"), wantStatus: http.StatusBadRequest, wantBody: "not initialized"},
+		{name: "upload over max size", req: newUploadRequest(t, ts.URL+"/detect", bytes.Repeat([]byte("x"), 2<<20), ""), wantStatus: http.StatusBadRequest, wantBody: "too large"},
+		{name: "private url", req: newURLRequest(imgServer.URL + "/a.png"), wantStatus: http.StatusBadGateway, wantBody: "private network"},
+		{name: "local file", req: newURLRequest("/etc/passwd"), wantStatus: http.StatusBadRequest, wantBody: "http(s)"},
+		{name: "no image", req: newURLRequest(""), wantStatus: http.StatusBadRequest, wantBody: "required"},
+		{name: "GET", req: getRequest, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := doTestRequest(t, tt.req)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %s, want to contain %s", body, tt.wantBody)
+			}
+		})
+	}
+
+	resp, body := doTestRequest(t, newUploadRequest(t, ts.URL+"/detect", img, ""))
+	var result detectResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || len(result.Results["test"].Faces) != 1 {
+		t.Errorf("/detect = %d %s, want a face of test engine", resp.StatusCode, body)
+	}
+}
+
+func TestDetectServerAnnotate(t *testing.T) {
+	s, ts := newTestDetectServer(t, 0)
+	defer ts.Close()
+	s.setEngines([]engine.Engine{testEngine{}})
+
+	resp, body := doTestRequest(t, newUploadRequest(t, ts.URL+"/annotate", newTestPNG(t), ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusOK, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("Content-Type = %s, want image/jpeg", ct)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(body)); err != nil {
+		t.Errorf("response is not JPEG: %s", err.Error())
+	}
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	srv := newHTTPServer(":0", http.NotFoundHandler(), time.Minute, 2*time.Minute)
+	if srv.ReadHeaderTimeout != serveReadHeaderTimeout || srv.ReadTimeout != time.Minute || srv.IdleTimeout != 2*time.Minute {
+		t.Errorf("timeouts = %s, %s, %s, want %s, 1m, 2m", srv.ReadHeaderTimeout, srv.ReadTimeout, srv.IdleTimeout, serveReadHeaderTimeout)
+	}
+
+	// the header timeout does not exceed the read timeout.
+	srv = newHTTPServer(":0", http.NotFoundHandler(), time.Second, 0)
+	if srv.ReadHeaderTimeout != time.Second {
+		t.Errorf("ReadHeaderTimeout = %s, want 1s", srv.ReadHeaderTimeout)
+	}
+}
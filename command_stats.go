@@ -0,0 +1,230 @@
+package fda
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mkideal/cli"
+)
+
+// output formats of stats and agreement commands.
+const (
+	tableFormatText     = "text"
+	tableFormatJSON     = "json"
+	tableFormatMarkdown = "markdown"
+)
+
+// stats command
+type statsT struct {
+	cli.Helper
+	Input  string `cli:"*i,input" usage:"detector's output tsv file --input='/path/to/output.tsv'"`
+	Output string `cli:"o,output" usage:"output file path (empty means stdout) --output='./stats.md'"`
+	Format string `cli:"f,format" usage:"output format [text,json,markdown] --format='text'" dft:"text"`
+	Bins   int    `cli:"bins" usage:"number of bins of confidence histogram --bins=10" dft:"10"`
+}
+
+var statistician = &cli.Command{
+	Name: "stats",
+	Desc: "Show summary statistics of --input TSV file",
+	Argv: func() interface{} { return new(statsT) },
+	Fn:   execStats,
+}
+
+func execStats(ctx *cli.Context) error {
+	argv := ctx.Argv().(*statsT)
+
+	var render func(io.Writer, detectStats) error
+	switch argv.Format {
+	case tableFormatText:
+		render = renderStatsText
+	case tableFormatJSON:
+		render = renderStatsJSON
+	case tableFormatMarkdown:
+		render = renderStatsMarkdown
+	default:
+		return fmt.Errorf("unknown format: [%s]", argv.Format)
+	}
+
+	result, err := readDetectResult(argv.Input)
+	if err != nil {
+		return err
+	}
+	stats := newDetectStats(result, argv.Bins)
+	return writeOutput(argv.Output, func(w io.Writer) error {
+		return render(w, stats)
+	})
+}
+
+// writeOutput writes the output into the file, or stdout when the path is empty.
+func writeOutput(path string, fn func(io.Writer) error) error {
+	if path == "" {
+		return fn(os.Stdout)
+	}
+	if _, err := NewFileHandler(path); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := fn(f); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func renderStatsJSON(w io.Writer, s detectStats) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func renderStatsText(w io.Writer, s detectStats) error {
+	writeTablesText(w, newStatsTables(s))
+	return nil
+}
+
+func renderStatsMarkdown(w io.Writer, s detectStats) error {
+	fmt.Fprintf(w, "# Detection statistics\n\nImages: %d\n", s.Images)
+	writeTablesMarkdown(w, newStatsTables(s))
+	return nil
+}
+
+// writeTablesText writes the tables in TSV with the titles.
+func writeTablesText(w io.Writer, tables []statsTable) {
+	for i, t := range tables {
+		if i != 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "[%s]\n", t.Title)
+		fmt.Fprintln(w, strings.Join(t.Header, "\t"))
+		for _, row := range t.Rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+	}
+}
+
+// writeTablesMarkdown writes the tables in markdown with the titles in h2.
+func writeTablesMarkdown(w io.Writer, tables []statsTable) {
+	for _, t := range tables {
+		fmt.Fprintf(w, "\n## %s\n\n", t.Title)
+		fmt.Fprintf(w, "| %s |\n", strings.Join(t.Header, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(":--|", len(t.Header)))
+		for _, row := range t.Rows {
+			fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+		}
+	}
+}
+
+// statsTable is a table of text and markdown output.
+type statsTable struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+}
+
+func newStatsTables(s detectStats) []statsTable {
+	summary := statsTable{
+		Title:  "Summary",
+		Header: []string{"engine", "images", "errors", "faces", "images_with_faces", "faces/image", "p50", "p90", "max"},
+	}
+	counts := statsTable{
+		Title:  "Faces per image",
+		Header: []string{"engine"},
+	}
+	sizes := statsTable{
+		Title:  "Face size (larger ratio of width and height to image)",
+		Header: []string{"engine", "faces", "width_p50", "height_p50"},
+	}
+	confidence := statsTable{
+		Title:  "Confidence",
+		Header: []string{"engine", "faces", "min", "mean", "p50", "p90", "max"},
+	}
+	var histograms []statsTable
+
+	for i, e := range s.Engines {
+		summary.Rows = append(summary.Rows, []string{
+			e.Engine,
+			strconv.Itoa(e.Images),
+			strconv.Itoa(e.Errors),
+			strconv.Itoa(e.Faces),
+			strconv.Itoa(e.ImagesWithFaces),
+			formatStatsFloat(e.FacesPerImage.Mean),
+			formatStatsFloat(e.FacesPerImage.P50),
+			formatStatsFloat(e.FacesPerImage.P90),
+			formatStatsFloat(e.FacesPerImage.Max),
+		})
+
+		row := []string{e.Engine}
+		for _, b := range e.FacesPerImageHistogram {
+			if i == 0 {
+				counts.Header = append(counts.Header, b.Label)
+			}
+			row = append(row, strconv.Itoa(b.Count))
+		}
+		counts.Rows = append(counts.Rows, row)
+
+		row = []string{e.Engine, strconv.Itoa(e.FaceWidth.Count), formatRatio(e.FaceWidth.P50), formatRatio(e.FaceHeight.P50)}
+		for _, b := range e.FaceSizeHistogram {
+			if i == 0 {
+				sizes.Header = append(sizes.Header, b.Label)
+			}
+			row = append(row, strconv.Itoa(b.Count))
+		}
+		sizes.Rows = append(sizes.Rows, row)
+
+		c := e.Confidence
+		if c.Count == 0 {
+			confidence.Rows = append(confidence.Rows, []string{e.Engine, "0", "-", "-", "-", "-", "-"})
+			continue
+		}
+		confidence.Rows = append(confidence.Rows, []string{
+			e.Engine,
+			strconv.Itoa(c.Count),
+			formatStatsFloat(c.Min),
+			formatStatsFloat(c.Mean),
+			formatStatsFloat(c.P50),
+			formatStatsFloat(c.P90),
+			formatStatsFloat(c.Max),
+		})
+
+		hist := statsTable{
+			Title:  "Confidence histogram: " + e.Engine,
+			Header: []string{"range", "faces", "ratio", ""},
+		}
+		for _, b := range e.ConfidenceHistogram {
+			hist.Rows = append(hist.Rows, []string{
+				b.Label,
+				strconv.Itoa(b.Count),
+				formatPercent(b.Count, c.Count),
+				strings.Repeat("#", b.Count*40/c.Count),
+			})
+		}
+		histograms = append(histograms, hist)
+	}
+
+	agreement := statsTable{
+		Title:  "Face count agreement",
+		Header: []string{"engine_a", "engine_b", "images", "matched", "rate", "mean_abs_diff"},
+	}
+	for _, ag := range s.Agreements {
+		agreement.Rows = append(agreement.Rows, []string{
+			ag.A,
+			ag.B,
+			strconv.Itoa(ag.Images),
+			strconv.Itoa(ag.Matched),
+			formatPercent(ag.Matched, ag.Images),
+			formatStatsFloat(ag.MeanAbsDiff),
+		})
+	}
+
+	tables := []statsTable{summary, counts, sizes, confidence}
+	tables = append(tables, histograms...)
+	return append(tables, agreement)
+}
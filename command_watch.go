@@ -0,0 +1,510 @@
+package fda
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mkideal/cli"
+	"github.com/pkg/errors"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// watch command
+type watchT struct {
+	cli.Helper
+	Input        string `cli:"*i,input" usage:"image dir path to watch --input='/path/to/image_dir'"`
+	Output       string `cli:"*o,output" usage:"output file path, TSV or JSONL by the extension --output='./watch.tsv'" dft:"./watch.tsv"`
+	UseAllEngine bool   `cli:"a,all" usage:"use all engines"`
+	Engines      string `cli:"e,engine" usage:"comma separate Face Detect Engines --engine='opencv,dlib,pigo,tensorflow,rekognition,google,azure,face++'" dft:"opencv,dlib,pigo,tensorflow"`
+	Type         string `cli:"t,type" usage:"comma separate file extensions --type='jpg,jpeg,png,gif'" dft:"jpg,jpeg,png,gif"`
+	Exclude      string `cli:"exclude" usage:"comma separate glob patterns of files and dirs to exclude --exclude='_annotated_*'" dft:"_annotated_*"`
+	Interval     int    `cli:"interval" usage:"polling interval in seconds --interval=2" dft:"2"`
+	Settle       int    `cli:"settle" usage:"seconds to wait until the file stops changing --settle=3" dft:"3"`
+	Concurrency  int    `cli:"c,concurrency" usage:"number of images detected concurrently --concurrency=2" dft:"2"`
+	MaxRetry     int    `cli:"max-retry" usage:"max attempts of the failed image before recording it as failed (0 means no limit) --max-retry=5" dft:"5"`
+	RetryWait    int    `cli:"retry-wait" usage:"seconds to wait before retrying the failed image, doubled on each failure --retry-wait=10" dft:"10"`
+	Rotate       int    `cli:"rotate" usage:"max rows of output file before rotating (0 means no rotation) --rotate=10000" dft:"0"`
+	State        string `cli:"state" usage:"state file path to skip processed images on restart --state='./watch.state'" dft:"./watch.state"`
+	Annotate     bool   `cli:"annotate" usage:"annotate each image into _annotated_ file next to it"`
+	Redact       string `cli:"redact" usage:"output directory path of redacted images (empty means no redaction) --redact='./redact'"`
+	RedactMethod string `cli:"redact-method" usage:"redaction method [blur,pixelate,fill] --redact-method='blur'" dft:"blur"`
+}
+
+var watcher = &cli.Command{
+	Name: "watch",
+	Desc: "Watch --input directory and detect faces from new image files",
+	Argv: func() interface{} { return new(watchT) },
+	Fn:   execWatch,
+}
+
+func execWatch(ctx *cli.Context) error {
+	argv := ctx.Argv().(*watchT)
+	conf := NewConfig(argv.UseAllEngine)
+	for _, e := range strings.Split(argv.Engines, ",") {
+		if err := conf.setUseEngineFromName(e); err != nil {
+			return errors.Wrap(err, "[ERROR] setUseEngineFromName")
+		}
+	}
+
+	pipeline, err := NewPipeline(WithConfig(conf), WithEngineRegistry(enabledEngines...))
+	if err != nil {
+		return errors.Wrap(err, "[ERROR] NewPipeline")
+	}
+
+	p, err := newWatchProcessor(argv, pipeline)
+	if err != nil {
+		pipeline.Close()
+		return err
+	}
+	defer p.Close()
+
+	dir := filepath.Clean(argv.Input)
+	opt := WalkOption{
+		Types:   strings.Split(argv.Type, ","),
+		Exclude: splitPatterns(argv.Exclude),
+		OnError: func(path string, err error) {
+			fmt.Printf("[WARN] skipped path:%s\terr:%s\n", path, err.Error())
+		},
+	}
+	// do not detect the redacted images.
+	if argv.Redact != "" {
+		if rel, err := filepath.Rel(dir, filepath.Clean(argv.Redact)); err == nil && !strings.HasPrefix(rel, "..") {
+			opt.Exclude = append(opt.Exclude, filepath.ToSlash(rel))
+		}
+	}
+	w := newDirWatcher(dir, opt, time.Duration(argv.Settle)*time.Second, p.state)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	concurrency := argv.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				p.Process(path)
+			}
+		}()
+	}
+
+	interval := time.Duration(argv.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	fmt.Printf("[INFO] watching: %s\n", dir)
+	watchLoop(w, jobs, ticker.C, sig)
+
+	// finish the images in progress before exit.
+	fmt.Printf("[INFO] shutting down...\n")
+	close(jobs)
+	wg.Wait()
+	return nil
+}
+
+func watchLoop(w *dirWatcher, jobs chan<- string, tick <-chan time.Time, sig <-chan os.Signal) {
+	for {
+		files, err := w.Poll(time.Now())
+		if err != nil {
+			fmt.Printf("[ERROR] %s\n", err.Error())
+		}
+
+		sort.Strings(files)
+		for i, path := range files {
+			select {
+			case jobs <- path:
+			case <-sig:
+				// the rest of files are processed after restart.
+				for _, f := range files[i:] {
+					w.state.Cancel(f)
+				}
+				return
+			}
+		}
+
+		select {
+		case <-tick:
+		case <-sig:
+			return
+		}
+	}
+}
+
+// watchProcessor detects faces from the image and writes the results.
+type watchProcessor struct {
+	inputDir string
+	pipeline *Pipeline
+	engines  []engine.Engine
+	output   *rollingWriter
+	jsonl    bool
+	state    *watchState
+
+	// optional actions.
+	style     *annotateStyle
+	redactDir string
+	redactor  faceRedactor
+
+	// the images failed after detection, to retry only the failed steps.
+	mu      sync.Mutex
+	partial map[string]*watchJob
+}
+
+// watchJob is the progress of processing the image.
+type watchJob struct {
+	stamp     fileStamp
+	out       *DetectResult
+	annotated bool
+	redacted  bool
+}
+
+func newWatchProcessor(argv *watchT, pipeline *Pipeline) (*watchProcessor, error) {
+	engines := pipeline.Engines()
+	p := &watchProcessor{
+		inputDir: filepath.Clean(argv.Input),
+		pipeline: pipeline,
+		engines:  engines,
+		jsonl:    isJSONLFile(argv.Output),
+		partial:  make(map[string]*watchJob),
+	}
+
+	if argv.Annotate {
+		p.style = newAnnotateStyle()
+	}
+	if argv.Redact != "" {
+		switch argv.RedactMethod {
+		case redactMethodBlur, redactMethodPixelate, redactMethodFill:
+		default:
+			return nil, fmt.Errorf("unknown method: [%s]", argv.RedactMethod)
+		}
+		if err := os.MkdirAll(argv.Redact, 0755); err != nil {
+			return nil, err
+		}
+		p.redactDir = argv.Redact
+		p.redactor = faceRedactor{
+			method: argv.RedactMethod,
+			expand: 0.1,
+			color:  color.Black,
+		}
+	}
+
+	state, err := openWatchState(argv.State, argv.MaxRetry, time.Duration(argv.RetryWait)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	p.state = state
+
+	header := ""
+	if !p.jsonl {
+		header = getHeader(engines)
+	}
+	p.output, err = openRollingWriter(argv.Output, header, argv.Rotate)
+	if err != nil {
+		state.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Process detects faces of the image and marks it as processed.
+// The failed image is retried later, and the steps succeeded before are skipped on the retry.
+func (p *watchProcessor) Process(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Printf("[ERROR] path:%s\terr:%s\n", path, err.Error())
+		p.removeJob(path)
+		p.state.Cancel(path)
+		return
+	}
+	job := p.getJob(path, newFileStamp(info))
+
+	if job.out == nil {
+		out := p.pipeline.Detect(path)
+		if out.hasError() {
+			out.printErrors()
+			p.retry(path, job)
+			return
+		}
+		job.out = &out
+	}
+	if !job.annotated {
+		if err := p.annotate(*job.out); err != nil {
+			fmt.Printf("[ERROR] annotate path:%s\terr:%s\n", path, err.Error())
+			p.retry(path, job)
+			return
+		}
+		job.annotated = true
+	}
+	if !job.redacted {
+		if err := p.redact(*job.out); err != nil {
+			fmt.Printf("[ERROR] redact path:%s\terr:%s\n", path, err.Error())
+			p.retry(path, job)
+			return
+		}
+		job.redacted = true
+	}
+	if err := p.write(*job.out); err != nil {
+		fmt.Printf("[ERROR] path:%s\terr:%s\n", path, err.Error())
+		p.retry(path, job)
+		return
+	}
+
+	p.removeJob(path)
+	if err := p.state.MarkDone(path, job.stamp); err != nil {
+		fmt.Printf("[ERROR] cannot save state path:%s\terr:%s\n", path, err.Error())
+	}
+	fmt.Printf("[INFO] processed: %s\n", path)
+}
+
+// getJob returns the progress of the last attempt, or the new one when the file is modified.
+func (p *watchProcessor) getJob(path string, stamp fileStamp) *watchJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if job, ok := p.partial[path]; ok && job.stamp.Equal(stamp) {
+		return job
+	}
+	job := &watchJob{stamp: stamp}
+	p.partial[path] = job
+	return job
+}
+
+func (p *watchProcessor) removeJob(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.partial, path)
+}
+
+// retry keeps the progress until the next attempt, or gives up the image after the max attempts.
+func (p *watchProcessor) retry(path string, job *watchJob) {
+	attempts, wait, failed, err := p.state.Fail(path, job.stamp, time.Now())
+	if err != nil {
+		fmt.Printf("[ERROR] cannot save state path:%s\terr:%s\n", path, err.Error())
+	}
+	if failed {
+		p.removeJob(path)
+		fmt.Printf("[ERROR] gave up after %d attempts: %s\n", attempts, path)
+		return
+	}
+	fmt.Printf("[WARN] retry in %s (attempts: %d): %s\n", wait, attempts, path)
+}
+
+func (p *watchProcessor) write(out DetectResult) error {
+	if !p.jsonl {
+		return p.output.WriteLine(out.Row())
+	}
+
+	byt, err := json.Marshal(out.record(p.engines))
+	if err != nil {
+		return err
+	}
+	return p.output.WriteLine(string(byt))
+}
+
+func (p *watchProcessor) annotate(out DetectResult) error {
+	if p.style == nil {
+		return nil
+	}
+
+	var targets []string
+	for _, r := range out.Results {
+		if r == nil {
+			continue
+		}
+		byt, err := json.Marshal(r)
+		if err != nil {
+			continue
+		}
+		targets = append(targets, string(byt))
+	}
+	return annotateImage(out.Path, p.style, targets...)
+}
+
+func (p *watchProcessor) redact(out DetectResult) error {
+	if p.redactDir == "" {
+		return nil
+	}
+
+	row := detectResultRow{
+		Path:    out.Path,
+		Results: make(map[string]engine.FaceResult),
+	}
+	names := make([]string, len(p.engines))
+	for i, e := range p.engines {
+		names[i] = e.String()
+		if r := out.Results[i]; r != nil {
+			row.Results[names[i]] = *r
+		}
+	}
+
+	faces, err := getRedactFaces(row, names, false, 0, 1)
+	if err != nil {
+		return err
+	}
+	output := filepath.Join(p.redactDir, p.getRedactedFileName(out.Path))
+	_, err = p.redactor.Redact(out.Path, output, faces)
+	return err
+}
+
+// getRedactedFileName returns the file name from the relative path. (e.g. camera1/001.jpg => camera1_001.jpg)
+func (p *watchProcessor) getRedactedFileName(path string) string {
+	rel, err := filepath.Rel(p.inputDir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	name := strings.Replace(filepath.ToSlash(rel), "/", "_", -1)
+
+	ext := filepath.Ext(name)
+	if strings.ToLower(ext) != ".png" {
+		name = strings.TrimSuffix(name, ext) + ".jpg"
+	}
+	return name
+}
+
+func (p *watchProcessor) Close() error {
+	err := p.output.Close()
+	if stateErr := p.state.Close(); err == nil {
+		err = stateErr
+	}
+	if pipelineErr := p.pipeline.Close(); err == nil {
+		err = pipelineErr
+	}
+	return err
+}
+
+func isJSONLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// rollingWriter appends lines into the file, and rotates the file by the number of rows.
+// The rotated files are renamed with the sequence number. (e.g. watch.tsv => watch.001.tsv)
+type rollingWriter struct {
+	mu      sync.Mutex
+	path    string
+	header  string
+	maxRows int
+	rows    int
+	fp      *os.File
+}
+
+func openRollingWriter(path, header string, maxRows int) (*rollingWriter, error) {
+	if _, err := NewFileHandler(path); err != nil {
+		return nil, err
+	}
+
+	w := &rollingWriter{
+		path:    path,
+		header:  header,
+		maxRows: maxRows,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rollingWriter) open() error {
+	fp, err := os.OpenFile(w.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	// continue the existing file after restart.
+	rows, err := countLines(fp)
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	switch {
+	case rows == 0 && w.header != "":
+		if _, err := fmt.Fprintln(fp, w.header); err != nil {
+			fp.Close()
+			return err
+		}
+	case w.header != "":
+		rows--
+	}
+
+	w.fp = fp
+	w.rows = rows
+	return nil
+}
+
+func countLines(fp *os.File) (int, error) {
+	n := 0
+	sc := bufio.NewScanner(fp)
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) != 0 {
+			n++
+		}
+	}
+	return n, sc.Err()
+}
+
+// WriteLine writes the line and flushes it into the file.
+func (w *rollingWriter) WriteLine(line string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxRows > 0 && w.rows >= w.maxRows {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintln(w.fp, line); err != nil {
+		return err
+	}
+	w.rows++
+	return w.fp.Sync()
+}
+
+func (w *rollingWriter) rotate() error {
+	if err := w.fp.Close(); err != nil {
+		return err
+	}
+
+	for i := 1; ; i++ {
+		rotated := getShardPath(w.path, i)
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			if err := os.Rename(w.path, rotated); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return w.open()
+}
+
+func (w *rollingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.fp.Close()
+}
@@ -30,6 +30,9 @@ const (
 	keyConfigPigoCascadeFilePath = "FDA_PIGO_CASCADE_FILE"
 	defaultPigoCascadeFilePath   = "models/facefinder"
 
+	keyConfigPigoQThreshold = "FDA_PIGO_Q_THRESHOLD"
+	defaultPigoQThreshold   = 5.0
+
 	keyConfigDlibModelDir = "FDA_DLIB_MODEL_DIR"
 	defaultDlibModelDir   = "models"
 
@@ -38,11 +41,20 @@ const (
 
 	keyConfigTensorFlowModelFilePath = "FDA_TF_MODEL_FILE"
 	defaultTensorFlowModelFilePath   = "models/tensorflow.pb"
+
+	keyConfigTensorFlowScoreThreshold = "FDA_TF_SCORE_THRESHOLD"
+	defaultTensorFlowScoreThreshold   = 0.5
+
+	// endpoint of S3 compatible storage like MinIO. (empty means AWS S3)
+	keyConfigS3Endpoint = "FDA_S3_ENDPOINT"
 )
 
 type Config struct {
-	InputPath  string
-	OutputPath string
+	InputPath    string
+	OutputPath   string
+	OutputFormat string
+	// number of the first calls of each engine excluded from the latency summary.
+	Warmup int
 
 	UseEngineAzureVision  bool
 	UseEngineGoogleVision bool
@@ -59,12 +71,20 @@ type Config struct {
 	DlibModelDir          string
 	OpneCVCascadeFilePath string
 	TensorFlowModelPath   string
+	// min detection quality of Pigo. (0 means default)
+	PigoQThreshold float64
+	// min detection score of TensorFlow. (0 means default)
+	TensorFlowScoreThreshold float64
+
+	metaFilter imageMetaFilter
 }
 
 func NewConfig(useAll bool) Config {
 	useAzure, _ := strconv.ParseBool(os.Getenv(keyConfigEngineAzureVision))
 	useGoogle, _ := strconv.ParseBool(os.Getenv(keyConfigEngineGoogleVision))
 	useRekognition, _ := strconv.ParseBool(os.Getenv(keyConfigEngineRekognition))
+	pigoQThreshold, _ := strconv.ParseFloat(os.Getenv(keyConfigPigoQThreshold), 64)
+	tfScoreThreshold, _ := strconv.ParseFloat(os.Getenv(keyConfigTensorFlowScoreThreshold), 64)
 	useFacePlusPlus, _ := strconv.ParseBool(os.Getenv(keyConfigEngineFacePlusPlus))
 	usePigo, _ := strconv.ParseBool(os.Getenv(keyConfigEnginePigo))
 	useDlib, _ := strconv.ParseBool(os.Getenv(keyConfigEngineDlib))
@@ -86,20 +106,22 @@ func NewConfig(useAll bool) Config {
 	}
 
 	return Config{
-		UseEngineAzureVision:  useAzure,
-		UseEngineGoogleVision: useGoogle,
-		UseEngineRekognition:  useRekognition,
-		UseEngineFacePlusPlus: useFacePlusPlus,
-		UseEnginePigo:         usePigo,
-		UseEngineDlib:         useDlib,
-		UseEngineOpenCV:       useOpenCV,
-		UseEngineTensorFlow:   useTF,
-		PigoCascadeFilePath:   os.Getenv(keyConfigPigoCascadeFilePath),
-		OpneCVCascadeFilePath: os.Getenv(keyConfigOpenCVCascadeFilePath),
-		DlibModelDir:          os.Getenv(keyConfigDlibModelDir),
-		TensorFlowModelPath:   os.Getenv(keyConfigTensorFlowModelFilePath),
-		AzureRegion:           os.Getenv(keyConfigAzureRegion),
-		AzureSubscriptionKey:  os.Getenv(keyConfigAzureSubscriptionKey),
+		UseEngineAzureVision:     useAzure,
+		UseEngineGoogleVision:    useGoogle,
+		UseEngineRekognition:     useRekognition,
+		UseEngineFacePlusPlus:    useFacePlusPlus,
+		UseEnginePigo:            usePigo,
+		UseEngineDlib:            useDlib,
+		UseEngineOpenCV:          useOpenCV,
+		UseEngineTensorFlow:      useTF,
+		PigoCascadeFilePath:      os.Getenv(keyConfigPigoCascadeFilePath),
+		OpneCVCascadeFilePath:    os.Getenv(keyConfigOpenCVCascadeFilePath),
+		DlibModelDir:             os.Getenv(keyConfigDlibModelDir),
+		TensorFlowModelPath:      os.Getenv(keyConfigTensorFlowModelFilePath),
+		PigoQThreshold:           pigoQThreshold,
+		TensorFlowScoreThreshold: tfScoreThreshold,
+		AzureRegion:              os.Getenv(keyConfigAzureRegion),
+		AzureSubscriptionKey:     os.Getenv(keyConfigAzureSubscriptionKey),
 	}
 }
 
@@ -111,6 +133,18 @@ func (c *Config) setOutputPath(s string) {
 	c.OutputPath = s
 }
 
+func (c *Config) setOutputFormat(s string) {
+	c.OutputFormat = s
+}
+
+func (c *Config) setWarmup(n int) {
+	c.Warmup = n
+}
+
+func (c *Config) setMetaFilter(f imageMetaFilter) {
+	c.metaFilter = f
+}
+
 func (c *Config) setUseEngineFromName(name string) error {
 	switch name {
 	case "azure":
@@ -161,6 +195,13 @@ func (c Config) GetPigoCascadeFile() string {
 	return defaultPigoCascadeFilePath
 }
 
+func (c Config) GetPigoQThreshold() float64 {
+	if c.PigoQThreshold > 0 {
+		return c.PigoQThreshold
+	}
+	return defaultPigoQThreshold
+}
+
 func (c Config) GetDlibModelDir() string {
 	if c.DlibModelDir != "" {
 		return c.DlibModelDir
@@ -181,3 +222,10 @@ func (c Config) GetTensorFlowModelFile() string {
 	}
 	return defaultTensorFlowModelFilePath
 }
+
+func (c Config) GetTensorFlowScoreThreshold() float64 {
+	if c.TensorFlowScoreThreshold > 0 {
+		return c.TensorFlowScoreThreshold
+	}
+	return defaultTensorFlowScoreThreshold
+}
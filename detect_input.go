@@ -0,0 +1,196 @@
+package fda
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// imageReader reads the images of URL, archive file and S3.
+type imageReader struct {
+	fetcher  *urlFetcher
+	archives *archiveCache
+	s3       *s3Storage
+}
+
+// detectInput is an image to detect, which is a local file, an entry of archive file, URL or S3 object.
+type detectInput struct {
+	Path string
+
+	// image data of the archive entry, URL or S3 object. (nil means local file)
+	data []byte
+	ext  string
+	// S3 object for the engines reading it directly. the data is loaded only when other engines need it.
+	s3Object *engine.S3Object
+	s3       *s3Storage
+	// temporary file for the engines which need a local file.
+	tmpPath string
+}
+
+func newDetectInput(path string, r imageReader) (*detectInput, error) {
+	in := &detectInput{
+		Path: path,
+		ext:  filepath.Ext(path),
+		s3:   r.s3,
+	}
+
+	var err error
+	switch {
+	case isURLPath(path):
+		in.ext = getURLExt(path)
+		in.data, err = r.fetcher.Fetch(path)
+	case isArchivePath(path):
+		in.data, _, err = r.archives.ReadFile(path)
+	case isS3Path(path):
+		if !r.s3.IsAWS() {
+			in.data, err = r.s3.ReadFile(path)
+			break
+		}
+		bucket, key, _ := splitS3Path(path)
+		in.s3Object = &engine.S3Object{
+			Bucket: bucket,
+			Key:    key,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// newDetectInputFromBytes returns the input of image data, like uploaded file.
+// The file name is used only for the extension of temporary file.
+func newDetectInputFromBytes(name string, data []byte) *detectInput {
+	ext := filepath.Ext(name)
+	if ext == "" {
+		if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			ext = "." + format
+		}
+	}
+	return &detectInput{
+		Path: name,
+		data: data,
+		ext:  ext,
+	}
+}
+
+// prepare loads the data and writes the temporary file which the engine needs, before the detection.
+// It's called again by Detect, and does nothing for the prepared data.
+func (in *detectInput) prepare(e engine.Engine) error {
+	if in.s3Object != nil {
+		if _, ok := e.(engine.S3Detector); ok {
+			return in.setS3ImageSize()
+		}
+		if err := in.loadS3Object(); err != nil {
+			return err
+		}
+	}
+
+	if in.data == nil {
+		return nil
+	}
+	if _, ok := e.(engine.BytesDetector); ok {
+		return nil
+	}
+	_, err := in.getTempFile()
+	return err
+}
+
+// Detect detects faces by the engine.
+// The image data is passed directly to engine.BytesDetector, otherwise it's written into a temporary file.
+func (in *detectInput) Detect(e engine.Engine) (engine.FaceResult, error) {
+	if err := in.prepare(e); err != nil {
+		return engine.FaceResult{}, err
+	}
+
+	if in.s3Object != nil {
+		if d, ok := e.(engine.S3Detector); ok {
+			return d.DetectS3Object(*in.s3Object)
+		}
+	}
+	if in.data == nil {
+		return e.Detect(in.Path)
+	}
+	if d, ok := e.(engine.BytesDetector); ok {
+		return d.DetectBytes(in.data)
+	}
+	return e.Detect(in.tmpPath)
+}
+
+func (in *detectInput) loadS3Object() error {
+	if in.data != nil {
+		return nil
+	}
+
+	byt, err := in.s3.ReadFile(in.Path)
+	if err != nil {
+		return err
+	}
+	in.data = byt
+	return nil
+}
+
+// setS3ImageSize reads the image size from the head of the object without downloading whole data.
+func (in *detectInput) setS3ImageSize() error {
+	obj := in.s3Object
+	if obj.Width > 0 {
+		return nil
+	}
+
+	var conf image.Config
+	var err error
+	if in.data != nil {
+		conf, _, err = image.DecodeConfig(bytes.NewReader(in.data))
+	} else {
+		var byt []byte
+		byt, err = in.s3.ReadHead(in.Path, s3HeadSize)
+		if err == nil {
+			conf, _, err = image.DecodeConfig(bytes.NewReader(byt))
+		}
+	}
+	if err != nil {
+		// the header is larger than the head size.
+		if err := in.loadS3Object(); err != nil {
+			return err
+		}
+		if conf, _, err = image.DecodeConfig(bytes.NewReader(in.data)); err != nil {
+			return err
+		}
+	}
+	obj.Width = conf.Width
+	obj.Height = conf.Height
+	return nil
+}
+
+// getTempFile writes the image data into the temporary file only once.
+func (in *detectInput) getTempFile() (string, error) {
+	if in.tmpPath != "" {
+		return in.tmpPath, nil
+	}
+
+	// keep the extension for the engines checking the image format by it.
+	f, err := ioutil.TempFile("", "fda-*"+in.ext)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(in.data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	in.tmpPath = f.Name()
+	return in.tmpPath, nil
+}
+
+// Close removes the temporary file.
+func (in *detectInput) Close() error {
+	if in.tmpPath == "" {
+		return nil
+	}
+	return os.Remove(in.tmpPath)
+}
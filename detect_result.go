@@ -0,0 +1,136 @@
+package fda
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// column names of detector's output TSV.
+const (
+	colPath          = "path"
+	colCount         = "count"
+	colError         = "error"
+	colSuffixCount   = ":count"
+	colSuffixDetail  = ":detail"
+	colSuffixLatency = ":latency"
+)
+
+// detectResult is the parsed content of detector's output TSV.
+type detectResult struct {
+	Header  []string
+	Engines []string
+	Rows    []detectResultRow
+}
+
+// detectResultRow is a line of detector's output TSV.
+type detectResultRow struct {
+	Path  string
+	Count string
+	// error of reading the image. (e.g. download error)
+	Error string
+	// raw line of TSV.
+	Line map[string]string
+	// FaceResult of each engines. the engine is missing when the detection was failed.
+	Results map[string]engine.FaceResult
+}
+
+// readDetectResult reads detector's output TSV file.
+func readDetectResult(file string) (*detectResult, error) {
+	f, err := NewCSVHandler(file)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	engines := getEnginesFromHeader(f.header)
+	rows := make([]detectResultRow, len(lines))
+	for i, line := range lines {
+		rows[i] = newDetectResultRow(engines, line)
+	}
+	result := &detectResult{
+		Header:  f.header,
+		Engines: engines,
+		Rows:    rows,
+	}
+	for _, e := range result.getOverScaleEngines() {
+		fmt.Printf("[WARN] confidence of engine:%s is over 1, which may be the 0-100 scale of old TSV. re-run detect command for the 0-1 scale.\n", e)
+	}
+	return result, nil
+}
+
+// getOverScaleEngines returns the engines whose confidence is over 1.
+func (r *detectResult) getOverScaleEngines() []string {
+	var engines []string
+	for _, e := range r.Engines {
+		for _, row := range r.Rows {
+			if row.hasOverScaleConfidence(e) {
+				engines = append(engines, e)
+				break
+			}
+		}
+	}
+	return engines
+}
+
+func newDetectResultRow(engines []string, line map[string]string) detectResultRow {
+	results := make(map[string]engine.FaceResult, len(engines))
+	for _, e := range engines {
+		raw := line[e+colSuffixDetail]
+		if raw == "" {
+			continue
+		}
+
+		data := engine.FaceResult{}
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			continue
+		}
+		results[e] = data
+	}
+
+	return detectResultRow{
+		Path:    line[colPath],
+		Count:   line[colCount],
+		Error:   line[colError],
+		Line:    line,
+		Results: results,
+	}
+}
+
+func (r detectResultRow) hasOverScaleConfidence(e string) bool {
+	for _, f := range r.Results[e].Faces {
+		if f.Confidence > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpectedCount returns the value of "count" column.
+func (r detectResultRow) ExpectedCount() (int, bool) {
+	if r.Count == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(r.Count)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+func getEnginesFromHeader(header []string) []string {
+	var engines []string
+	for _, h := range header {
+		if strings.HasSuffix(h, colSuffixDetail) {
+			engines = append(engines, strings.TrimSuffix(h, colSuffixDetail))
+		}
+	}
+	return engines
+}
@@ -1,10 +1,11 @@
 package azure
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"io/ioutil"
 
 	"github.com/Azure/azure-sdk-for-go/services/cognitiveservices/v2.0/computervision"
 	"github.com/Azure/go-autorest/autorest"
@@ -41,22 +42,24 @@ func (d AzureVisionFaceDetector) String() string {
 }
 
 func (d AzureVisionFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
-	emptyResult := engine.FaceResult{}
-	imgWidth, imgHeight, err := engine.GetImageSize(imgPath)
+	byt, err := ioutil.ReadFile(imgPath)
 	if err != nil {
-		return emptyResult, err
+		return engine.FaceResult{}, err
 	}
+	return d.DetectBytes(byt)
+}
 
-	f, err := os.Open(imgPath)
+func (d AzureVisionFaceDetector) DetectBytes(byt []byte) (engine.FaceResult, error) {
+	emptyResult := engine.FaceResult{}
+	imgWidth, imgHeight, err := engine.GetImageSizeFromBytes(byt)
 	if err != nil {
 		return emptyResult, err
 	}
-	defer f.Close()
 
 	ctx := context.Background()
 	resp, err := d.client.AnalyzeImageInStream(
 		ctx,
-		f,
+		ioutil.NopCloser(bytes.NewReader(byt)),
 		[]computervision.VisualFeatureTypes{computervision.VisualFeatureTypesFaces},
 		nil,
 		"",
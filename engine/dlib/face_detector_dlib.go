@@ -2,6 +2,9 @@ package dlib
 
 import (
 	"errors"
+	"image"
+	"os"
+	"path/filepath"
 
 	"github.com/Kagami/go-face"
 
@@ -13,7 +16,11 @@ type Config interface {
 }
 
 type DlibFaceDetector struct {
+	// models are loaded once per model dir, since Init is called by each pipeline.
+	modelDir   string
 	recognizer *face.Recognizer
+	// nil when the model dir does not have 68-point shape predictor.
+	landmark *landmarkPredictor
 }
 
 func (d *DlibFaceDetector) Init(conf engine.Config) error {
@@ -22,15 +29,45 @@ func (d *DlibFaceDetector) Init(conf engine.Config) error {
 		return errors.New("Incompatible config type for DlibFaceDetector")
 	}
 
-	r, err := face.NewRecognizer(c.GetDlibModelDir())
+	dir := c.GetDlibModelDir()
+	if d.recognizer != nil && d.modelDir == dir {
+		return nil
+	}
+	d.Close()
+
+	r, err := face.NewRecognizer(dir)
 	if err != nil {
 		return err
 	}
 
+	modelPath := filepath.Join(dir, landmarkModelFile)
+	if _, err := os.Stat(modelPath); err == nil {
+		p, err := newLandmarkPredictor(modelPath)
+		if err != nil {
+			r.Close()
+			return err
+		}
+		d.landmark = p
+	}
+
+	d.modelDir = dir
 	d.recognizer = r
 	return nil
 }
 
+// Close frees the models loaded by Init.
+func (d *DlibFaceDetector) Close() {
+	if d.recognizer != nil {
+		d.recognizer.Close()
+		d.recognizer = nil
+	}
+	if d.landmark != nil {
+		d.landmark.Close()
+		d.landmark = nil
+	}
+	d.modelDir = ""
+}
+
 func (d DlibFaceDetector) String() string {
 	return "dlib"
 }
@@ -42,6 +79,14 @@ func (d DlibFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
 	}
 
 	rects, err := d.recognizer.RecognizeFile(imgPath)
+	if err != nil {
+		return engine.FaceResult{}, err
+	}
+	landmarks, err := d.getLandmarks(imgPath, rects)
+	if err != nil {
+		return engine.FaceResult{}, err
+	}
+
 	faces := make([]engine.FaceData, len(rects))
 	for i, rect := range rects {
 		r := rect.Rectangle
@@ -58,6 +103,9 @@ func (d DlibFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
 			PercentWidth:  float64(w) / float64(imgWidth),
 			PercentHeight: float64(h) / float64(imgHeight),
 		}
+		if landmarks != nil {
+			faces[i].Landmarks = landmarks[i]
+		}
 	}
 
 	return engine.FaceResult{
@@ -65,3 +113,14 @@ func (d DlibFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
 		Faces:      faces,
 	}, nil
 }
+
+func (d DlibFaceDetector) getLandmarks(imgPath string, faces []face.Face) ([][]engine.Landmark, error) {
+	if d.landmark == nil {
+		return nil, nil
+	}
+	rects := make([]image.Rectangle, len(faces))
+	for i, f := range faces {
+		rects[i] = f.Rectangle
+	}
+	return d.landmark.Predict(imgPath, rects)
+}
@@ -0,0 +1,49 @@
+#include <string.h>
+#include <dlib/image_processing.h>
+#include "landmark.h"
+
+using namespace dlib;
+
+struct landmark_predictor {
+	shape_predictor sp;
+};
+
+landmark_predictor* landmark_new(const char* model_path, char** err) {
+	landmark_predictor* p = new landmark_predictor;
+	try {
+		deserialize(model_path) >> p->sp;
+	} catch (std::exception& e) {
+		*err = strdup(e.what());
+		delete p;
+		return NULL;
+	}
+	return p;
+}
+
+int landmark_predict(landmark_predictor* p, const unsigned char* rgb, int width, int height, const long* rects, int n, long* points, int parts) {
+	if (p->sp.num_parts() != (unsigned long)parts) {
+		return 1;
+	}
+
+	matrix<rgb_pixel> img(height, width);
+	for (int y = 0; y < height; y++) {
+		for (int x = 0; x < width; x++) {
+			const unsigned char* px = rgb + (y * width + x) * 3;
+			img(y, x) = rgb_pixel(px[0], px[1], px[2]);
+		}
+	}
+
+	for (int i = 0; i < n; i++) {
+		const long* r = rects + i * 4;
+		full_object_detection shape = p->sp(img, rectangle(r[0], r[1], r[2], r[3]));
+		for (int j = 0; j < parts; j++) {
+			points[(i * parts + j) * 2] = shape.part(j).x();
+			points[(i * parts + j) * 2 + 1] = shape.part(j).y();
+		}
+	}
+	return 0;
+}
+
+void landmark_free(landmark_predictor* p) {
+	delete p;
+}
@@ -0,0 +1,130 @@
+package dlib
+
+// #cgo pkg-config: dlib-1
+// #cgo CXXFLAGS: -std=c++1z -Wall -O3 -DNDEBUG -march=native
+// #include <stdlib.h>
+// #include "landmark.h"
+import "C"
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"unsafe"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// file name of 68-point shape predictor model in the model dir.
+const landmarkModelFile = "shape_predictor_68_face_landmarks.dat"
+
+// number of the points of the model.
+const landmarkParts = 68
+
+// landmarkTypes is the types of the points in the iBUG 300-W markup order.
+var landmarkTypes = func() []string {
+	types := make([]string, 0, landmarkParts)
+	for _, r := range []struct {
+		typ   string
+		count int
+	}{
+		{"jaw", 17},
+		{"right_eyebrow", 5},
+		{"left_eyebrow", 5},
+		{"nose", 9},
+		{"right_eye", 6},
+		{"left_eye", 6},
+		{"mouth", 20},
+	} {
+		for i := 0; i < r.count; i++ {
+			types = append(types, r.typ)
+		}
+	}
+	return types
+}()
+
+// landmarkPredictor predicts 68-point landmarks of the faces by dlib shape predictor.
+type landmarkPredictor struct {
+	ptr *C.landmark_predictor
+}
+
+func newLandmarkPredictor(modelPath string) (*landmarkPredictor, error) {
+	cPath := C.CString(modelPath)
+	defer C.free(unsafe.Pointer(cPath))
+
+	var cErr *C.char
+	ptr := C.landmark_new(cPath, &cErr)
+	if ptr == nil {
+		defer C.free(unsafe.Pointer(cErr))
+		return nil, fmt.Errorf("failed to load shape predictor: [%s]", C.GoString(cErr))
+	}
+	return &landmarkPredictor{ptr: ptr}, nil
+}
+
+// Predict returns the landmarks of each rectangle on the image file.
+func (p *landmarkPredictor) Predict(imgPath string, rects []image.Rectangle) ([][]engine.Landmark, error) {
+	if len(rects) == 0 {
+		return nil, nil
+	}
+
+	fp, err := os.Open(imgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	img, _, err := image.Decode(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	rgb := make([]byte, w*h*3)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			i := (y*w + x) * 3
+			rgb[i], rgb[i+1], rgb[i+2] = byte(r>>8), byte(g>>8), byte(bl>>8)
+		}
+	}
+
+	cRects := make([]C.long, len(rects)*4)
+	for i, r := range rects {
+		cRects[i*4] = C.long(r.Min.X)
+		cRects[i*4+1] = C.long(r.Min.Y)
+		cRects[i*4+2] = C.long(r.Max.X)
+		cRects[i*4+3] = C.long(r.Max.Y)
+	}
+	points := make([]C.long, len(rects)*landmarkParts*2)
+
+	ret := C.landmark_predict(p.ptr,
+		(*C.uchar)(unsafe.Pointer(&rgb[0])), C.int(w), C.int(h),
+		&cRects[0], C.int(len(rects)),
+		&points[0], C.int(landmarkParts),
+	)
+	if ret != 0 {
+		return nil, errors.New("shape predictor must have 68 points")
+	}
+
+	result := make([][]engine.Landmark, len(rects))
+	for i := range rects {
+		landmarks := make([]engine.Landmark, landmarkParts)
+		for j := range landmarks {
+			k := (i*landmarkParts + j) * 2
+			landmarks[j] = engine.Landmark{
+				Type: landmarkTypes[j],
+				X:    float64(points[k]),
+				Y:    float64(points[k+1]),
+			}
+		}
+		result[i] = landmarks
+	}
+	return result, nil
+}
+
+func (p *landmarkPredictor) Close() {
+	C.landmark_free(p.ptr)
+}
@@ -0,0 +1,21 @@
+#pragma once
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+typedef struct landmark_predictor landmark_predictor;
+
+// landmark_new loads the shape predictor model. err is set on failure and must be freed.
+landmark_predictor* landmark_new(const char* model_path, char** err);
+
+// landmark_predict predicts the shapes of the faces on the RGB image.
+// rects has (left, top, right, bottom) of n faces, and points receives (x, y) of parts points of each face.
+// It returns non-zero when the model does not have the parts.
+int landmark_predict(landmark_predictor* p, const unsigned char* rgb, int width, int height, const long* rects, int n, long* points, int parts);
+
+void landmark_free(landmark_predictor* p);
+
+#ifdef __cplusplus
+}
+#endif
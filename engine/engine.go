@@ -6,4 +6,24 @@ type Engine interface {
 	Detect(imgPath string) (FaceResult, error)
 }
 
+// BytesDetector is an optional interface of Engine to detect faces from image data on memory.
+// The engine without it needs a local file to detect.
+type BytesDetector interface {
+	DetectBytes(byt []byte) (FaceResult, error)
+}
+
+// S3Detector is an optional interface of Engine to detect faces from the object of AWS S3 directly.
+type S3Detector interface {
+	DetectS3Object(obj S3Object) (FaceResult, error)
+}
+
+// S3Object is an image object of AWS S3.
+type S3Object struct {
+	Bucket string
+	Key    string
+	// image size in pixels to calculate the face position.
+	Width  int
+	Height int
+}
+
 type Config interface{}
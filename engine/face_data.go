@@ -13,7 +13,45 @@ type FaceData struct {
 	Height        int     `json:"height"`
 	PercentWidth  float64 `json:"width_per"`
 	PercentHeight float64 `json:"height_per"`
-	Confidence    float64 `json:"confidence"`
+	// normalized confidence between 0 and 1. (0 means the engine does not support it)
+	Confidence float64 `json:"confidence"`
+	// confidence in the original scale of the engine.
+	RawConfidence float64 `json:"raw_confidence,omitempty"`
+
+	// optional data for the engines which support them.
+	Landmarks []Landmark `json:"landmarks,omitempty"`
+	Pose      *Pose      `json:"pose,omitempty"`
+
+	// optional difficulty attributes of the labeled faces in ground truth.
+	Attributes *Attributes `json:"attributes,omitempty"`
+}
+
+// Landmark is a facial landmark point in pixels.
+type Landmark struct {
+	Type string  `json:"type,omitempty"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
+// Pose is head pose angles in degrees.
+type Pose struct {
+	Roll  float64 `json:"roll"`
+	Yaw   float64 `json:"yaw"`
+	Pitch float64 `json:"pitch"`
+}
+
+// Attributes is difficulty attributes of the face in the same levels as WIDER FACE dataset.
+type Attributes struct {
+	// 0: clear, 1: normal, 2: heavy
+	Blur int `json:"blur"`
+	// 0: typical, 1: exaggerate
+	Expression int `json:"expression"`
+	// 0: normal, 1: extreme
+	Illumination int `json:"illumination"`
+	// 0: no, 1: partial, 2: heavy
+	Occlusion int `json:"occlusion"`
+	// 0: typical, 1: atypical
+	Pose int `json:"pose"`
 }
 
 func (d FaceData) String() string {
@@ -25,7 +63,7 @@ func (d FaceData) SizeString() string {
 }
 
 func (d FaceData) PercentString() string {
-	return fmt.Sprintf("%d,%d", d.PercentWidth, d.PercentHeight)
+	return fmt.Sprintf("%f,%f", d.PercentWidth, d.PercentHeight)
 }
 
 func (d FaceData) ToJson() string {
@@ -33,6 +71,18 @@ func (d FaceData) ToJson() string {
 	return string(byt)
 }
 
+func (d FaceData) HasLandmarks() bool {
+	return len(d.Landmarks) != 0
+}
+
+func (d FaceData) HasPose() bool {
+	return d.Pose != nil
+}
+
+func (d FaceData) HasAttributes() bool {
+	return d.Attributes != nil
+}
+
 func (d FaceData) MaxX() int {
 	return d.X + d.Width
 }
@@ -40,3 +90,35 @@ func (d FaceData) MaxX() int {
 func (d FaceData) MaxY() int {
 	return d.Y + d.Height
 }
+
+// IoU returns Intersection over Union of the two faces.
+func (d FaceData) IoU(o FaceData) float64 {
+	minX := maxInt(d.X, o.X)
+	minY := maxInt(d.Y, o.Y)
+	maxX := minInt(d.MaxX(), o.MaxX())
+	maxY := minInt(d.MaxY(), o.MaxY())
+	if maxX <= minX || maxY <= minY {
+		return 0
+	}
+
+	intersection := float64((maxX - minX) * (maxY - minY))
+	union := float64(d.Width*d.Height+o.Width*o.Height) - intersection
+	if union <= 0 {
+		return 0
+	}
+	return intersection / union
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -1,6 +1,10 @@
 package faceplusplus
 
 import (
+	"encoding/base64"
+	"io/ioutil"
+	"sort"
+
 	"github.com/evalphobia/go-face-plusplus/config"
 	"github.com/evalphobia/go-face-plusplus/face"
 
@@ -26,13 +30,24 @@ func (d FacePlusPlusFaceDetector) String() string {
 }
 
 func (d FacePlusPlusFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
+	byt, err := ioutil.ReadFile(imgPath)
+	if err != nil {
+		return engine.FaceResult{}, err
+	}
+	return d.DetectBytes(byt)
+}
+
+func (d FacePlusPlusFaceDetector) DetectBytes(byt []byte) (engine.FaceResult, error) {
 	emptyResult := engine.FaceResult{}
-	imgWidth, imgHeight, err := engine.GetImageSize(imgPath)
+	imgWidth, imgHeight, err := engine.GetImageSizeFromBytes(byt)
 	if err != nil {
 		return emptyResult, err
 	}
 
-	resp, err := d.client.DetectFromFile(imgPath)
+	resp, err := d.client.DetectByBase64(base64.StdEncoding.EncodeToString(byt),
+		face.WithReturnLandmark(face.ReturnLandmarkYES),
+		face.WithReturnAttributes(face.AttributeHeadPose),
+	)
 	if err != nil {
 		return emptyResult, err
 	}
@@ -52,6 +67,8 @@ func (d FacePlusPlusFaceDetector) Detect(imgPath string) (engine.FaceResult, err
 			Height:        h,
 			PercentWidth:  float64(w) / float64(imgWidth),
 			PercentHeight: float64(h) / float64(imgHeight),
+			Landmarks:     getLandmarks(f.Landmark),
+			Pose:          getPose(f.HeadPose),
 		}
 	}
 
@@ -60,3 +77,35 @@ func (d FacePlusPlusFaceDetector) Detect(imgPath string) (engine.FaceResult, err
 		Faces:      faces,
 	}, nil
 }
+
+// getPose returns nil when the face does not have headpose.
+// Face++ returns the attributes for the five largest faces only, and the others have zero values.
+func getPose(p face.HeadPose) *engine.Pose {
+	if p == (face.HeadPose{}) {
+		return nil
+	}
+	return &engine.Pose{
+		Roll:  p.RollAngle,
+		Yaw:   p.YawAngle,
+		Pitch: p.PitchAngle,
+	}
+}
+
+func getLandmarks(m map[string]face.Landmark) []engine.Landmark {
+	types := make([]string, 0, len(m))
+	for typ := range m {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+
+	landmarks := make([]engine.Landmark, len(types))
+	for i, typ := range types {
+		l := m[typ]
+		landmarks[i] = engine.Landmark{
+			Type: typ,
+			X:    float64(l.X),
+			Y:    float64(l.Y),
+		}
+	}
+	return landmarks
+}
@@ -29,13 +29,16 @@ func (d GoogleVisionFaceDetector) String() string {
 }
 
 func (d GoogleVisionFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
-	emptyResult := engine.FaceResult{}
-	imgWidth, imgHeight, err := engine.GetImageSize(imgPath)
+	byt, err := ioutil.ReadFile(imgPath)
 	if err != nil {
-		return emptyResult, err
+		return engine.FaceResult{}, err
 	}
+	return d.DetectBytes(byt)
+}
 
-	byt, err := ioutil.ReadFile(imgPath)
+func (d GoogleVisionFaceDetector) DetectBytes(byt []byte) (engine.FaceResult, error) {
+	emptyResult := engine.FaceResult{}
+	imgWidth, imgHeight, err := engine.GetImageSizeFromBytes(byt)
 	if err != nil {
 		return emptyResult, err
 	}
@@ -67,7 +70,14 @@ func (d GoogleVisionFaceDetector) Detect(imgPath string) (engine.FaceResult, err
 			Height:        int(h),
 			PercentWidth:  float64(w) / float64(imgWidth),
 			PercentHeight: float64(h) / float64(imgHeight),
-			Confidence:    r.DetectionConfidence * 100,
+			Confidence:    r.DetectionConfidence,
+			RawConfidence: r.DetectionConfidence,
+			Landmarks:     getLandmarks(r.Landmarks),
+			Pose: &engine.Pose{
+				Roll:  r.RollAngle,
+				Yaw:   r.PanAngle,
+				Pitch: r.TiltAngle,
+			},
 		}
 	}
 
@@ -76,3 +86,18 @@ func (d GoogleVisionFaceDetector) Detect(imgPath string) (engine.FaceResult, err
 		Faces:      faces,
 	}, nil
 }
+
+func getLandmarks(list []*SDK.Landmark) []engine.Landmark {
+	landmarks := make([]engine.Landmark, 0, len(list))
+	for _, l := range list {
+		if l.Position == nil {
+			continue
+		}
+		landmarks = append(landmarks, engine.Landmark{
+			Type: l.Type,
+			X:    l.Position.X,
+			Y:    l.Position.Y,
+		})
+	}
+	return landmarks
+}
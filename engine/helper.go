@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"bytes"
 	"image"
 	_ "image/jpeg"
 	"os"
@@ -20,3 +21,13 @@ func GetImageSize(imgPath string) (width, height int, err error) {
 
 	return c.Width, c.Height, nil
 }
+
+// GetImageSizeFromBytes returns the image size from image data.
+func GetImageSizeFromBytes(byt []byte) (width, height int, err error) {
+	c, _, err := image.DecodeConfig(bytes.NewReader(byt))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return c.Width, c.Height, nil
+}
@@ -1,7 +1,9 @@
 package pigo
 
 import (
+	"bytes"
 	"errors"
+	"image"
 	"io/ioutil"
 	"sync"
 
@@ -12,9 +14,11 @@ import (
 
 type Config interface {
 	GetPigoCascadeFile() string
+	GetPigoQThreshold() float64
 }
 
 type PigoFaceDetector struct {
+	// mu serializes RunCascade of the shared classifier. The methods must have pointer receivers not to copy it.
 	mu         sync.Mutex
 	classifier *pigo.Pigo
 
@@ -50,28 +54,33 @@ func (d *PigoFaceDetector) Init(conf engine.Config) error {
 	d.maxSize = 1000
 	d.shiftFactor = 0.1
 	d.scaleFactor = 1.1
-	d.qThresh = 5.0
+	d.qThresh = float32(c.GetPigoQThreshold())
 	return nil
 }
 
-func (d PigoFaceDetector) String() string {
+func (d *PigoFaceDetector) String() string {
 	return "pigo"
 }
 
-func (d PigoFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
-	imgWidth, imgHeight, err := engine.GetImageSize(imgPath)
+func (d *PigoFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
+	byt, err := ioutil.ReadFile(imgPath)
 	if err != nil {
 		return engine.FaceResult{}, err
 	}
+	return d.DetectBytes(byt)
+}
 
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	src, err := pigo.GetImage(imgPath)
+func (d *PigoFaceDetector) DetectBytes(byt []byte) (engine.FaceResult, error) {
+	img, _, err := image.Decode(bytes.NewReader(byt))
 	if err != nil {
 		return engine.FaceResult{}, err
 	}
+	imgWidth, imgHeight := img.Bounds().Dx(), img.Bounds().Dy()
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	src := pigo.ImgToNRGBA(img)
 	pixels := pigo.RgbToGrayscale(src)
 	cols, rows := src.Bounds().Max.X, src.Bounds().Max.Y
 	dets := d.classifier.RunCascade(pigo.CascadeParams{
@@ -106,7 +115,8 @@ func (d PigoFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
 			Height:        h,
 			PercentWidth:  float64(w) / float64(imgWidth),
 			PercentHeight: float64(h) / float64(imgHeight),
-			Confidence:    float64(det.Q),
+			Confidence:    normalizeQ(det.Q),
+			RawConfidence: float64(det.Q),
 		})
 	}
 
@@ -115,3 +125,14 @@ func (d PigoFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
 		Faces:      faces,
 	}, nil
 }
+
+// halfQ is the quality score normalized into 0.5.
+const halfQ = 20.0
+
+// normalizeQ maps the quality score of the detection, which has no upper limit, into between 0 and 1.
+func normalizeQ(q float32) float64 {
+	if q <= 0 {
+		return 0
+	}
+	return float64(q) / (float64(q) + halfQ)
+}
@@ -1,6 +1,8 @@
 package rekognition
 
 import (
+	"io/ioutil"
+
 	"github.com/evalphobia/aws-sdk-go-wrapper/config"
 	"github.com/evalphobia/aws-sdk-go-wrapper/rekognition"
 
@@ -26,17 +28,37 @@ func (d RekognitionFaceDetector) String() string {
 }
 
 func (d RekognitionFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
+	byt, err := ioutil.ReadFile(imgPath)
+	if err != nil {
+		return engine.FaceResult{}, err
+	}
+	return d.DetectBytes(byt)
+}
+
+func (d RekognitionFaceDetector) DetectBytes(byt []byte) (engine.FaceResult, error) {
 	emptyResult := engine.FaceResult{}
-	imgWidth, imgHeight, err := engine.GetImageSize(imgPath)
+	imgWidth, imgHeight, err := engine.GetImageSizeFromBytes(byt)
 	if err != nil {
 		return emptyResult, err
 	}
 
-	resp, err := d.client.DetectFacesFromLocalFile(imgPath)
+	resp, err := d.client.DetectFacesByBytes(byt)
 	if err != nil {
 		return emptyResult, err
 	}
+	return d.newFaceResult(resp, imgWidth, imgHeight), nil
+}
 
+// DetectS3Object detects faces by S3Object mode, which does not send the image data.
+func (d RekognitionFaceDetector) DetectS3Object(obj engine.S3Object) (engine.FaceResult, error) {
+	resp, err := d.client.DetectFacesByS3Object(obj.Bucket, obj.Key)
+	if err != nil {
+		return engine.FaceResult{}, err
+	}
+	return d.newFaceResult(resp, obj.Width, obj.Height), nil
+}
+
+func (d RekognitionFaceDetector) newFaceResult(resp *rekognition.FaceDetailResponse, imgWidth, imgHeight int) engine.FaceResult {
 	faces := make([]engine.FaceData, len(resp.List))
 	for i, r := range resp.List {
 		x := r.BoundingLeft * float64(imgWidth)
@@ -51,12 +73,31 @@ func (d RekognitionFaceDetector) Detect(imgPath string) (engine.FaceResult, erro
 			Height:        int(float64(imgHeight) * ph),
 			PercentWidth:  pw,
 			PercentHeight: ph,
-			Confidence:    r.FaceConfidence,
+			Confidence:    r.FaceConfidence / 100,
+			RawConfidence: r.FaceConfidence,
+		}
+		if r.HasLandmark {
+			landmarks := make([]engine.Landmark, len(r.Landmarks))
+			for j, l := range r.Landmarks {
+				landmarks[j] = engine.Landmark{
+					Type: l.Type,
+					X:    l.X * float64(imgWidth),
+					Y:    l.Y * float64(imgHeight),
+				}
+			}
+			faces[i].Landmarks = landmarks
+		}
+		if r.HasPose {
+			faces[i].Pose = &engine.Pose{
+				Roll:  r.PoseRoll,
+				Yaw:   r.PoseYaw,
+				Pitch: r.PosePitch,
+			}
 		}
 	}
 
 	return engine.FaceResult{
 		EngineName: d.String(),
 		Faces:      faces,
-	}, nil
+	}
 }
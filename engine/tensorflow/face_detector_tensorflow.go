@@ -17,11 +17,13 @@ import (
 
 type Config interface {
 	GetTensorFlowModelFile() string
+	GetTensorFlowScoreThreshold() float64
 }
 
 type TensorFlowFaceDetector struct {
-	graph   *tf.Graph
-	session *tf.Session
+	graph          *tf.Graph
+	session        *tf.Session
+	scoreThreshold float64
 }
 
 func (d *TensorFlowFaceDetector) Init(conf engine.Config) error {
@@ -48,6 +50,7 @@ func (d *TensorFlowFaceDetector) Init(conf engine.Config) error {
 
 	d.graph = graph
 	d.session = session
+	d.scoreThreshold = c.GetTensorFlowScoreThreshold()
 	return nil
 }
 
@@ -56,12 +59,30 @@ func (d TensorFlowFaceDetector) String() string {
 }
 
 func (d TensorFlowFaceDetector) Detect(imgPath string) (engine.FaceResult, error) {
-	imgWidth, imgHeight, err := engine.GetImageSize(imgPath)
+	f, err := os.Open(imgPath)
+	if err != nil {
+		return engine.FaceResult{}, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
 	if err != nil {
 		return engine.FaceResult{}, err
 	}
+	return d.detectImage(img)
+}
+
+func (d TensorFlowFaceDetector) DetectBytes(byt []byte) (engine.FaceResult, error) {
+	img, _, err := image.Decode(bytes.NewReader(byt))
+	if err != nil {
+		return engine.FaceResult{}, err
+	}
+	return d.detectImage(img)
+}
 
-	tensor, err := makeTensorFromFile(imgPath)
+func (d TensorFlowFaceDetector) detectImage(img image.Image) (engine.FaceResult, error) {
+	imgWidth, imgHeight := img.Bounds().Dx(), img.Bounds().Dy()
+	tensor, err := makeTensorFromImage(img)
 	if err != nil {
 		return engine.FaceResult{}, err
 	}
@@ -87,7 +108,8 @@ func (d TensorFlowFaceDetector) Detect(imgPath string) (engine.FaceResult, error
 			Height:        int(h),
 			PercentWidth:  pw,
 			PercentHeight: ph,
-			Confidence:    r.Score * 100,
+			Confidence:    r.Score,
+			RawConfidence: r.Score,
 		}
 	}
 
@@ -97,20 +119,9 @@ func (d TensorFlowFaceDetector) Detect(imgPath string) (engine.FaceResult, error
 	}, nil
 }
 
-func makeTensorFromFile(imgPath string) (*tf.Tensor, error) {
-	f, err := os.Open(imgPath)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	img, _, err := image.Decode(f)
-	if err != nil {
-		return nil, err
-	}
-
+func makeTensorFromImage(img image.Image) (*tf.Tensor, error) {
 	var buf bytes.Buffer
-	err = bmp.Encode(&buf, img)
+	err := bmp.Encode(&buf, img)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +192,7 @@ func (d TensorFlowFaceDetector) detectFaces(tensor *tf.Tensor) ([]tfFace, error)
 			PercentMaxX: float64(box[3]),
 			Score:       float64(score),
 		}
-		if f.hasConfidence() {
+		if f.Score > d.scoreThreshold {
 			results = append(results, f)
 		}
 	}
@@ -195,8 +206,3 @@ type tfFace struct {
 	PercentMaxY float64
 	Score       float64
 }
-
-func (f tfFace) hasConfidence() bool {
-	const confidenceBorder = 0.5
-	return f.Score > confidenceBorder
-}
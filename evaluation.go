@@ -0,0 +1,299 @@
+package fda
+
+import (
+	"image"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// evalTruth is the ground truth faces by the path of the image.
+type evalTruth struct {
+	Faces map[string][]engine.FaceData
+	// regions where the detected faces are neither true nor false positive. (e.g. invalid faces of WIDER FACE)
+	Ignore map[string][]engine.FaceData
+}
+
+// evalImage is the ground truth and the detected faces of an engine on an image.
+type evalImage struct {
+	Path   string
+	Truth  []engine.FaceData
+	Ignore []engine.FaceData
+	Faces  []engine.FaceData
+	// size of the image in pixels. (zero means unknown)
+	Size image.Point
+}
+
+// getTruthFaces returns the faces of the ground truth engine by the path.
+// The images without the result of the engine are not labeled.
+func getTruthFaces(result *detectResult, truthEngine string) map[string][]engine.FaceData {
+	truth := make(map[string][]engine.FaceData, len(result.Rows))
+	for _, row := range result.Rows {
+		data, ok := row.Results[truthEngine]
+		if !ok {
+			continue
+		}
+		if _, ok := truth[row.Path]; !ok {
+			truth[row.Path] = data.Faces
+		}
+	}
+	return truth
+}
+
+// getImageSizes returns the size of the labeled images by the path.
+// The size is got from width and height columns, the ratio of the faces to the image, or the image file in this order.
+func getImageSizes(rows []detectResultRow, truth map[string][]engine.FaceData) map[string]image.Point {
+	sizes := make(map[string]image.Point, len(truth))
+	for _, row := range rows {
+		if _, ok := truth[row.Path]; !ok {
+			continue
+		}
+		if _, ok := sizes[row.Path]; ok {
+			continue
+		}
+		if size, ok := getImageSize(row, truth[row.Path]); ok {
+			sizes[row.Path] = size
+		}
+	}
+	return sizes
+}
+
+func getImageSize(row detectResultRow, truth []engine.FaceData) (image.Point, bool) {
+	w, errW := strconv.Atoi(row.Line[colWidth])
+	h, errH := strconv.Atoi(row.Line[colHeight])
+	if errW == nil && errH == nil && w > 0 && h > 0 {
+		return image.Pt(w, h), true
+	}
+
+	for _, f := range truth {
+		if size, ok := getImageSizeByFace(f); ok {
+			return size, true
+		}
+	}
+	for _, data := range row.Results {
+		for _, f := range data.Faces {
+			if size, ok := getImageSizeByFace(f); ok {
+				return size, true
+			}
+		}
+	}
+
+	conf, _, err := sniffImage(row.Path)
+	if err != nil {
+		return image.Point{}, false
+	}
+	return image.Pt(conf.Width, conf.Height), true
+}
+
+// getImageSizeByFace returns the size of the image from the pixels and the ratio of the face.
+func getImageSizeByFace(f engine.FaceData) (image.Point, bool) {
+	if f.PercentWidth <= 0 || f.PercentHeight <= 0 || f.Width <= 0 || f.Height <= 0 {
+		return image.Point{}, false
+	}
+	return image.Pt(
+		int(math.Round(float64(f.Width)/f.PercentWidth)),
+		int(math.Round(float64(f.Height)/f.PercentHeight)),
+	), true
+}
+
+// fillTruthPercent sets the ratio of the ground truth faces to the image, for the ground truth which has only pixels.
+func fillTruthPercent(truth map[string][]engine.FaceData, sizes map[string]image.Point) {
+	for path, faces := range truth {
+		size, ok := sizes[path]
+		if !ok || size.X == 0 || size.Y == 0 {
+			continue
+		}
+		for i, f := range faces {
+			if f.PercentWidth != 0 || f.PercentHeight != 0 {
+				continue
+			}
+			faces[i].PercentWidth = float64(f.Width) / float64(size.X)
+			faces[i].PercentHeight = float64(f.Height) / float64(size.Y)
+		}
+	}
+}
+
+// getEvalImages returns the images which have both of the ground truth and the result of the engine.
+func getEvalImages(rows []detectResultRow, truth evalTruth, sizes map[string]image.Point, e string) []evalImage {
+	var images []evalImage
+	for _, row := range rows {
+		faces, ok := truth.Faces[row.Path]
+		if !ok {
+			continue
+		}
+		data, ok := row.Results[e]
+		if !ok {
+			continue
+		}
+		images = append(images, evalImage{
+			Path:   row.Path,
+			Truth:  faces,
+			Ignore: truth.Ignore[row.Path],
+			Faces:  data.Faces,
+			Size:   sizes[row.Path],
+		})
+	}
+	return images
+}
+
+// removeIgnoredFaces removes the detected faces which are not matched with the ground truth but overlap the ignore regions.
+func removeIgnoredFaces(images []evalImage, iou float64) []evalImage {
+	result := make([]evalImage, len(images))
+	for i, img := range images {
+		result[i] = img
+		if len(img.Ignore) == 0 {
+			continue
+		}
+
+		faces := make([]engine.FaceData, 0, len(img.Faces))
+		for j, m := range matchByConfidence(img.Faces, img.Truth, iou) {
+			if m < 0 && isIgnoredFace(img.Faces[j], img.Ignore, iou) {
+				continue
+			}
+			faces = append(faces, img.Faces[j])
+		}
+		result[i].Faces = faces
+		result[i].Ignore = nil
+	}
+	return result
+}
+
+// isIgnoredFace checks the face overlaps any of the ignore regions. A region can ignore multiple faces, like a crowd.
+func isIgnoredFace(f engine.FaceData, ignore []engine.FaceData, iou float64) bool {
+	for _, r := range ignore {
+		if v := f.IoU(r); v >= iou && v > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sweepPoint is the metrics of the faces whose confidence is the threshold or higher.
+type sweepPoint struct {
+	Threshold  float64 `json:"threshold"`
+	TP         int     `json:"tp"`
+	FP         int     `json:"fp"`
+	FN         int     `json:"fn"`
+	Precision  float64 `json:"precision"`
+	Recall     float64 `json:"recall"`
+	F1         float64 `json:"f1"`
+	FPPerImage float64 `json:"fp_per_image"`
+}
+
+// engineEval is the evaluation of an engine against the ground truth.
+type engineEval struct {
+	Engine     string `json:"engine"`
+	Images     int    `json:"images"`
+	TruthFaces int    `json:"truth_faces"`
+	Faces      int    `json:"faces"`
+	// average precision. (area under the interpolated precision-recall curve)
+	AP float64 `json:"ap"`
+	// the point of the best F1.
+	Best sweepPoint `json:"best"`
+	// the point of all detected faces.
+	All sweepPoint `json:"all"`
+	// the points of each confidence in descending order.
+	Curve []sweepPoint `json:"curve"`
+}
+
+// scoredFace is a detected face with the matching result.
+type scoredFace struct {
+	Confidence float64
+	TP         bool
+}
+
+// evaluateEngine sweeps the confidence thresholds of the detected faces.
+// The detected faces in the ignore regions are not counted.
+func evaluateEngine(e string, images []evalImage, iou float64) engineEval {
+	images = removeIgnoredFaces(images, iou)
+	ev := engineEval{
+		Engine: e,
+		Images: len(images),
+	}
+
+	for _, img := range images {
+		ev.TruthFaces += len(img.Truth)
+		ev.Faces += len(img.Faces)
+	}
+	scored := getScoredFaces(images, iou)
+
+	tp, fp := 0, 0
+	for i, s := range scored {
+		if s.TP {
+			tp++
+		} else {
+			fp++
+		}
+		// the faces of the same confidence are in the same point.
+		if i+1 < len(scored) && scored[i+1].Confidence == s.Confidence {
+			continue
+		}
+		ev.Curve = append(ev.Curve, newSweepPoint(s.Confidence, tp, fp, ev.TruthFaces, ev.Images))
+	}
+
+	if len(ev.Curve) == 0 {
+		ev.All = newSweepPoint(0, 0, 0, ev.TruthFaces, ev.Images)
+		ev.Best = ev.All
+		return ev
+	}
+	ev.All = ev.Curve[len(ev.Curve)-1]
+	ev.Best = ev.Curve[0]
+	for _, p := range ev.Curve {
+		if p.F1 > ev.Best.F1 {
+			ev.Best = p
+		}
+	}
+	ev.AP = averagePrecision(ev.Curve)
+	return ev
+}
+
+// getScoredFaces returns the detected faces with the matching result in descending order of confidence.
+func getScoredFaces(images []evalImage, iou float64) []scoredFace {
+	var scored []scoredFace
+	for _, img := range images {
+		for i, m := range matchByConfidence(img.Faces, img.Truth, iou) {
+			scored = append(scored, scoredFace{
+				Confidence: img.Faces[i].Confidence,
+				TP:         m >= 0,
+			})
+		}
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Confidence > scored[j].Confidence
+	})
+	return scored
+}
+
+func newSweepPoint(threshold float64, tp, fp, truth, images int) sweepPoint {
+	p := sweepPoint{
+		Threshold: threshold,
+		TP:        tp,
+		FP:        fp,
+		FN:        truth - tp,
+		Precision: ratio(tp, tp+fp),
+		Recall:    ratio(tp, truth),
+	}
+	p.F1 = f1Score(p.Precision, p.Recall)
+	p.FPPerImage = ratio(fp, images)
+	return p
+}
+
+// averagePrecision returns the area under the precision-recall curve,
+// where the precision is interpolated by the max precision of the higher recalls.
+func averagePrecision(curve []sweepPoint) float64 {
+	ap := 0.0
+	maxPrecision := 0.0
+	for i := len(curve) - 1; i >= 0; i-- {
+		if curve[i].Precision > maxPrecision {
+			maxPrecision = curve[i].Precision
+		}
+		prevRecall := 0.0
+		if i > 0 {
+			prevRecall = curve[i-1].Recall
+		}
+		ap += (curve[i].Recall - prevRecall) * maxPrecision
+	}
+	return ap
+}
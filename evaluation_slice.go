@@ -0,0 +1,266 @@
+package fda
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// names of evaluation slices.
+const (
+	sliceSize       = "size"
+	sliceResolution = "resolution"
+	sliceAttribute  = "attribute"
+)
+
+// bounds of the long side of image resolution buckets in pixels.
+var resolutionBounds = []float64{320, 640, 1280, 1920}
+
+// sliceBucket is a range of the value of evaluation slice.
+type sliceBucket struct {
+	Label string
+	Min   float64
+	// zero means no upper limit.
+	Max float64
+}
+
+func (b sliceBucket) Contains(v float64) bool {
+	return v >= b.Min && (b.Max == 0 || v < b.Max)
+}
+
+// newSliceBuckets returns the buckets split by the bounds.
+func newSliceBuckets(bounds []float64, format func(float64) string) []sliceBucket {
+	buckets := make([]sliceBucket, 0, len(bounds)+1)
+	lower := 0.0
+	for _, upper := range bounds {
+		buckets = append(buckets, sliceBucket{
+			Label: format(lower) + "-" + format(upper),
+			Min:   lower,
+			Max:   upper,
+		})
+		lower = upper
+	}
+	return append(buckets, sliceBucket{
+		Label: ">=" + format(lower),
+		Min:   lower,
+	})
+}
+
+// newLevelBuckets returns the buckets of each level from zero.
+func newLevelBuckets(labels ...string) []sliceBucket {
+	buckets := make([]sliceBucket, len(labels))
+	for i, label := range labels {
+		buckets[i] = sliceBucket{
+			Label: label,
+			Min:   float64(i),
+			Max:   float64(i + 1),
+		}
+	}
+	buckets[len(labels)-1].Max = 0
+	return buckets
+}
+
+// evalSlice splits the ground truth faces into the buckets by the value.
+type evalSlice struct {
+	Name    string
+	Buckets []sliceBucket
+	// value of the ground truth face. false means the face is not sliced.
+	truthValue func(img evalImage, f engine.FaceData) (float64, bool)
+	// value of the detected face. nil means the detected faces are not sliced, and precision is not available.
+	faceValue func(img evalImage, f engine.FaceData) (float64, bool)
+}
+
+func (s evalSlice) bucketIndex(v float64) int {
+	for i, b := range s.Buckets {
+		if b.Contains(v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// getEvalSlices returns the slices by the names. (size, resolution, attribute)
+// The slices of attributes are returned only when the ground truth has the attributes.
+func getEvalSlices(names []string, truth map[string][]engine.FaceData) ([]evalSlice, error) {
+	var slices []evalSlice
+	for _, name := range names {
+		switch name {
+		case sliceSize:
+			slices = append(slices, evalSlice{
+				Name:       "face_size",
+				Buckets:    newSliceBuckets(faceSizeBounds, formatRatio),
+				truthValue: getFaceSizeValue,
+				faceValue:  getFaceSizeValue,
+			})
+		case sliceResolution:
+			slices = append(slices, evalSlice{
+				Name: "resolution",
+				Buckets: newSliceBuckets(resolutionBounds, func(v float64) string {
+					return strconv.FormatFloat(v, 'f', -1, 64)
+				}),
+				truthValue: getResolutionValue,
+				faceValue:  getResolutionValue,
+			})
+		case sliceAttribute:
+			if !hasTruthAttributes(truth) {
+				continue
+			}
+			slices = append(slices,
+				newAttributeSlice("blur", func(a engine.Attributes) int { return a.Blur }, "clear", "normal", "heavy"),
+				newAttributeSlice("occlusion", func(a engine.Attributes) int { return a.Occlusion }, "no", "partial", "heavy"),
+				newAttributeSlice("pose", func(a engine.Attributes) int { return a.Pose }, "typical", "atypical"),
+				newAttributeSlice("illumination", func(a engine.Attributes) int { return a.Illumination }, "normal", "extreme"),
+			)
+		default:
+			return nil, fmt.Errorf("unknown slice: [%s]", name)
+		}
+	}
+	return slices, nil
+}
+
+func newAttributeSlice(name string, fn func(engine.Attributes) int, labels ...string) evalSlice {
+	return evalSlice{
+		Name:    name,
+		Buckets: newLevelBuckets(labels...),
+		truthValue: func(img evalImage, f engine.FaceData) (float64, bool) {
+			if !f.HasAttributes() {
+				return 0, false
+			}
+			return float64(fn(*f.Attributes)), true
+		},
+	}
+}
+
+// getFaceSizeValue returns the larger ratio of the width and height to the image.
+func getFaceSizeValue(img evalImage, f engine.FaceData) (float64, bool) {
+	v := math.Max(f.PercentWidth, f.PercentHeight)
+	return v, v > 0
+}
+
+// getResolutionValue returns the long side of the image.
+func getResolutionValue(img evalImage, f engine.FaceData) (float64, bool) {
+	v := math.Max(float64(img.Size.X), float64(img.Size.Y))
+	return v, v > 0
+}
+
+func hasTruthAttributes(truth map[string][]engine.FaceData) bool {
+	for _, faces := range truth {
+		for _, f := range faces {
+			if f.HasAttributes() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sliceEval is the evaluation of an engine on a bucket of the slice.
+type sliceEval struct {
+	Slice  string `json:"slice"`
+	Bucket string `json:"bucket"`
+	Engine string `json:"engine"`
+	// number of the images which have the faces in the bucket.
+	Images     int `json:"images"`
+	TruthFaces int `json:"truth_faces"`
+	TP         int `json:"tp"`
+	// false positive is not counted when precision is not available.
+	FP     int     `json:"fp"`
+	FN     int     `json:"fn"`
+	Recall float64 `json:"recall"`
+	// precision and F1 are not available for the slices which detected faces don't have the value. (e.g. occlusion)
+	Precision *float64 `json:"precision,omitempty"`
+	F1        *float64 `json:"f1,omitempty"`
+}
+
+// evaluateSlice evaluates all detected faces of the engine on each bucket of the slice.
+// The detected faces matched with the ground truth faces in other buckets are ignored,
+// and the unmatched detected faces are false positive of the bucket of their own value.
+// The detected faces in the ignore regions are not counted.
+func evaluateSlice(e string, images []evalImage, s evalSlice, iou float64) []sliceEval {
+	images = removeIgnoredFaces(images, iou)
+	evals := make([]sliceEval, len(s.Buckets))
+	for i, b := range s.Buckets {
+		evals[i] = sliceEval{
+			Slice:  s.Name,
+			Bucket: b.Label,
+			Engine: e,
+		}
+	}
+
+	for _, img := range images {
+		matchedTruth := make([]bool, len(img.Truth))
+		matched := matchByConfidence(img.Faces, img.Truth, iou)
+		for _, m := range matched {
+			if m >= 0 {
+				matchedTruth[m] = true
+			}
+		}
+
+		inImage := make([]bool, len(s.Buckets))
+		for i, f := range img.Truth {
+			v, ok := s.truthValue(img, f)
+			if !ok {
+				continue
+			}
+			b := s.bucketIndex(v)
+			if b < 0 {
+				continue
+			}
+			inImage[b] = true
+			evals[b].TruthFaces++
+			if matchedTruth[i] {
+				evals[b].TP++
+			} else {
+				evals[b].FN++
+			}
+		}
+		if s.faceValue != nil {
+			for i, f := range img.Faces {
+				if matched[i] >= 0 {
+					continue
+				}
+				v, ok := s.faceValue(img, f)
+				if !ok {
+					continue
+				}
+				b := s.bucketIndex(v)
+				if b < 0 {
+					continue
+				}
+				inImage[b] = true
+				evals[b].FP++
+			}
+		}
+		for b, ok := range inImage {
+			if ok {
+				evals[b].Images++
+			}
+		}
+	}
+
+	for i := range evals {
+		ev := &evals[i]
+		ev.Recall = ratio(ev.TP, ev.TruthFaces)
+		if s.faceValue != nil {
+			precision := ratio(ev.TP, ev.TP+ev.FP)
+			f1 := f1Score(precision, ev.Recall)
+			ev.Precision = &precision
+			ev.F1 = &f1
+		}
+	}
+	return evals
+}
+
+// parseSliceNames returns the names of comma separated slices.
+func parseSliceNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
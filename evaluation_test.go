@@ -0,0 +1,133 @@
+package fda
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+func newTestFace(x, y int, confidence float64) engine.FaceData {
+	return engine.FaceData{X: x, Y: y, Width: 10, Height: 10, Confidence: confidence}
+}
+
+// testEvalImages are 4 ground truth faces in 2 images, and the detected faces are
+// 0.9:TP, 0.8:FP and TP (tie), 0.7:TP, 0.6:FP (duplicate of the first face), 0.3:FP.
+var testEvalImages = []evalImage{
+	{
+		Path:  "a.jpg",
+		Truth: []engine.FaceData{newTestFace(0, 0, 0), newTestFace(20, 0, 0), newTestFace(40, 0, 0)},
+		Faces: []engine.FaceData{
+			newTestFace(0, 0, 0.9),
+			newTestFace(100, 100, 0.8),
+			newTestFace(20, 0, 0.7),
+			newTestFace(1, 1, 0.6),
+		},
+	},
+	{
+		Path:  "b.jpg",
+		Truth: []engine.FaceData{newTestFace(0, 0, 0)},
+		Faces: []engine.FaceData{
+			newTestFace(0, 0, 0.8),
+			newTestFace(50, 50, 0.3),
+		},
+	},
+}
+
+func floatEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestGetScoredFaces(t *testing.T) {
+	want := []scoredFace{
+		{Confidence: 0.9, TP: true},
+		{Confidence: 0.8, TP: false},
+		{Confidence: 0.8, TP: true},
+		{Confidence: 0.7, TP: true},
+		{Confidence: 0.6, TP: false},
+		{Confidence: 0.3, TP: false},
+	}
+	if got := getScoredFaces(testEvalImages, 0.5); !reflect.DeepEqual(got, want) {
+		t.Errorf("getScoredFaces = %v, want %v", got, want)
+	}
+}
+
+func TestEvaluateEngine(t *testing.T) {
+	ev := evaluateEngine("test", testEvalImages, 0.5)
+	if ev.Images != 2 || ev.TruthFaces != 4 || ev.Faces != 6 {
+		t.Errorf("images, truth, faces = %d, %d, %d, want 2, 4, 6", ev.Images, ev.TruthFaces, ev.Faces)
+	}
+
+	// the faces of the same confidence 0.8 are in the same point.
+	want := []sweepPoint{
+		{Threshold: 0.9, TP: 1, FP: 0, FN: 3, Precision: 1, Recall: 0.25, F1: 0.4, FPPerImage: 0},
+		{Threshold: 0.8, TP: 2, FP: 1, FN: 2, Precision: 2.0 / 3, Recall: 0.5, F1: 4.0 / 7, FPPerImage: 0.5},
+		{Threshold: 0.7, TP: 3, FP: 1, FN: 1, Precision: 0.75, Recall: 0.75, F1: 0.75, FPPerImage: 0.5},
+		{Threshold: 0.6, TP: 3, FP: 2, FN: 1, Precision: 0.6, Recall: 0.75, F1: 2.0 / 3, FPPerImage: 1},
+		{Threshold: 0.3, TP: 3, FP: 3, FN: 1, Precision: 0.5, Recall: 0.75, F1: 0.6, FPPerImage: 1.5},
+	}
+	if len(ev.Curve) != len(want) {
+		t.Fatalf("curve = %d points, want %d: %+v", len(ev.Curve), len(want), ev.Curve)
+	}
+	for i, w := range want {
+		p := ev.Curve[i]
+		if p.Threshold != w.Threshold || p.TP != w.TP || p.FP != w.FP || p.FN != w.FN ||
+			!floatEqual(p.Precision, w.Precision) || !floatEqual(p.Recall, w.Recall) ||
+			!floatEqual(p.F1, w.F1) || !floatEqual(p.FPPerImage, w.FPPerImage) {
+			t.Errorf("curve[%d] = %+v, want %+v", i, p, w)
+		}
+	}
+
+	if ev.Best.Threshold != 0.7 {
+		t.Errorf("best threshold = %v, want 0.7 of the best F1", ev.Best.Threshold)
+	}
+	if ev.All.Threshold != 0.3 || ev.All.FP != 3 {
+		t.Errorf("all = %+v, want the point of the lowest confidence", ev.All)
+	}
+	// 0.25*1 + 0.25*0.75 (interpolated from 2/3) + 0.25*0.75
+	if !floatEqual(ev.AP, 0.625) {
+		t.Errorf("AP = %v, want 0.625", ev.AP)
+	}
+}
+
+func TestEvaluateEngineNoFaces(t *testing.T) {
+	images := []evalImage{{Path: "a.jpg", Truth: []engine.FaceData{newTestFace(0, 0, 0)}}}
+	ev := evaluateEngine("test", images, 0.5)
+	if ev.AP != 0 || len(ev.Curve) != 0 || ev.All.FN != 1 || ev.Best != ev.All {
+		t.Errorf("evaluation = %+v, want AP 0 with all faces missed", ev)
+	}
+}
+
+func TestAveragePrecision(t *testing.T) {
+	tests := []struct {
+		name  string
+		curve []sweepPoint
+		want  float64
+	}{
+		{name: "empty", curve: nil, want: 0},
+		{
+			name:  "perfect",
+			curve: []sweepPoint{{Precision: 1, Recall: 0.5}, {Precision: 1, Recall: 1}},
+			want:  1,
+		},
+		{
+			name:  "no interpolation",
+			curve: []sweepPoint{{Precision: 1, Recall: 0.5}, {Precision: 0.5, Recall: 0.5}},
+			want:  0.5,
+		},
+		{
+			// the precision 0.5 at recall 0.5 is interpolated by 0.75 of the higher recall.
+			name:  "interpolation",
+			curve: []sweepPoint{{Precision: 1, Recall: 0.25}, {Precision: 0.5, Recall: 0.5}, {Precision: 0.75, Recall: 1}},
+			want:  0.25 + 0.25*0.75 + 0.5*0.75,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := averagePrecision(tt.curve); !floatEqual(got, tt.want) {
+				t.Errorf("averagePrecision = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
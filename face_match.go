@@ -0,0 +1,174 @@
+package fda
+
+import (
+	"sort"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// fusedFace is a face merged from the faces of multiple engines.
+type fusedFace struct {
+	engine.FaceData
+	Engines []string
+	Members []engine.FaceData
+}
+
+// Votes returns the number of engines which detected the face.
+func (f fusedFace) Votes() int {
+	return len(f.Engines)
+}
+
+// fuseFaces merges the overlapped faces of the engines by IoU,
+// and returns the faces detected by at least minVotes engines.
+// A face is merged into the cluster with the highest IoU which does not have a face of the same engine yet.
+func fuseFaces(results []engine.FaceResult, iouThreshold float64, minVotes int) []fusedFace {
+	var clusters []*fusedFace
+	for _, r := range results {
+		used := make(map[int]bool)
+		for _, f := range r.Faces {
+			best, bestIoU := -1, iouThreshold
+			for i, c := range clusters {
+				if used[i] {
+					continue
+				}
+				if iou := c.FaceData.IoU(f); iou >= bestIoU && iou > 0 {
+					best, bestIoU = i, iou
+				}
+			}
+
+			if best < 0 {
+				clusters = append(clusters, &fusedFace{FaceData: f})
+				best = len(clusters) - 1
+			}
+			used[best] = true
+
+			c := clusters[best]
+			c.Engines = append(c.Engines, r.EngineName)
+			c.Members = append(c.Members, f)
+			c.FaceData = averageFace(c.Members)
+		}
+	}
+
+	faces := make([]fusedFace, 0, len(clusters))
+	for _, c := range clusters {
+		if c.Votes() < minVotes {
+			continue
+		}
+		faces = append(faces, *c)
+	}
+	sort.SliceStable(faces, func(i, j int) bool {
+		if faces[i].Y != faces[j].Y {
+			return faces[i].Y < faces[j].Y
+		}
+		return faces[i].X < faces[j].X
+	})
+	return faces
+}
+
+// averageFace returns the face of the averaged rectangle.
+func averageFace(faces []engine.FaceData) engine.FaceData {
+	var x, y, w, h, pw, ph, conf float64
+	for _, f := range faces {
+		x += float64(f.X)
+		y += float64(f.Y)
+		w += float64(f.Width)
+		h += float64(f.Height)
+		pw += f.PercentWidth
+		ph += f.PercentHeight
+		conf += f.Confidence
+	}
+
+	n := float64(len(faces))
+	return engine.FaceData{
+		X:             int(x/n + 0.5),
+		Y:             int(y/n + 0.5),
+		Width:         int(w/n + 0.5),
+		Height:        int(h/n + 0.5),
+		PercentWidth:  pw / n,
+		PercentHeight: ph / n,
+		Confidence:    conf / n,
+	}
+}
+
+// faceMatch is a pair of the matched faces by the indexes.
+type faceMatch struct {
+	A   int
+	B   int
+	IoU float64
+}
+
+// matchFaces matches the faces of a and b one-to-one by IoU, greedily from the most overlapped pair.
+// It returns the matched pairs and the indexes of unmatched faces of a and b.
+func matchFaces(a, b []engine.FaceData, iouThreshold float64) (matches []faceMatch, onlyA, onlyB []int) {
+	var candidates []faceMatch
+	for i, fa := range a {
+		for j, fb := range b {
+			if iou := fa.IoU(fb); iou >= iouThreshold && iou > 0 {
+				candidates = append(candidates, faceMatch{A: i, B: j, IoU: iou})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].IoU > candidates[j].IoU
+	})
+
+	usedA := make(map[int]bool)
+	usedB := make(map[int]bool)
+	for _, c := range candidates {
+		if usedA[c.A] || usedB[c.B] {
+			continue
+		}
+		usedA[c.A] = true
+		usedB[c.B] = true
+		matches = append(matches, c)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].A < matches[j].A
+	})
+
+	for i := range a {
+		if !usedA[i] {
+			onlyA = append(onlyA, i)
+		}
+	}
+	for j := range b {
+		if !usedB[j] {
+			onlyB = append(onlyB, j)
+		}
+	}
+	return matches, onlyA, onlyB
+}
+
+// matchByConfidence matches the detected faces with the ground truth faces in descending order of confidence,
+// and returns the index of the matched ground truth face of each detected face. (-1 means false positive)
+// A detected face is matched with the most overlapped ground truth face which is not matched yet.
+func matchByConfidence(faces, truth []engine.FaceData, iouThreshold float64) []int {
+	order := make([]int, len(faces))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return faces[order[i]].Confidence > faces[order[j]].Confidence
+	})
+
+	matched := make([]int, len(faces))
+	used := make([]bool, len(truth))
+	for _, i := range order {
+		matched[i] = -1
+		best, bestIoU := -1, iouThreshold
+		for j, t := range truth {
+			if used[j] {
+				continue
+			}
+			if iou := faces[i].IoU(t); iou >= bestIoU && iou > 0 {
+				best, bestIoU = j, iou
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		used[best] = true
+		matched[i] = best
+	}
+	return matched
+}
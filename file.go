@@ -1,9 +1,11 @@
 package fda
 
 import (
+	"encoding/csv"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 )
 
 // FileHandler handles list file.
@@ -34,3 +36,71 @@ func (f *FileHandler) WriteAll(lines []string) error {
 	fp.WriteString(strings.Join(lines, "\n"))
 	return fp.Sync()
 }
+
+// WriteCSV writes records into file as CSV format.
+func (f *FileHandler) WriteCSV(records [][]string) error {
+	fp, err := os.Create(f.file)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	w := csv.NewWriter(fp)
+	if err := w.WriteAll(records); err != nil {
+		return err
+	}
+	return fp.Sync()
+}
+
+// CSVWriter writes CSV records into file one by one.
+// It is safe for concurrent use.
+type CSVWriter struct {
+	mu sync.Mutex
+	fp *os.File
+	w  *csv.Writer
+}
+
+// NewCSVWriter creates the file and returns initialized *CSVWriter.
+func NewCSVWriter(file string) (*CSVWriter, error) {
+	if _, err := NewFileHandler(file); err != nil {
+		return nil, err
+	}
+
+	fp, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	return &CSVWriter{
+		fp: fp,
+		w:  csv.NewWriter(fp),
+	}, nil
+}
+
+// Write writes a record and flushes it into file.
+func (w *CSVWriter) Write(record []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.w.Write(record); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// Close closes the file.
+func (w *CSVWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		w.fp.Close()
+		return err
+	}
+	if err := w.fp.Sync(); err != nil {
+		w.fp.Close()
+		return err
+	}
+	return w.fp.Close()
+}
@@ -57,3 +57,27 @@ func (f fileType) isTarget(path string) bool {
 	_, ok := f.types[ext]
 	return ok
 }
+
+// file extensions of image formats.
+var formatExtensions = map[string][]string{
+	"jpeg": {".jpg", ".jpeg"},
+	"png":  {".png"},
+	"gif":  {".gif"},
+	"bmp":  {".bmp"},
+	"webp": {".webp"},
+	"tiff": {".tif", ".tiff"},
+}
+
+// isTargetFormat checks the image format detected from the file content.
+func (f fileType) isTargetFormat(format string) bool {
+	if f.includeAll {
+		return true
+	}
+
+	for _, ext := range formatExtensions[format] {
+		if _, ok := f.types[ext]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -8,14 +8,16 @@ require (
 	github.com/Azure/go-autorest/autorest/validation v0.1.0 // indirect
 	github.com/Bowery/prompt v0.0.0-20190419144237-972d0ceb96f5 // indirect
 	github.com/Kagami/go-face v0.0.0-20190308235700-97bf298c303b
-	github.com/aws/aws-sdk-go v1.20.16 // indirect
+	github.com/aws/aws-sdk-go v1.20.16
 	github.com/esimov/pigo v1.1.0
 	github.com/evalphobia/aws-sdk-go-wrapper v1.6.4
 	github.com/evalphobia/go-face-plusplus v0.0.2
 	github.com/evalphobia/google-api-go-wrapper v0.6.0
 	github.com/evalphobia/httpwrapper v0.1.2 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	github.com/golang/protobuf v1.3.1
 	github.com/labstack/gommon v0.2.9 // indirect
+	github.com/mattn/go-sqlite3 v1.11.0
 	github.com/mkideal/cli v0.0.3
 	github.com/mkideal/pkg v0.0.0-20170503154153-3e188c9e7ecc // indirect
 	github.com/nbio/st v0.0.0-20140626010706-e9e8d9816f32 // indirect
@@ -24,6 +26,7 @@ require (
 	gocv.io/x/gocv v0.20.0
 	golang.org/x/image v0.0.0-20190703141733-d6a02ce849c9
 	google.golang.org/api v0.7.0
+	google.golang.org/grpc v1.20.1
 	gopkg.in/eapache/go-resiliency.v1 v1.2.0 // indirect
 	gopkg.in/h2non/gentleman-retry.v1 v1.0.0 // indirect
 	gopkg.in/h2non/gentleman.v1 v1.0.4 // indirect
@@ -0,0 +1,120 @@
+package fda
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// openImageFile opens the image file, the entry of archive file like 'images.zip!/train/001.jpg'
+// or the object of S3 like 's3://bucket/train/001.jpg'.
+func openImageFile(path string) (io.ReadCloser, error) {
+	var byt []byte
+	var err error
+	switch {
+	case isS3Path(path):
+		byt, err = s3Objects.ReadFile(path)
+	case isArchivePath(path):
+		byt, _, err = archives.ReadFile(path)
+	default:
+		return os.Open(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(byt)), nil
+}
+
+// statImageFile returns the file info of the image file, the entry of archive file or the object of S3.
+func statImageFile(path string) (os.FileInfo, error) {
+	switch {
+	case isS3Path(path):
+		return s3Objects.Stat(path)
+	case isArchivePath(path):
+		_, info, err := archives.ReadFile(path)
+		return info, err
+	}
+	return os.Stat(path)
+}
+
+// loadImage reads and decodes image file.
+func loadImage(path string) (image.Image, error) {
+	f, err := openImageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// saveJPEG encodes image into JPEG file.
+func saveJPEG(path string, img image.Image) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := jpeg.Encode(out, img, &jpeg.Options{Quality: 95}); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// saveImage encodes image into PNG or JPEG file by the file extension.
+func saveImage(path string, img image.Image) error {
+	if strings.ToLower(filepath.Ext(path)) != ".png" {
+		return saveJPEG(path, img)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := png.Encode(out, img); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// toRGBA returns a copy of image as *image.RGBA.
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, src, bounds.Min, draw.Src)
+	return img
+}
+
+// resizeImage scales the image so that the longer side is the size in pixels.
+func resizeImage(src image.Image, size int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w >= h {
+		w, h = size, h*size/w
+	} else {
+		w, h = w*size/h, size
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+	return dst
+}
@@ -0,0 +1,177 @@
+package fda
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// metadata columns of list CSV.
+const (
+	colWidth  = "width"
+	colHeight = "height"
+	colFormat = "format"
+	colSize   = "size"
+	colSHA256 = "sha256"
+	colMtime  = "mtime"
+)
+
+var imageMetaHeader = []string{colWidth, colHeight, colFormat, colSize, colSHA256, colMtime}
+
+// imageMeta is metadata of image file.
+type imageMeta struct {
+	Width  int
+	Height int
+	// format name of the decoder. (e.g. jpeg, png, gif)
+	Format  string
+	Size    int64
+	SHA256  string
+	ModTime time.Time
+}
+
+// sniffImage detects the actual image format from the file header.
+func sniffImage(path string) (image.Config, string, error) {
+	f, err := openImageFile(path)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer f.Close()
+
+	return image.DecodeConfig(f)
+}
+
+// validateImage decodes whole image to detect broken or truncated files.
+func validateImage(path string) error {
+	_, err := loadImage(path)
+	return err
+}
+
+// readImageMeta reads metadata of the image file.
+func readImageMeta(path string) (imageMeta, error) {
+	info, err := statImageFile(path)
+	if err != nil {
+		return imageMeta{}, err
+	}
+
+	conf, format, err := sniffImage(path)
+	if err != nil {
+		return imageMeta{}, err
+	}
+
+	f, err := openImageFile(path)
+	if err != nil {
+		return imageMeta{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return imageMeta{}, err
+	}
+
+	return imageMeta{
+		Width:   conf.Width,
+		Height:  conf.Height,
+		Format:  format,
+		Size:    info.Size(),
+		SHA256:  hex.EncodeToString(h.Sum(nil)),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// Columns returns the values in the order of imageMetaHeader.
+func (m imageMeta) Columns() []string {
+	return []string{
+		strconv.Itoa(m.Width),
+		strconv.Itoa(m.Height),
+		m.Format,
+		strconv.FormatInt(m.Size, 10),
+		m.SHA256,
+		m.ModTime.UTC().Format(time.RFC3339),
+	}
+}
+
+// imageMetaFilter filters lines of list CSV by metadata columns.
+// The lines without the columns are not filtered.
+type imageMetaFilter struct {
+	Dedup     bool
+	MinWidth  int
+	MinHeight int
+	Formats   map[string]struct{}
+}
+
+func newImageMetaFilter(dedup bool, minWidth, minHeight int, formats string) imageMetaFilter {
+	f := imageMetaFilter{
+		Dedup:     dedup,
+		MinWidth:  minWidth,
+		MinHeight: minHeight,
+	}
+	if formats != "" {
+		f.Formats = make(map[string]struct{})
+		for _, s := range strings.Split(formats, ",") {
+			f.Formats[strings.ToLower(strings.TrimSpace(s))] = struct{}{}
+		}
+	}
+	return f
+}
+
+// Filter returns the lines matched with the conditions.
+func (f imageMetaFilter) Filter(lines []map[string]string) []map[string]string {
+	seen := make(map[string]struct{})
+	result := make([]map[string]string, 0, len(lines))
+	for _, line := range lines {
+		if f.accept(line, seen) {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// FilterRows returns the rows of detector's output whose lines are matched with the conditions.
+func (f imageMetaFilter) FilterRows(rows []detectResultRow) []detectResultRow {
+	seen := make(map[string]struct{})
+	result := make([]detectResultRow, 0, len(rows))
+	for _, row := range rows {
+		if f.accept(row.Line, seen) {
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// accept returns true when the line is the target and not a duplicate of the seen hashes.
+func (f imageMetaFilter) accept(line map[string]string, seen map[string]struct{}) bool {
+	if !f.isTarget(line) {
+		return false
+	}
+	if hash := line[colSHA256]; f.Dedup && hash != "" {
+		if _, ok := seen[hash]; ok {
+			return false
+		}
+		seen[hash] = struct{}{}
+	}
+	return true
+}
+
+func (f imageMetaFilter) isTarget(line map[string]string) bool {
+	if w, err := strconv.Atoi(line[colWidth]); err == nil && w < f.MinWidth {
+		return false
+	}
+	if h, err := strconv.Atoi(line[colHeight]); err == nil && h < f.MinHeight {
+		return false
+	}
+	if format := line[colFormat]; len(f.Formats) != 0 && format != "" {
+		if _, ok := f.Formats[format]; !ok {
+			return false
+		}
+	}
+	return true
+}
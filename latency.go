@@ -0,0 +1,160 @@
+package fda
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+func toMsec(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// formatMsec returns the duration in milliseconds for the output.
+func formatMsec(d time.Duration) string {
+	return strconv.FormatFloat(toMsec(d), 'f', 3, 64)
+}
+
+// percentile returns the p-th percentile of the sorted values by nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// latencyStats collects the latencies of each engine.
+type latencyStats struct {
+	engines []engine.Engine
+	// number of the first calls of each engine excluded as warm-up.
+	warmup int
+	start  time.Time
+
+	mu      sync.Mutex
+	calls   []int
+	samples [][]time.Duration
+	// number of all images, including warm-up and failures.
+	images int
+}
+
+func newLatencyStats(engines []engine.Engine, warmup int) *latencyStats {
+	return &latencyStats{
+		engines: engines,
+		warmup:  warmup,
+		start:   time.Now(),
+		calls:   make([]int, len(engines)),
+		samples: make([][]time.Duration, len(engines)),
+	}
+}
+
+// Add records the latencies of the successful engines.
+func (s *latencyStats) Add(r DetectResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.images++
+	for i, res := range r.Results {
+		if res == nil || i >= len(r.Latencies) || i >= len(s.engines) {
+			continue
+		}
+		s.calls[i]++
+		if s.calls[i] <= s.warmup {
+			continue
+		}
+		s.samples[i] = append(s.samples[i], r.Latencies[i])
+	}
+}
+
+// latencySummary is the latency statistics of an engine.
+type latencySummary struct {
+	Engine string
+	Count  int
+	Mean   time.Duration
+	P50    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+	// total time of the engine calls.
+	Busy time.Duration
+	// number of images per second in the busy time of the engine.
+	Throughput float64
+}
+
+// Summary returns the statistics of each engine.
+func (s *latencyStats) Summary() []latencySummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]latencySummary, len(s.engines))
+	for i, e := range s.engines {
+		list[i] = summarizeLatencies(e.String(), s.samples[i])
+	}
+	return list
+}
+
+// Throughput returns the number of images per second of the whole pipeline in the wall-clock time.
+func (s *latencyStats) Throughput() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := time.Since(s.start)
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.images) / elapsed.Seconds()
+}
+
+// summarizeLatencies returns the statistics of the latencies.
+func summarizeLatencies(name string, samples []time.Duration) latencySummary {
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+
+	sum := time.Duration(0)
+	for _, d := range sorted {
+		sum += d
+	}
+	summary := latencySummary{
+		Engine: name,
+		Count:  len(sorted),
+		P50:    percentile(sorted, 50),
+		P95:    percentile(sorted, 95),
+		P99:    percentile(sorted, 99),
+		Busy:   sum,
+	}
+	if len(sorted) != 0 {
+		summary.Mean = sum / time.Duration(len(sorted))
+	}
+	if sum > 0 {
+		summary.Throughput = float64(len(sorted)) / sum.Seconds()
+	}
+	return summary
+}
+
+// Print shows the summary table.
+func (s *latencyStats) Print() {
+	fmt.Printf("[INFO] latency summary (warm-up: %d)\n", s.warmup)
+	fmt.Println(strings.Join([]string{"engine", "count", "mean(ms)", "p50(ms)", "p95(ms)", "p99(ms)", "busy(s)", "images/sec"}, "\t"))
+	for _, l := range s.Summary() {
+		fmt.Println(strings.Join([]string{
+			l.Engine,
+			strconv.Itoa(l.Count),
+			formatMsec(l.Mean),
+			formatMsec(l.P50),
+			formatMsec(l.P95),
+			formatMsec(l.P99),
+			strconv.FormatFloat(l.Busy.Seconds(), 'f', 3, 64),
+			strconv.FormatFloat(l.Throughput, 'f', 2, 64),
+		}, "\t"))
+	}
+	fmt.Printf("[INFO] pipeline throughput: %.2f images/sec\n", s.Throughput())
+}
@@ -0,0 +1,84 @@
+package fda
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+func TestLatencyStatsSummary(t *testing.T) {
+	fast := namedEngine{name: "fast"}
+	slow := namedEngine{name: "slow"}
+	s := newLatencyStats([]engine.Engine{fast, slow}, 1)
+	for _, d := range []time.Duration{time.Second, 10 * time.Millisecond, 30 * time.Millisecond} {
+		s.Add(DetectResult{
+			Results:   []*engine.FaceResult{{}, {}},
+			Latencies: []time.Duration{d, 10 * d},
+		})
+	}
+
+	list := s.Summary()
+	tests := []struct {
+		name       string
+		busy       time.Duration
+		mean       time.Duration
+		throughput float64
+	}{
+		// the first call is excluded as warm-up.
+		{name: "fast", busy: 40 * time.Millisecond, mean: 20 * time.Millisecond, throughput: 50},
+		{name: "slow", busy: 400 * time.Millisecond, mean: 200 * time.Millisecond, throughput: 5},
+	}
+	for i, tt := range tests {
+		l := list[i]
+		if l.Engine != tt.name || l.Count != 2 || l.Busy != tt.busy || l.Mean != tt.mean {
+			t.Errorf("summary = %+v, want %s with count 2, busy %s and mean %s", l, tt.name, tt.busy, tt.mean)
+		}
+		// the throughput is calculated from the busy time of each engine, not the shared wall-clock time.
+		if l.Throughput < tt.throughput-0.001 || l.Throughput > tt.throughput+0.001 {
+			t.Errorf("throughput of %s = %f, want %f", tt.name, l.Throughput, tt.throughput)
+		}
+	}
+}
+
+// fileEngine detects faces only from a local file.
+type fileEngine struct {
+	engine testEngine
+}
+
+func (e fileEngine) Init(conf engine.Config) error { return nil }
+func (e fileEngine) String() string                { return "file" }
+
+func (e fileEngine) Detect(imgPath string) (engine.FaceResult, error) {
+	return e.engine.Detect(imgPath)
+}
+
+func TestDetectInputPrepare(t *testing.T) {
+	in := newDetectInputFromBytes("a.png", newTestPNG(t))
+	defer in.Close()
+
+	// the data is passed to BytesDetector without the temporary file.
+	if err := in.prepare(testEngine{}); err != nil {
+		t.Fatal(err)
+	}
+	if in.tmpPath != "" {
+		t.Errorf("tmpPath = %s, want empty for BytesDetector", in.tmpPath)
+	}
+
+	// the temporary file is written before the detection, which is out of the latency.
+	if err := in.prepare(fileEngine{}); err != nil {
+		t.Fatal(err)
+	}
+	tmpPath := in.tmpPath
+	if _, err := os.Stat(tmpPath); err != nil {
+		t.Fatalf("temporary file is not written: %s", err.Error())
+	}
+	res, err := in.Detect(fileEngine{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Faces) != 1 || in.tmpPath != tmpPath {
+		t.Errorf("faces, tmpPath = %d, %s, want 1, %s", len(res.Faces), in.tmpPath, tmpPath)
+	}
+}
@@ -0,0 +1,139 @@
+package fda
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func splitPatterns(s string) []string {
+	var list []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// isExcludedDir checks the directory is excluded by the depth or exclude patterns.
+func (o WalkOption) isExcludedDir(relPath string, depth int) bool {
+	if o.MaxDepth > 0 && depth >= o.MaxDepth {
+		return true
+	}
+	return matchPatterns(o.Exclude, relPath)
+}
+
+// isTargetFile checks the file is matched with the conditions.
+func (o WalkOption) isTargetFile(filePath, relPath string, info os.FileInfo) bool {
+	if !o.matchFile(relPath, info.Size()) {
+		return false
+	}
+	if !o.hasDimensionLimit() {
+		return true
+	}
+
+	conf, _, err := sniffImage(filePath)
+	if err != nil {
+		return false
+	}
+	return o.matchDimension(conf)
+}
+
+// isTargetEntry checks the entry of archive file is matched with the conditions.
+func (o WalkOption) isTargetEntry(name string, info os.FileInfo, r io.Reader) bool {
+	if !o.matchFile(name, info.Size()) {
+		return false
+	}
+	if !o.hasDimensionLimit() {
+		return true
+	}
+
+	conf, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return false
+	}
+	return o.matchDimension(conf)
+}
+
+// matchFile checks the patterns and file size.
+func (o WalkOption) matchFile(relPath string, size int64) bool {
+	if len(o.Include) != 0 && !matchPatterns(o.Include, relPath) {
+		return false
+	}
+	if matchPatterns(o.Exclude, relPath) {
+		return false
+	}
+	return (o.MinSize <= 0 || size >= o.MinSize) && (o.MaxSize <= 0 || size <= o.MaxSize)
+}
+
+func (o WalkOption) matchDimension(conf image.Config) bool {
+	switch {
+	case o.MinWidth > 0 && conf.Width < o.MinWidth,
+		o.MaxWidth > 0 && conf.Width > o.MaxWidth,
+		o.MinHeight > 0 && conf.Height < o.MinHeight,
+		o.MaxHeight > 0 && conf.Height > o.MaxHeight:
+		return false
+	}
+	return true
+}
+
+func (o WalkOption) hasDimensionLimit() bool {
+	return o.MinWidth > 0 || o.MaxWidth > 0 || o.MinHeight > 0 || o.MaxHeight > 0
+}
+
+func matchPatterns(patterns []string, relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	base := path.Base(relPath)
+	for _, p := range patterns {
+		target := base
+		if strings.Contains(p, "/") {
+			target = relPath
+		}
+		if ok, _ := path.Match(p, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseByteSize parses size string like '100', '10KB', '1.5MB' or '2GB'.
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix string
+		size   float64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"G", 1 << 30},
+		{"M", 1 << 20},
+		{"K", 1 << 10},
+		{"B", 1},
+	}
+	multiplier := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSuffix(s, u.suffix)
+			multiplier = u.size
+			break
+		}
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: [%s]", s)
+	}
+	return int64(v * multiplier), nil
+}
@@ -0,0 +1,172 @@
+package fda
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// listSampler samples and shuffles files in the list command.
+type listSampler struct {
+	Seed int64
+	// number of files to sample. (0 means no sampling)
+	Count int
+	// percentage of files to sample. (0 means no sampling)
+	Percent float64
+	// sample files from each subdirectory in proportion to its size.
+	Stratify bool
+	Shuffle  bool
+}
+
+// parseSampleSize parses sample size like '100' or '10%'.
+func parseSampleSize(s string) (count int, percent float64, err error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return 0, 0, nil
+	case strings.HasSuffix(s, "%"):
+		percent, err = strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, 0, fmt.Errorf("invalid sample size: [%s]", s)
+		}
+		return 0, percent, nil
+	}
+
+	count, err = strconv.Atoi(s)
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("invalid sample size: [%s]", s)
+	}
+	return count, 0, nil
+}
+
+func (s listSampler) isSampling() bool {
+	return s.Count > 0 || s.Percent > 0
+}
+
+func (s listSampler) isActive() bool {
+	return s.isSampling() || s.Shuffle
+}
+
+// Apply samples and shuffles the files.
+// The sampled files keep the original order unless Shuffle is set.
+func (s listSampler) Apply(dir string, files []string) []string {
+	rnd := rand.New(rand.NewSource(s.Seed))
+	if s.isSampling() {
+		files = s.sample(rnd, dir, files)
+	}
+	if s.Shuffle {
+		shuffled := make([]string, len(files))
+		copy(shuffled, files)
+		rnd.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		files = shuffled
+	}
+	return files
+}
+
+func (s listSampler) sample(rnd *rand.Rand, dir string, files []string) []string {
+	groups := map[string][]int{"": nil}
+	keys := []string{""}
+	if s.Stratify {
+		groups, keys = groupBySubDir(dir, files)
+	} else {
+		for i := range files {
+			groups[""] = append(groups[""], i)
+		}
+	}
+
+	sizes := s.allocate(keys, groups, len(files))
+	var picked []int
+	for i, key := range keys {
+		list := groups[key]
+		for _, j := range rnd.Perm(len(list))[:sizes[i]] {
+			picked = append(picked, list[j])
+		}
+	}
+	sort.Ints(picked)
+
+	result := make([]string, len(picked))
+	for i, j := range picked {
+		result[i] = files[j]
+	}
+	return result
+}
+
+// allocate returns the sample size of each group by the largest remainder method.
+func (s listSampler) allocate(keys []string, groups map[string][]int, total int) []int {
+	target := float64(s.Count)
+	if s.Percent > 0 {
+		target = float64(total) * s.Percent / 100
+	}
+	if target > float64(total) {
+		target = float64(total)
+	}
+	if total == 0 {
+		return make([]int, len(keys))
+	}
+
+	sizes := make([]int, len(keys))
+	remainders := make([]float64, len(keys))
+	sum := 0
+	for i, key := range keys {
+		quota := target * float64(len(groups[key])) / float64(total)
+		sizes[i] = int(math.Floor(quota))
+		remainders[i] = quota - float64(sizes[i])
+		sum += sizes[i]
+	}
+
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for _, i := range order {
+		if sum >= int(math.Round(target)) {
+			break
+		}
+		if sizes[i] < len(groups[keys[i]]) {
+			sizes[i]++
+			sum++
+		}
+	}
+	return sizes
+}
+
+// groupBySubDir groups the index of files by the relative directory path.
+func groupBySubDir(dir string, files []string) (map[string][]int, []string) {
+	groups := make(map[string][]int)
+	var keys []string
+	for i, f := range files {
+		key, err := filepath.Rel(dir, filepath.Dir(f))
+		if err != nil {
+			key = filepath.Dir(f)
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	sort.Strings(keys)
+	return groups, keys
+}
+
+// getShardIndex returns the shard number from the hash of the path.
+func getShardIndex(path string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// getShardPath returns the file path of the shard. (e.g. list.csv => list.003.csv)
+func getShardPath(output string, index int) string {
+	ext := filepath.Ext(output)
+	return fmt.Sprintf("%s.%03d%s", strings.TrimSuffix(output, ext), index, ext)
+}
@@ -0,0 +1,85 @@
+package pb
+
+import "github.com/evalphobia/face-detect-annotator/engine"
+
+// NewFaceResult converts engine.FaceResult into the message.
+func NewFaceResult(r engine.FaceResult) *FaceResult {
+	faces := make([]*FaceData, len(r.Faces))
+	for i, f := range r.Faces {
+		faces[i] = NewFaceData(f)
+	}
+	return &FaceResult{
+		Engine: r.EngineName,
+		Faces:  faces,
+	}
+}
+
+// NewFaceData converts engine.FaceData into the message.
+func NewFaceData(f engine.FaceData) *FaceData {
+	d := &FaceData{
+		X:             int32(f.X),
+		Y:             int32(f.Y),
+		Width:         int32(f.Width),
+		Height:        int32(f.Height),
+		WidthPer:      f.PercentWidth,
+		HeightPer:     f.PercentHeight,
+		Confidence:    f.Confidence,
+		RawConfidence: f.RawConfidence,
+	}
+	for _, l := range f.Landmarks {
+		d.Landmarks = append(d.Landmarks, &Landmark{
+			Type: l.Type,
+			X:    l.X,
+			Y:    l.Y,
+		})
+	}
+	if f.Pose != nil {
+		d.Pose = &Pose{
+			Roll:  f.Pose.Roll,
+			Yaw:   f.Pose.Yaw,
+			Pitch: f.Pose.Pitch,
+		}
+	}
+	return d
+}
+
+// ToEngine converts the message into engine.FaceResult.
+func (m *FaceResult) ToEngine() engine.FaceResult {
+	r := engine.FaceResult{
+		EngineName: m.GetEngine(),
+		Faces:      make([]engine.FaceData, len(m.GetFaces())),
+	}
+	for i, f := range m.GetFaces() {
+		r.Faces[i] = f.ToEngine()
+	}
+	return r
+}
+
+// ToEngine converts the message into engine.FaceData.
+func (m *FaceData) ToEngine() engine.FaceData {
+	f := engine.FaceData{
+		X:             int(m.GetX()),
+		Y:             int(m.GetY()),
+		Width:         int(m.GetWidth()),
+		Height:        int(m.GetHeight()),
+		PercentWidth:  m.GetWidthPer(),
+		PercentHeight: m.GetHeightPer(),
+		Confidence:    m.GetConfidence(),
+		RawConfidence: m.GetRawConfidence(),
+	}
+	for _, l := range m.GetLandmarks() {
+		f.Landmarks = append(f.Landmarks, engine.Landmark{
+			Type: l.GetType(),
+			X:    l.GetX(),
+			Y:    l.GetY(),
+		})
+	}
+	if p := m.GetPose(); p != nil {
+		f.Pose = &engine.Pose{
+			Roll:  p.GetRoll(),
+			Yaw:   p.GetYaw(),
+			Pitch: p.GetPitch(),
+		}
+	}
+	return f
+}
@@ -0,0 +1,775 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: detector.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type DetectRequest struct {
+	// id is returned in the response as it is.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are valid to be assigned to Source:
+	//	*DetectRequest_Image
+	//	*DetectRequest_Url
+	Source isDetectRequest_Source `protobuf_oneof:"source"`
+	// file name of the image to guess the format. (optional)
+	Name string `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	// engine names to use. (empty means all engines)
+	Engines              []string `protobuf:"bytes,5,rep,name=engines,proto3" json:"engines,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DetectRequest) Reset()         { *m = DetectRequest{} }
+func (m *DetectRequest) String() string { return proto.CompactTextString(m) }
+func (*DetectRequest) ProtoMessage()    {}
+func (*DetectRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_219a833ba4205d7f, []int{0}
+}
+
+func (m *DetectRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DetectRequest.Unmarshal(m, b)
+}
+func (m *DetectRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DetectRequest.Marshal(b, m, deterministic)
+}
+func (m *DetectRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DetectRequest.Merge(m, src)
+}
+func (m *DetectRequest) XXX_Size() int {
+	return xxx_messageInfo_DetectRequest.Size(m)
+}
+func (m *DetectRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DetectRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DetectRequest proto.InternalMessageInfo
+
+func (m *DetectRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type isDetectRequest_Source interface {
+	isDetectRequest_Source()
+}
+
+type DetectRequest_Image struct {
+	Image []byte `protobuf:"bytes,2,opt,name=image,proto3,oneof"`
+}
+
+type DetectRequest_Url struct {
+	Url string `protobuf:"bytes,3,opt,name=url,proto3,oneof"`
+}
+
+func (*DetectRequest_Image) isDetectRequest_Source() {}
+
+func (*DetectRequest_Url) isDetectRequest_Source() {}
+
+func (m *DetectRequest) GetSource() isDetectRequest_Source {
+	if m != nil {
+		return m.Source
+	}
+	return nil
+}
+
+func (m *DetectRequest) GetImage() []byte {
+	if x, ok := m.GetSource().(*DetectRequest_Image); ok {
+		return x.Image
+	}
+	return nil
+}
+
+func (m *DetectRequest) GetUrl() string {
+	if x, ok := m.GetSource().(*DetectRequest_Url); ok {
+		return x.Url
+	}
+	return ""
+}
+
+func (m *DetectRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DetectRequest) GetEngines() []string {
+	if m != nil {
+		return m.Engines
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*DetectRequest) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*DetectRequest_Image)(nil),
+		(*DetectRequest_Url)(nil),
+	}
+}
+
+type DetectResponse struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// results in the order of the engines.
+	Results []*FaceResult `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"`
+	// error messages of the failed engines by the engine name.
+	EngineErrors map[string]string `protobuf:"bytes,3,rep,name=engine_errors,json=engineErrors,proto3" json:"engine_errors,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// error of the request in DetectStream. (e.g. download error)
+	Error                string   `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DetectResponse) Reset()         { *m = DetectResponse{} }
+func (m *DetectResponse) String() string { return proto.CompactTextString(m) }
+func (*DetectResponse) ProtoMessage()    {}
+func (*DetectResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_219a833ba4205d7f, []int{1}
+}
+
+func (m *DetectResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DetectResponse.Unmarshal(m, b)
+}
+func (m *DetectResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DetectResponse.Marshal(b, m, deterministic)
+}
+func (m *DetectResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DetectResponse.Merge(m, src)
+}
+func (m *DetectResponse) XXX_Size() int {
+	return xxx_messageInfo_DetectResponse.Size(m)
+}
+func (m *DetectResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DetectResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DetectResponse proto.InternalMessageInfo
+
+func (m *DetectResponse) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *DetectResponse) GetResults() []*FaceResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+func (m *DetectResponse) GetEngineErrors() map[string]string {
+	if m != nil {
+		return m.EngineErrors
+	}
+	return nil
+}
+
+func (m *DetectResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+// FaceResult is engine.FaceResult.
+type FaceResult struct {
+	Engine               string      `protobuf:"bytes,1,opt,name=engine,proto3" json:"engine,omitempty"`
+	Faces                []*FaceData `protobuf:"bytes,2,rep,name=faces,proto3" json:"faces,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *FaceResult) Reset()         { *m = FaceResult{} }
+func (m *FaceResult) String() string { return proto.CompactTextString(m) }
+func (*FaceResult) ProtoMessage()    {}
+func (*FaceResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_219a833ba4205d7f, []int{2}
+}
+
+func (m *FaceResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FaceResult.Unmarshal(m, b)
+}
+func (m *FaceResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FaceResult.Marshal(b, m, deterministic)
+}
+func (m *FaceResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FaceResult.Merge(m, src)
+}
+func (m *FaceResult) XXX_Size() int {
+	return xxx_messageInfo_FaceResult.Size(m)
+}
+func (m *FaceResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_FaceResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FaceResult proto.InternalMessageInfo
+
+func (m *FaceResult) GetEngine() string {
+	if m != nil {
+		return m.Engine
+	}
+	return ""
+}
+
+func (m *FaceResult) GetFaces() []*FaceData {
+	if m != nil {
+		return m.Faces
+	}
+	return nil
+}
+
+// FaceData is engine.FaceData.
+type FaceData struct {
+	X                    int32       `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y                    int32       `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	Width                int32       `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height               int32       `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	WidthPer             float64     `protobuf:"fixed64,5,opt,name=width_per,json=widthPer,proto3" json:"width_per,omitempty"`
+	HeightPer            float64     `protobuf:"fixed64,6,opt,name=height_per,json=heightPer,proto3" json:"height_per,omitempty"`
+	Confidence           float64     `protobuf:"fixed64,7,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Landmarks            []*Landmark `protobuf:"bytes,8,rep,name=landmarks,proto3" json:"landmarks,omitempty"`
+	Pose                 *Pose       `protobuf:"bytes,9,opt,name=pose,proto3" json:"pose,omitempty"`
+	RawConfidence        float64     `protobuf:"fixed64,10,opt,name=raw_confidence,json=rawConfidence,proto3" json:"raw_confidence,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *FaceData) Reset()         { *m = FaceData{} }
+func (m *FaceData) String() string { return proto.CompactTextString(m) }
+func (*FaceData) ProtoMessage()    {}
+func (*FaceData) Descriptor() ([]byte, []int) {
+	return fileDescriptor_219a833ba4205d7f, []int{3}
+}
+
+func (m *FaceData) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FaceData.Unmarshal(m, b)
+}
+func (m *FaceData) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FaceData.Marshal(b, m, deterministic)
+}
+func (m *FaceData) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FaceData.Merge(m, src)
+}
+func (m *FaceData) XXX_Size() int {
+	return xxx_messageInfo_FaceData.Size(m)
+}
+func (m *FaceData) XXX_DiscardUnknown() {
+	xxx_messageInfo_FaceData.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FaceData proto.InternalMessageInfo
+
+func (m *FaceData) GetX() int32 {
+	if m != nil {
+		return m.X
+	}
+	return 0
+}
+
+func (m *FaceData) GetY() int32 {
+	if m != nil {
+		return m.Y
+	}
+	return 0
+}
+
+func (m *FaceData) GetWidth() int32 {
+	if m != nil {
+		return m.Width
+	}
+	return 0
+}
+
+func (m *FaceData) GetHeight() int32 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *FaceData) GetWidthPer() float64 {
+	if m != nil {
+		return m.WidthPer
+	}
+	return 0
+}
+
+func (m *FaceData) GetHeightPer() float64 {
+	if m != nil {
+		return m.HeightPer
+	}
+	return 0
+}
+
+func (m *FaceData) GetConfidence() float64 {
+	if m != nil {
+		return m.Confidence
+	}
+	return 0
+}
+
+func (m *FaceData) GetLandmarks() []*Landmark {
+	if m != nil {
+		return m.Landmarks
+	}
+	return nil
+}
+
+func (m *FaceData) GetPose() *Pose {
+	if m != nil {
+		return m.Pose
+	}
+	return nil
+}
+
+func (m *FaceData) GetRawConfidence() float64 {
+	if m != nil {
+		return m.RawConfidence
+	}
+	return 0
+}
+
+// Landmark is engine.Landmark.
+type Landmark struct {
+	Type                 string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	X                    float64  `protobuf:"fixed64,2,opt,name=x,proto3" json:"x,omitempty"`
+	Y                    float64  `protobuf:"fixed64,3,opt,name=y,proto3" json:"y,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Landmark) Reset()         { *m = Landmark{} }
+func (m *Landmark) String() string { return proto.CompactTextString(m) }
+func (*Landmark) ProtoMessage()    {}
+func (*Landmark) Descriptor() ([]byte, []int) {
+	return fileDescriptor_219a833ba4205d7f, []int{4}
+}
+
+func (m *Landmark) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Landmark.Unmarshal(m, b)
+}
+func (m *Landmark) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Landmark.Marshal(b, m, deterministic)
+}
+func (m *Landmark) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Landmark.Merge(m, src)
+}
+func (m *Landmark) XXX_Size() int {
+	return xxx_messageInfo_Landmark.Size(m)
+}
+func (m *Landmark) XXX_DiscardUnknown() {
+	xxx_messageInfo_Landmark.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Landmark proto.InternalMessageInfo
+
+func (m *Landmark) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *Landmark) GetX() float64 {
+	if m != nil {
+		return m.X
+	}
+	return 0
+}
+
+func (m *Landmark) GetY() float64 {
+	if m != nil {
+		return m.Y
+	}
+	return 0
+}
+
+// Pose is engine.Pose.
+type Pose struct {
+	Roll                 float64  `protobuf:"fixed64,1,opt,name=roll,proto3" json:"roll,omitempty"`
+	Yaw                  float64  `protobuf:"fixed64,2,opt,name=yaw,proto3" json:"yaw,omitempty"`
+	Pitch                float64  `protobuf:"fixed64,3,opt,name=pitch,proto3" json:"pitch,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Pose) Reset()         { *m = Pose{} }
+func (m *Pose) String() string { return proto.CompactTextString(m) }
+func (*Pose) ProtoMessage()    {}
+func (*Pose) Descriptor() ([]byte, []int) {
+	return fileDescriptor_219a833ba4205d7f, []int{5}
+}
+
+func (m *Pose) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Pose.Unmarshal(m, b)
+}
+func (m *Pose) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Pose.Marshal(b, m, deterministic)
+}
+func (m *Pose) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Pose.Merge(m, src)
+}
+func (m *Pose) XXX_Size() int {
+	return xxx_messageInfo_Pose.Size(m)
+}
+func (m *Pose) XXX_DiscardUnknown() {
+	xxx_messageInfo_Pose.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Pose proto.InternalMessageInfo
+
+func (m *Pose) GetRoll() float64 {
+	if m != nil {
+		return m.Roll
+	}
+	return 0
+}
+
+func (m *Pose) GetYaw() float64 {
+	if m != nil {
+		return m.Yaw
+	}
+	return 0
+}
+
+func (m *Pose) GetPitch() float64 {
+	if m != nil {
+		return m.Pitch
+	}
+	return 0
+}
+
+type ListEnginesRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListEnginesRequest) Reset()         { *m = ListEnginesRequest{} }
+func (m *ListEnginesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListEnginesRequest) ProtoMessage()    {}
+func (*ListEnginesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_219a833ba4205d7f, []int{6}
+}
+
+func (m *ListEnginesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListEnginesRequest.Unmarshal(m, b)
+}
+func (m *ListEnginesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListEnginesRequest.Marshal(b, m, deterministic)
+}
+func (m *ListEnginesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListEnginesRequest.Merge(m, src)
+}
+func (m *ListEnginesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListEnginesRequest.Size(m)
+}
+func (m *ListEnginesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListEnginesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListEnginesRequest proto.InternalMessageInfo
+
+type ListEnginesResponse struct {
+	Engines              []string `protobuf:"bytes,1,rep,name=engines,proto3" json:"engines,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListEnginesResponse) Reset()         { *m = ListEnginesResponse{} }
+func (m *ListEnginesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListEnginesResponse) ProtoMessage()    {}
+func (*ListEnginesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_219a833ba4205d7f, []int{7}
+}
+
+func (m *ListEnginesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListEnginesResponse.Unmarshal(m, b)
+}
+func (m *ListEnginesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListEnginesResponse.Marshal(b, m, deterministic)
+}
+func (m *ListEnginesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListEnginesResponse.Merge(m, src)
+}
+func (m *ListEnginesResponse) XXX_Size() int {
+	return xxx_messageInfo_ListEnginesResponse.Size(m)
+}
+func (m *ListEnginesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListEnginesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListEnginesResponse proto.InternalMessageInfo
+
+func (m *ListEnginesResponse) GetEngines() []string {
+	if m != nil {
+		return m.Engines
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*DetectRequest)(nil), "fda.DetectRequest")
+	proto.RegisterType((*DetectResponse)(nil), "fda.DetectResponse")
+	proto.RegisterMapType((map[string]string)(nil), "fda.DetectResponse.EngineErrorsEntry")
+	proto.RegisterType((*FaceResult)(nil), "fda.FaceResult")
+	proto.RegisterType((*FaceData)(nil), "fda.FaceData")
+	proto.RegisterType((*Landmark)(nil), "fda.Landmark")
+	proto.RegisterType((*Pose)(nil), "fda.Pose")
+	proto.RegisterType((*ListEnginesRequest)(nil), "fda.ListEnginesRequest")
+	proto.RegisterType((*ListEnginesResponse)(nil), "fda.ListEnginesResponse")
+}
+
+func init() { proto.RegisterFile("detector.proto", fileDescriptor_219a833ba4205d7f) }
+
+var fileDescriptor_219a833ba4205d7f = []byte{
+	// 632 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xcf, 0x4e, 0xdb, 0x4e,
+	0x10, 0x66, 0xed, 0x38, 0xc4, 0x43, 0x92, 0xdf, 0xaf, 0x0b, 0xa2, 0x16, 0x15, 0x55, 0xe4, 0x0a,
+	0xc9, 0x55, 0x45, 0x52, 0xe0, 0x52, 0xc1, 0xa1, 0x15, 0x85, 0x8a, 0x56, 0x1c, 0xd0, 0xf6, 0xd6,
+	0x0b, 0xda, 0xd8, 0x9b, 0xc4, 0xc2, 0xf1, 0xba, 0xeb, 0x0d, 0x21, 0xe7, 0x3e, 0x4a, 0x5f, 0xa3,
+	0xcf, 0xd4, 0x67, 0xa8, 0x76, 0xd6, 0x86, 0x44, 0xe9, 0xa1, 0xb7, 0xf9, 0xbe, 0x99, 0x6f, 0xfe,
+	0x79, 0xd6, 0xd0, 0x4d, 0x84, 0x16, 0xb1, 0x96, 0xaa, 0x5f, 0x28, 0xa9, 0x25, 0x75, 0x47, 0x09,
+	0x0f, 0x7f, 0x10, 0xe8, 0x5c, 0x20, 0xcf, 0xc4, 0xf7, 0x99, 0x28, 0x35, 0xed, 0x82, 0x93, 0x26,
+	0x01, 0xe9, 0x91, 0xc8, 0x67, 0x4e, 0x9a, 0xd0, 0x5d, 0xf0, 0xd2, 0x29, 0x1f, 0x8b, 0xc0, 0xe9,
+	0x91, 0xa8, 0x7d, 0xb5, 0xc1, 0x2c, 0xa4, 0x14, 0xdc, 0x99, 0xca, 0x02, 0xd7, 0x04, 0x5e, 0x6d,
+	0x30, 0x03, 0x28, 0x85, 0x46, 0xce, 0xa7, 0x22, 0x68, 0xa0, 0x1a, 0x6d, 0x1a, 0xc0, 0xa6, 0xc8,
+	0xc7, 0x69, 0x2e, 0xca, 0xc0, 0xeb, 0xb9, 0x91, 0xcf, 0x6a, 0x78, 0xde, 0x82, 0x66, 0x29, 0x67,
+	0x2a, 0x16, 0xe1, 0x6f, 0x02, 0xdd, 0xba, 0x8b, 0xb2, 0x90, 0x79, 0x29, 0xd6, 0xda, 0x78, 0x0d,
+	0x9b, 0x4a, 0x94, 0xb3, 0x4c, 0x97, 0x81, 0xd3, 0x73, 0xa3, 0xad, 0xe3, 0xff, 0xfa, 0xa3, 0x84,
+	0xf7, 0x3f, 0xf1, 0x58, 0x30, 0xe4, 0x59, 0xed, 0xa7, 0x5f, 0xa0, 0x63, 0x4b, 0xdc, 0x0a, 0xa5,
+	0xa4, 0x2a, 0x03, 0x17, 0x05, 0x07, 0x28, 0x58, 0x2d, 0xd3, 0xbf, 0xc4, 0xc0, 0x4b, 0x8c, 0xbb,
+	0xcc, 0xb5, 0x5a, 0xb0, 0xb6, 0x58, 0xa2, 0xe8, 0x0e, 0x78, 0x98, 0xa4, 0x1a, 0xc9, 0x82, 0xbd,
+	0xf7, 0xf0, 0x6c, 0x4d, 0x48, 0xff, 0x07, 0xf7, 0x4e, 0x2c, 0xaa, 0x96, 0x8d, 0x69, 0xc4, 0xf7,
+	0x3c, 0x9b, 0xd9, 0xd5, 0xf9, 0xcc, 0x82, 0x53, 0xe7, 0x1d, 0x09, 0x3f, 0x03, 0x3c, 0x75, 0x4e,
+	0x77, 0xa1, 0x69, 0x8b, 0x56, 0xe2, 0x0a, 0xd1, 0x57, 0xe0, 0x8d, 0x78, 0x2c, 0xea, 0x89, 0x3b,
+	0x8f, 0x13, 0x5f, 0x70, 0xcd, 0x99, 0xf5, 0x85, 0x3f, 0x1d, 0x68, 0xd5, 0x1c, 0x6d, 0x03, 0x79,
+	0xc0, 0x24, 0x1e, 0x23, 0x0f, 0x06, 0x2d, 0xb0, 0xb6, 0xc7, 0x08, 0x76, 0x33, 0x4f, 0x13, 0x3d,
+	0xc1, 0x4f, 0xe6, 0x31, 0x0b, 0x4c, 0xed, 0x89, 0x48, 0xc7, 0x13, 0x8d, 0x13, 0x7a, 0xac, 0x42,
+	0xf4, 0x05, 0xf8, 0x18, 0x70, 0x5b, 0x08, 0x15, 0x78, 0x3d, 0x12, 0x11, 0xd6, 0x42, 0xe2, 0x46,
+	0x28, 0xba, 0x0f, 0x60, 0xc3, 0xd0, 0xdb, 0x44, 0xaf, 0x6f, 0x19, 0xe3, 0x7e, 0x09, 0x10, 0xcb,
+	0x7c, 0x94, 0x26, 0x22, 0x8f, 0x45, 0xb0, 0x89, 0xee, 0x25, 0x86, 0xbe, 0x01, 0x3f, 0xe3, 0x79,
+	0x32, 0xe5, 0xea, 0xae, 0x0c, 0x5a, 0x4b, 0xb3, 0x5d, 0x57, 0x2c, 0x7b, 0xf2, 0xd3, 0x7d, 0x68,
+	0x14, 0xb2, 0x14, 0x81, 0xdf, 0x23, 0xd1, 0xd6, 0xb1, 0x8f, 0x71, 0x37, 0xb2, 0x14, 0x0c, 0x69,
+	0x7a, 0x00, 0x5d, 0xc5, 0xe7, 0xb7, 0x4b, 0xf5, 0x00, 0xeb, 0x75, 0x14, 0x9f, 0x7f, 0x7c, 0x24,
+	0xc3, 0x53, 0x68, 0xd5, 0xc9, 0xcd, 0x95, 0xea, 0x45, 0x51, 0x2f, 0x1b, 0x6d, 0xbb, 0x38, 0x07,
+	0x95, 0xf5, 0xe2, 0x5c, 0x8b, 0x16, 0xe1, 0x39, 0x34, 0x4c, 0x41, 0xa3, 0x53, 0x32, 0xcb, 0x50,
+	0x47, 0x18, 0xda, 0xe6, 0xa3, 0x2f, 0xf8, 0xbc, 0x52, 0x1a, 0xd3, 0xac, 0xb9, 0x48, 0x75, 0x3c,
+	0xa9, 0xf4, 0x16, 0x84, 0x3b, 0x40, 0xaf, 0xd3, 0x52, 0xdb, 0xab, 0x29, 0xab, 0xb7, 0x16, 0x0e,
+	0x60, 0x7b, 0x85, 0xad, 0x6e, 0x7f, 0xe9, 0xc9, 0x90, 0x95, 0x27, 0x73, 0xfc, 0x8b, 0x40, 0xeb,
+	0xa2, 0x7a, 0xc6, 0xf4, 0x08, 0x9a, 0xd6, 0xa6, 0x74, 0xe5, 0xb4, 0x31, 0xf7, 0xde, 0xf6, 0x5f,
+	0xce, 0x9d, 0x9e, 0x41, 0xdb, 0x32, 0x5f, 0xb5, 0x12, 0x7c, 0xfa, 0xcf, 0xc2, 0x88, 0xbc, 0x25,
+	0xf4, 0x03, 0x6c, 0x2d, 0x75, 0x4b, 0x9f, 0xdb, 0x4f, 0xb6, 0x36, 0xd5, 0x5e, 0xb0, 0xee, 0xb0,
+	0x59, 0xce, 0x4f, 0xbe, 0x1d, 0x8d, 0x53, 0x3d, 0x99, 0x0d, 0xfb, 0xb1, 0x9c, 0x0e, 0xc4, 0x3d,
+	0xcf, 0x8a, 0x89, 0x1c, 0xa6, 0x7c, 0x60, 0x4e, 0xf9, 0xd0, 0xfe, 0x9f, 0x0e, 0x79, 0x9e, 0x4b,
+	0xcd, 0xb5, 0x54, 0x83, 0x62, 0x78, 0x56, 0x0c, 0x87, 0x4d, 0xfc, 0x5d, 0x9d, 0xfc, 0x19, 0x00,
+	0xbd, 0x7e, 0xc6, 0x26, 0xc0, 0x04, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// DetectorClient is the client API for Detector service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type DetectorClient interface {
+	// Detect detects faces from an image.
+	Detect(ctx context.Context, in *DetectRequest, opts ...grpc.CallOption) (*DetectResponse, error)
+	// DetectStream detects faces from the images sent in the stream.
+	// The responses may be returned in a different order from the requests, use id to match them.
+	DetectStream(ctx context.Context, opts ...grpc.CallOption) (Detector_DetectStreamClient, error)
+	// ListEngines returns the names of the initialized engines.
+	ListEngines(ctx context.Context, in *ListEnginesRequest, opts ...grpc.CallOption) (*ListEnginesResponse, error)
+}
+
+type detectorClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewDetectorClient(cc *grpc.ClientConn) DetectorClient {
+	return &detectorClient{cc}
+}
+
+func (c *detectorClient) Detect(ctx context.Context, in *DetectRequest, opts ...grpc.CallOption) (*DetectResponse, error) {
+	out := new(DetectResponse)
+	err := c.cc.Invoke(ctx, "/fda.Detector/Detect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *detectorClient) DetectStream(ctx context.Context, opts ...grpc.CallOption) (Detector_DetectStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Detector_serviceDesc.Streams[0], "/fda.Detector/DetectStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &detectorDetectStreamClient{stream}
+	return x, nil
+}
+
+type Detector_DetectStreamClient interface {
+	Send(*DetectRequest) error
+	Recv() (*DetectResponse, error)
+	grpc.ClientStream
+}
+
+type detectorDetectStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *detectorDetectStreamClient) Send(m *DetectRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *detectorDetectStreamClient) Recv() (*DetectResponse, error) {
+	m := new(DetectResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *detectorClient) ListEngines(ctx context.Context, in *ListEnginesRequest, opts ...grpc.CallOption) (*ListEnginesResponse, error) {
+	out := new(ListEnginesResponse)
+	err := c.cc.Invoke(ctx, "/fda.Detector/ListEngines", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DetectorServer is the server API for Detector service.
+type DetectorServer interface {
+	// Detect detects faces from an image.
+	Detect(context.Context, *DetectRequest) (*DetectResponse, error)
+	// DetectStream detects faces from the images sent in the stream.
+	// The responses may be returned in a different order from the requests, use id to match them.
+	DetectStream(Detector_DetectStreamServer) error
+	// ListEngines returns the names of the initialized engines.
+	ListEngines(context.Context, *ListEnginesRequest) (*ListEnginesResponse, error)
+}
+
+func RegisterDetectorServer(s *grpc.Server, srv DetectorServer) {
+	s.RegisterService(&_Detector_serviceDesc, srv)
+}
+
+func _Detector_Detect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DetectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DetectorServer).Detect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/fda.Detector/Detect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DetectorServer).Detect(ctx, req.(*DetectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Detector_DetectStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DetectorServer).DetectStream(&detectorDetectStreamServer{stream})
+}
+
+type Detector_DetectStreamServer interface {
+	Send(*DetectResponse) error
+	Recv() (*DetectRequest, error)
+	grpc.ServerStream
+}
+
+type detectorDetectStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *detectorDetectStreamServer) Send(m *DetectResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *detectorDetectStreamServer) Recv() (*DetectRequest, error) {
+	m := new(DetectRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Detector_ListEngines_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEnginesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DetectorServer).ListEngines(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/fda.Detector/ListEngines",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DetectorServer).ListEngines(ctx, req.(*ListEnginesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Detector_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "fda.Detector",
+	HandlerType: (*DetectorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Detect",
+			Handler:    _Detector_Detect_Handler,
+		},
+		{
+			MethodName: "ListEngines",
+			Handler:    _Detector_ListEngines_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "DetectStream",
+			Handler:       _Detector_DetectStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "detector.proto",
+}
@@ -0,0 +1,4 @@
+// Package pb is the protobuf messages and gRPC service of face detection.
+package pb
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. detector.proto
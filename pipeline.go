@@ -0,0 +1,498 @@
+package fda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+const defaultConcurrency = 10
+
+// Pipeline detects faces from images by the engines, and sends the results to the sinks and callbacks.
+type Pipeline struct {
+	engines     []engine.Engine
+	reader      imageReader
+	concurrency int
+	sinks       []Sink
+	callbacks   []func(DetectResult)
+}
+
+// Option is an option of NewPipeline.
+type Option func(*pipelineOptions)
+
+type pipelineOptions struct {
+	conf        Config
+	hasConf     bool
+	names       []string
+	registry    []engine.Engine
+	engines     []engine.Engine
+	concurrency int
+	fetcher     *urlFetcher
+	archives    *archiveCache
+	s3          *s3Storage
+	sinks       []Sink
+	callbacks   []func(DetectResult)
+}
+
+// WithConfig sets the config of the engines. (default is NewConfig(false) from the environment variables)
+func WithConfig(conf Config) Option {
+	return func(o *pipelineOptions) {
+		o.conf = conf
+		o.hasConf = true
+	}
+}
+
+// WithEngineRegistry sets the candidate engines, which are selected by the config and WithEngineNames.
+func WithEngineRegistry(engines ...engine.Engine) Option {
+	return func(o *pipelineOptions) {
+		o.registry = append(o.registry, engines...)
+	}
+}
+
+// WithEngineNames selects the engines by the names from the engines of WithEngineRegistry.
+func WithEngineNames(names ...string) Option {
+	return func(o *pipelineOptions) {
+		o.names = append(o.names, names...)
+	}
+}
+
+// WithEngines uses the engines instead of the engines of WithEngineRegistry. They are initialized with the config.
+func WithEngines(engines ...engine.Engine) Option {
+	return func(o *pipelineOptions) {
+		o.engines = append(o.engines, engines...)
+	}
+}
+
+// WithConcurrency sets the number of images detected concurrently in Run. (default is 10)
+func WithConcurrency(n int) Option {
+	return func(o *pipelineOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithURLFetch sets the limits of downloading http(s) URL. (empty cacheDir means no cache)
+func WithURLFetch(concurrency int, timeout time.Duration, maxSize int64, cacheDir string) Option {
+	return func(o *pipelineOptions) {
+		o.fetcher = newURLFetcher(concurrency, timeout, maxSize, cacheDir)
+	}
+}
+
+// WithArchiveCache sets the max number of archive files kept opened to read the entries, and the max size of an entry.
+// (default is 64 files and 100MB, and zero maxEntrySize means no limit)
+func WithArchiveCache(maxOpen int, maxEntrySize int64) Option {
+	return func(o *pipelineOptions) {
+		o.archives = newArchiveCache(maxOpen, maxEntrySize)
+	}
+}
+
+// WithS3Endpoint sets the endpoint of S3 compatible storage. (default is FDA_S3_ENDPOINT, and empty means AWS S3)
+func WithS3Endpoint(endpoint string) Option {
+	return func(o *pipelineOptions) {
+		o.s3 = newS3Storage(endpoint)
+	}
+}
+
+// WithSink adds the sink to write the results.
+func WithSink(s Sink) Option {
+	return func(o *pipelineOptions) {
+		o.sinks = append(o.sinks, s)
+	}
+}
+
+// WithCallback adds the function called with each result after the sinks.
+func WithCallback(fn func(DetectResult)) Option {
+	return func(o *pipelineOptions) {
+		o.callbacks = append(o.callbacks, fn)
+	}
+}
+
+// NewPipeline initializes the engines and returns the pipeline.
+// The engines are set by WithEngines or WithEngineRegistry.
+func NewPipeline(opts ...Option) (*Pipeline, error) {
+	o := pipelineOptions{
+		concurrency: defaultConcurrency,
+	}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if !o.hasConf {
+		o.conf = NewConfig(false)
+	}
+	if o.fetcher == nil {
+		o.fetcher = newURLFetcher(defaultFetchConcurrency, defaultFetchTimeout, defaultMaxDownloadSize, "")
+	}
+	if o.archives == nil {
+		o.archives = newArchiveCache(maxCachedArchives, defaultMaxArchiveEntrySize)
+	}
+	if o.s3 == nil {
+		o.s3 = newS3Storage(os.Getenv(keyConfigS3Endpoint))
+	}
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+
+	for _, name := range o.names {
+		if err := o.conf.setUseEngineFromName(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var engines []engine.Engine
+	var err error
+	switch {
+	case len(o.engines) != 0:
+		engines, err = initAllEngines(o.conf, o.engines)
+	case len(o.registry) != 0:
+		engines, err = initEngines(o.conf, o.registry)
+	default:
+		err = errors.New("no engine is set by WithEngines or WithEngineRegistry")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range o.sinks {
+		if err := s.Open(engines); err != nil {
+			closeSinks(o.sinks[:i])
+			return nil, err
+		}
+	}
+
+	return &Pipeline{
+		engines: engines,
+		reader: imageReader{
+			fetcher:  o.fetcher,
+			archives: o.archives,
+			s3:       o.s3,
+		},
+		concurrency: o.concurrency,
+		sinks:       o.sinks,
+		callbacks:   o.callbacks,
+	}, nil
+}
+
+// initAllEngines initializes the engines regardless of the config flags.
+func initAllEngines(conf Config, engines []engine.Engine) ([]engine.Engine, error) {
+	for _, e := range engines {
+		if err := e.Init(conf); err != nil {
+			return nil, err
+		}
+	}
+	return engines, nil
+}
+
+// Engines returns the initialized engines.
+func (p *Pipeline) Engines() []engine.Engine {
+	return p.engines
+}
+
+// Detect detects faces of the image. The path is a local file, an entry of archive file, http(s) URL or S3 object.
+// It does not send the result to the sinks and callbacks.
+func (p *Pipeline) Detect(path string) DetectResult {
+	return detectImage(p.engines, Input{Path: path}, p.reader)
+}
+
+// DetectBytes detects faces of the image data. The name is used for the extension of the image.
+func (p *Pipeline) DetectBytes(name string, data []byte) DetectResult {
+	return detectImage(p.engines, Input{Path: name, Data: data}, p.reader)
+}
+
+// Input is an image to detect in Run.
+type Input struct {
+	// path of the image, or the name of the image data.
+	Path string
+	// image data. (nil means reading the image from Path)
+	Data []byte
+	// count column of the list file, which is written into the output as it is.
+	Count string
+}
+
+// RunInputs detects faces of the images. See Run.
+func (p *Pipeline) RunInputs(ctx context.Context, inputs []Input) error {
+	// stop sending the inputs when Run returns by the error of the sink.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan Input)
+	go func() {
+		defer close(ch)
+		for _, in := range inputs {
+			select {
+			case ch <- in:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return p.Run(ctx, ch)
+}
+
+// Run detects faces of the images from the channel concurrently until it's closed.
+// The results are sent to the sinks and callbacks in the order of the inputs.
+// It stops when the sink returns error or the context is canceled.
+func (p *Pipeline) Run(ctx context.Context, inputs <-chan Input) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		seq int
+		in  Input
+	}
+	type done struct {
+		seq    int
+		result DetectResult
+	}
+
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		for seq := 0; ; seq++ {
+			select {
+			case in, ok := <-inputs:
+				if !ok {
+					return
+				}
+				select {
+				case jobs <- job{seq: seq, in: in}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan done)
+	finished := make(chan struct{})
+	for i := 0; i < p.concurrency; i++ {
+		go func() {
+			defer func() { finished <- struct{}{} }()
+			for j := range jobs {
+				r := detectImage(p.engines, j.in, p.reader)
+				select {
+				case results <- done{seq: j.seq, result: r}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < p.concurrency; i++ {
+			<-finished
+		}
+		close(results)
+	}()
+
+	// reorder the results by the sequence of inputs.
+	var err error
+	pending := make(map[int]DetectResult)
+	next := 0
+	for d := range results {
+		if err != nil {
+			continue
+		}
+		pending[d.seq] = d.result
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err = p.emit(r); err != nil {
+				cancel()
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (p *Pipeline) emit(r DetectResult) error {
+	for _, s := range p.sinks {
+		if err := s.Write(r); err != nil {
+			return err
+		}
+	}
+	for _, fn := range p.callbacks {
+		fn(r)
+	}
+	return nil
+}
+
+// Close closes the sinks and the archive files.
+func (p *Pipeline) Close() error {
+	err := closeSinks(p.sinks)
+	if e := p.reader.archives.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// closeSinks closes all of the sinks and returns the first error.
+func closeSinks(sinks []Sink) error {
+	var err error
+	for _, s := range sinks {
+		if e := s.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// DetectResult is the detection results of an image.
+type DetectResult struct {
+	Path  string
+	Count string
+	// results in the order of the engines. nil means the detection was failed.
+	Results []*engine.FaceResult
+	// errors of each engine in the order of the engines.
+	Errors []error
+	// elapsed time of each engine in the order of the engines. (zero when the detection was failed)
+	Latencies []time.Duration
+	// error of reading the image. (e.g. download error)
+	Error error
+}
+
+// detectImage detects faces of the image by the engines.
+func detectImage(engines []engine.Engine, img Input, reader imageReader) DetectResult {
+	out := DetectResult{
+		Path:      img.Path,
+		Count:     img.Count,
+		Results:   make([]*engine.FaceResult, len(engines)),
+		Errors:    make([]error, len(engines)),
+		Latencies: make([]time.Duration, len(engines)),
+	}
+
+	var in *detectInput
+	if img.Data != nil {
+		in = newDetectInputFromBytes(img.Path, img.Data)
+	} else {
+		var err error
+		in, err = newDetectInput(img.Path, reader)
+		if err != nil {
+			out.Error = err
+			return out
+		}
+	}
+	defer in.Close()
+
+	for i, e := range engines {
+		// the latency excludes reading the image and writing the temporary file.
+		if err := in.prepare(e); err != nil {
+			out.Errors[i] = err
+			continue
+		}
+		start := time.Now()
+		faceResult, err := in.Detect(e)
+		if err != nil {
+			out.Errors[i] = err
+			continue
+		}
+		out.Latencies[i] = time.Since(start)
+		out.Results[i] = &faceResult
+	}
+	return out
+}
+
+// hasError checks the error of reading the image or any engine.
+func (o DetectResult) hasError() bool {
+	if o.Error != nil {
+		return true
+	}
+	for _, err := range o.Errors {
+		if err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// printErrors prints the errors of reading the image and the engines.
+func (o DetectResult) printErrors() {
+	if o.Error != nil {
+		fmt.Printf("[ERROR] path:%s\terr:%s\n", o.Path, o.Error.Error())
+	}
+	for _, err := range o.Errors {
+		if err != nil {
+			fmt.Printf("[ERROR] %s\n", err.Error())
+		}
+	}
+}
+
+// Row returns the line of detector's output TSV.
+func (o DetectResult) Row() string {
+	row := make([]string, 0, len(o.Results)+3)
+	row = append(row, o.Path, o.Count)
+	for i, r := range o.Results {
+		if r == nil {
+			// keep empty columns of count, detail and latency.
+			row = append(row, "\t\t")
+			continue
+		}
+		latency := ""
+		if i < len(o.Latencies) {
+			latency = formatMsec(o.Latencies[i])
+		}
+		row = append(row, r.ShowOutput(), latency)
+	}
+
+	errMsg := ""
+	if o.Error != nil {
+		errMsg = strings.Join(strings.Fields(o.Error.Error()), " ")
+	}
+	row = append(row, errMsg)
+	return strings.Join(row, "\t")
+}
+
+// detectRecord is a line of JSONL output.
+type detectRecord struct {
+	Path    string              `json:"path"`
+	Count   string              `json:"count,omitempty"`
+	Results []engine.FaceResult `json:"results"`
+	// elapsed time of the engines in milliseconds by the engine name.
+	LatencyMsec map[string]float64 `json:"latency_ms,omitempty"`
+	// error messages of the failed engines by the engine name.
+	EngineErrors map[string]string `json:"engine_errors,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// record returns the data for JSONL output. The failed engines are not included in the results.
+func (o DetectResult) record(engines []engine.Engine) detectRecord {
+	r := detectRecord{
+		Path:    o.Path,
+		Count:   o.Count,
+		Results: make([]engine.FaceResult, 0, len(o.Results)),
+	}
+	for i, res := range o.Results {
+		if res != nil {
+			r.Results = append(r.Results, *res)
+			if i < len(engines) && i < len(o.Latencies) {
+				if r.LatencyMsec == nil {
+					r.LatencyMsec = make(map[string]float64)
+				}
+				r.LatencyMsec[engines[i].String()] = toMsec(o.Latencies[i])
+			}
+			continue
+		}
+		if i < len(o.Errors) && o.Errors[i] != nil && i < len(engines) {
+			if r.EngineErrors == nil {
+				r.EngineErrors = make(map[string]string)
+			}
+			r.EngineErrors[engines[i].String()] = o.Errors[i].Error()
+		}
+	}
+	if o.Error != nil {
+		r.Error = o.Error.Error()
+	}
+	return r
+}
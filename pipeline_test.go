@@ -0,0 +1,257 @@
+package fda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// testSink records the calls of Open and Close.
+type testSink struct {
+	openErr error
+	opened  bool
+	closed  bool
+}
+
+func (s *testSink) Open(engines []engine.Engine) error {
+	if s.openErr != nil {
+		return s.openErr
+	}
+	s.opened = true
+	return nil
+}
+
+func (s *testSink) Write(r DetectResult) error { return nil }
+
+func (s *testSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestNewPipelineClosesSinksOnOpenError(t *testing.T) {
+	opened := &testSink{}
+	failed := &testSink{openErr: errors.New("open error")}
+	notOpened := &testSink{}
+
+	_, err := NewPipeline(
+		WithEngines(testEngine{}),
+		WithSink(opened),
+		WithSink(failed),
+		WithSink(notOpened),
+	)
+	if err == nil {
+		t.Fatal("err = nil, want open error")
+	}
+	if !opened.closed {
+		t.Error("the opened sink is not closed")
+	}
+	if failed.closed || notOpened.opened || notOpened.closed {
+		t.Error("the sinks after the failed sink are opened or closed")
+	}
+}
+
+// namedEngine is testEngine with the name.
+type namedEngine struct {
+	testEngine
+	name string
+}
+
+func (e namedEngine) String() string { return e.name }
+
+func TestNewPipelineEngineRegistry(t *testing.T) {
+	if _, err := NewPipeline(WithConfig(Config{})); err == nil {
+		t.Error("err = nil without engines, want error")
+	}
+
+	p, err := NewPipeline(
+		WithConfig(Config{}),
+		WithEngineRegistry(namedEngine{name: "pigo"}, namedEngine{name: "dlib"}),
+		WithEngineNames("pigo"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	engines := p.Engines()
+	if len(engines) != 1 || engines[0].String() != "pigo" {
+		t.Errorf("engines = %v, want [pigo]", engines)
+	}
+}
+
+// delayEngine delays the detection by the first byte of the image data in milliseconds.
+type delayEngine struct {
+	testEngine
+}
+
+func (e delayEngine) DetectBytes(byt []byte) (engine.FaceResult, error) {
+	if len(byt) != 0 {
+		time.Sleep(time.Duration(byt[0]) * time.Millisecond)
+	}
+	return e.testEngine.DetectBytes(byt)
+}
+
+// recordSink records the paths of the results, and returns error on the failAt-th write. (0 means no error)
+type recordSink struct {
+	testSink
+	failAt int
+
+	mu    sync.Mutex
+	paths []string
+}
+
+func (s *recordSink) Write(r DetectResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paths = append(s.paths, r.Path)
+	if len(s.paths) == s.failAt {
+		return errors.New("write error")
+	}
+	return nil
+}
+
+func (s *recordSink) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.paths...)
+}
+
+// newDelayInputs returns the inputs which the later ones are detected faster.
+func newDelayInputs(n int) []Input {
+	inputs := make([]Input, n)
+	for i := range inputs {
+		inputs[i] = Input{
+			Path: fmt.Sprintf("%03d.png", i),
+			Data: []byte{byte((n - i) % 7)},
+		}
+	}
+	return inputs
+}
+
+// waitGoroutines waits for the goroutines started after the count to finish.
+func waitGoroutines(t *testing.T, count int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > count {
+		if time.Now().After(deadline) {
+			t.Errorf("goroutines = %d, want %d: leaked", runtime.NumGoroutine(), count)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPipelineRunOrder(t *testing.T) {
+	before := runtime.NumGoroutine()
+	sink := &recordSink{}
+	var mu sync.Mutex
+	var called []string
+	p, err := NewPipeline(
+		WithEngines(delayEngine{}),
+		WithConcurrency(8),
+		WithSink(sink),
+		WithCallback(func(r DetectResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			called = append(called, r.Path)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := newDelayInputs(50)
+	if err := p.RunInputs(context.Background(), inputs); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := sink.Paths()
+	if len(paths) != len(inputs) || len(called) != len(inputs) {
+		t.Fatalf("sink = %d, callback = %d, want %d results", len(paths), len(called), len(inputs))
+	}
+	for i, in := range inputs {
+		if paths[i] != in.Path || called[i] != in.Path {
+			t.Fatalf("#%d sink = %s, callback = %s, want %s in the order of inputs", i, paths[i], called[i], in.Path)
+		}
+	}
+	if !sink.closed {
+		t.Error("sink is not closed")
+	}
+	waitGoroutines(t, before)
+}
+
+func TestPipelineRunSinkError(t *testing.T) {
+	before := runtime.NumGoroutine()
+	sink := &recordSink{failAt: 5}
+	var mu sync.Mutex
+	called := 0
+	p, err := NewPipeline(
+		WithEngines(delayEngine{}),
+		WithConcurrency(4),
+		WithSink(sink),
+		WithCallback(func(r DetectResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			called++
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	err = p.RunInputs(context.Background(), newDelayInputs(100))
+	if err == nil || err.Error() != "write error" {
+		t.Errorf("err = %v, want write error of the sink", err)
+	}
+	if n := len(sink.Paths()); n != 5 {
+		t.Errorf("writes = %d, want to stop at 5", n)
+	}
+	mu.Lock()
+	if called != 4 {
+		t.Errorf("callbacks = %d, want 4 before the failed write", called)
+	}
+	mu.Unlock()
+	waitGoroutines(t, before)
+}
+
+func TestPipelineRunCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	sink := &recordSink{}
+	p, err := NewPipeline(WithEngines(delayEngine{}), WithConcurrency(4), WithSink(sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// the channel is not closed, and Run stops by the cancel.
+	ctx, cancel := context.WithCancel(context.Background())
+	inputs := make(chan Input)
+	go func() {
+		for _, in := range newDelayInputs(3) {
+			inputs <- in
+		}
+		cancel()
+	}()
+
+	if err := p.Run(ctx, inputs); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	for i, path := range sink.Paths() {
+		if want := fmt.Sprintf("%03d.png", i); path != want {
+			t.Errorf("#%d path = %s, want %s", i, path, want)
+		}
+	}
+	waitGoroutines(t, before)
+}
@@ -0,0 +1,83 @@
+package fda
+
+import "html/template"
+
+const reportStyle = `
+<style>
+body { font-family: sans-serif; margin: 20px; color: #222; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.pager a, .pager span { margin-right: 6px; }
+.toggles label { margin-right: 14px; font-weight: bold; }
+.item { display: inline-block; vertical-align: top; margin: 0 12px 24px 0; max-width: 640px; }
+.item .frame { position: relative; }
+.item img { display: block; max-width: 640px; height: auto; }
+.item svg { position: absolute; top: 0; left: 0; width: 100%; height: 100%; }
+.item .caption { font-size: 12px; word-break: break-all; }
+.item .error { color: #c00; }
+</style>
+`
+
+var reportIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+` + reportStyle + `
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Total}} images</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>engine</th><th>images</th><th>errors</th><th>faces</th><th>faces/image</th><th>images with faces</th><th>count match</th><th>mean latency</th></tr>
+{{range .Summary}}<tr><td>{{.Engine}}</td><td>{{.Images}}</td><td>{{.Errors}}</td><td>{{.Faces}}</td><td>{{.FacesPerImage}}</td><td>{{.ImagesWithFaces}}</td><td>{{.ExpectedRate}}</td><td>{{.MeanLatency}}</td></tr>
+{{end}}</table>
+
+<h2>Face count agreement</h2>
+<table>
+<tr><th></th>{{range .Agreement.Engines}}<th>{{.}}</th>{{end}}</tr>
+{{range .Agreement.Rows}}<tr><td>{{.Engine}}</td>{{range .Rates}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+
+<h2>Gallery</h2>
+<p class="pager">{{range .Pages}}<a href="{{.File}}">{{.Number}}</a>{{end}}</p>
+</body>
+</html>
+`))
+
+var reportPageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+` + reportStyle + `
+<style>
+{{range .Engines}}.hide-e{{.Index}} .e{{.Index}} { display: none; }
+{{end}}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="pager"><a href="index.html">summary</a> |
+{{$current := .Current}}{{range .Pages}}{{if eq .Number $current}}<span>{{.Number}}</span>{{else}}<a href="{{.File}}">{{.Number}}</a>{{end}}{{end}}</p>
+<p class="toggles">{{range .Engines}}<label style="color: {{.Color}}"><input type="checkbox" checked onchange="document.body.classList.toggle('hide-e{{.Index}}', !this.checked)">{{.Name}}</label>{{end}}</p>
+
+{{range .Items}}<div class="item">
+{{if .Error}}<div class="caption">#{{.Number}} {{.Path}}</div><div class="caption error">{{.Error}}</div>
+{{else}}<div class="frame">
+<img src="{{.Src}}" width="{{.Width}}" height="{{.Height}}" loading="lazy">
+<svg viewBox="0 0 {{.Width}} {{.Height}}" preserveAspectRatio="none">
+{{range .Overlays}}<g class="e{{.Index}}" stroke="{{.Color}}" fill="none" stroke-width="3">
+{{range .Faces}}<rect x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="{{.Height}}" vector-effect="non-scaling-stroke"><title>{{.Label}}</title></rect>
+{{end}}</g>
+{{end}}</svg>
+</div>
+<div class="caption">#{{.Number}} {{.Path}}{{if .Count}} (count: {{.Count}}){{end}}</div>
+<div class="caption">{{range .Overlays}}<span class="e{{.Index}}" style="color: {{.Color}}">{{.Engine}}:{{if .Failed}}error{{else}}{{len .Faces}}{{end}}</span> {{end}}</div>
+{{end}}</div>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,239 @@
+package fda
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	SDK "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/evalphobia/aws-sdk-go-wrapper/config"
+)
+
+const s3Scheme = "s3://"
+
+const (
+	// size of the head of object to read the image size.
+	s3HeadSize = 64 << 10
+	// default max size of an object read into memory.
+	defaultMaxS3ObjectSize = 100 << 20
+)
+
+// isS3Path checks the path is like 's3://bucket/key'.
+func isS3Path(p string) bool {
+	return strings.HasPrefix(p, s3Scheme)
+}
+
+// splitS3Path splits the path like 's3://bucket/prefix/001.jpg' into the bucket and the key.
+func splitS3Path(p string) (bucket, key string, ok bool) {
+	if !isS3Path(p) {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(p, s3Scheme), "/", 2)
+	if parts[0] == "" {
+		return "", "", false
+	}
+	if len(parts) == 1 {
+		return parts[0], "", true
+	}
+	return parts[0], parts[1], true
+}
+
+// s3DirPrefix adds '/' to the prefix, not to list the keys of the sibling like 'photos-old/' for 'photos'.
+func s3DirPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// s3Storage reads the objects from S3 or S3 compatible storage.
+type s3Storage struct {
+	// endpoint of S3 compatible storage. (empty means AWS S3)
+	endpoint string
+	// max size of an object read by ReadFile. (0 means no limit)
+	maxSize int64
+
+	once   sync.Once
+	client *SDK.S3
+	err    error
+}
+
+func newS3Storage(endpoint string) *s3Storage {
+	return &s3Storage{
+		endpoint: endpoint,
+		maxSize:  defaultMaxS3ObjectSize,
+	}
+}
+
+// s3Objects is shared by the commands reading the image paths in S3 without Pipeline.
+// The endpoint is set by FDA_S3_ENDPOINT.
+var s3Objects = newS3Storage(os.Getenv(keyConfigS3Endpoint))
+
+func (s *s3Storage) getClient() (*SDK.S3, error) {
+	s.once.Do(func() {
+		conf := config.Config{
+			Endpoint: s.endpoint,
+			// bucket name in the host does not work in the most of local servers.
+			S3ForcePathStyle: s.endpoint != "",
+		}
+		sess, err := conf.Session()
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.client = SDK.New(sess)
+	})
+	return s.client, s.err
+}
+
+// IsAWS checks the objects are in AWS S3, which other AWS services can read directly.
+func (s *s3Storage) IsAWS() bool {
+	return s.endpoint == ""
+}
+
+// Walk calls fn with each object under the path like 's3://bucket/prefix'. The prefix is treated as a directory.
+func (s *s3Storage) Walk(p string, fn func(key string, info os.FileInfo) error) error {
+	bucket, prefix, ok := splitS3Path(p)
+	if !ok {
+		return fmt.Errorf("invalid s3 path: [%s]", p)
+	}
+	prefix = s3DirPrefix(prefix)
+	cli, err := s.getClient()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	err = cli.ListObjectsV2Pages(&SDK.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *SDK.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			key := aws.StringValue(obj.Key)
+			if strings.HasSuffix(key, "/") {
+				continue
+			}
+			info := s3FileInfo{
+				name:    path.Base(key),
+				size:    aws.Int64Value(obj.Size),
+				modTime: aws.TimeValue(obj.LastModified),
+			}
+			if fnErr = fn(key, info); fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// ReadFile reads the object of the path like 's3://bucket/key'.
+func (s *s3Storage) ReadFile(p string) ([]byte, error) {
+	body, size, err := s.open(p, "")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	if s.maxSize > 0 && size > s.maxSize {
+		return nil, fmt.Errorf("too large object size=[%d] path=[%s]", size, p)
+	}
+	var r io.Reader = body
+	if s.maxSize > 0 {
+		r = io.LimitReader(body, s.maxSize+1)
+	}
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if s.maxSize > 0 && int64(len(byt)) > s.maxSize {
+		return nil, fmt.Errorf("too large object size=[>%d] path=[%s]", s.maxSize, p)
+	}
+	return byt, nil
+}
+
+// ReadHead reads the first n bytes of the object.
+func (s *s3Storage) ReadHead(p string, n int64) ([]byte, error) {
+	body, _, err := s.open(p, fmt.Sprintf("bytes=0-%d", n-1))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// some servers ignore the range and return whole object.
+	return ioutil.ReadAll(io.LimitReader(body, n))
+}
+
+// open returns the body and the size of the object.
+func (s *s3Storage) open(p, byteRange string) (io.ReadCloser, int64, error) {
+	bucket, key, ok := splitS3Path(p)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid s3 path: [%s]", p)
+	}
+	cli, err := s.getClient()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	in := &SDK.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if byteRange != "" {
+		in.Range = aws.String(byteRange)
+	}
+	resp, err := cli.GetObject(in)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, aws.Int64Value(resp.ContentLength), nil
+}
+
+// Stat returns the file info of the object.
+func (s *s3Storage) Stat(p string) (os.FileInfo, error) {
+	bucket, key, ok := splitS3Path(p)
+	if !ok {
+		return nil, fmt.Errorf("invalid s3 path: [%s]", p)
+	}
+	cli, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cli.HeadObject(&SDK.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s3FileInfo{
+		name:    path.Base(key),
+		size:    aws.Int64Value(resp.ContentLength),
+		modTime: aws.TimeValue(resp.LastModified),
+	}, nil
+}
+
+// s3FileInfo is os.FileInfo of S3 object.
+type s3FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i s3FileInfo) Name() string       { return i.name }
+func (i s3FileInfo) Size() int64        { return i.size }
+func (i s3FileInfo) Mode() os.FileMode  { return 0444 }
+func (i s3FileInfo) ModTime() time.Time { return i.modTime }
+func (i s3FileInfo) IsDir() bool        { return false }
+func (i s3FileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,195 @@
+package fda
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	SDK "github.com/aws/aws-sdk-go/service/s3"
+)
+
+// fakeS3 serves ListObjectsV2 and GetObject of a bucket in path style.
+type fakeS3 struct {
+	bucket  string
+	objects map[string][]byte
+}
+
+type fakeS3ListResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string   `xml:"Name"`
+	Prefix      string   `xml:"Prefix"`
+	KeyCount    int      `xml:"KeyCount"`
+	IsTruncated bool     `xml:"IsTruncated"`
+	Contents    []fakeS3Object
+}
+
+type fakeS3Object struct {
+	Key          string `xml:"Key"`
+	Size         int    `xml:"Size"`
+	LastModified string `xml:"LastModified"`
+}
+
+func (s fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path == s.bucket && r.URL.Query().Get("list-type") == "2" {
+		prefix := r.URL.Query().Get("prefix")
+		result := fakeS3ListResult{Name: s.bucket, Prefix: prefix}
+		for key, data := range s.objects {
+			if strings.HasPrefix(key, prefix) {
+				result.Contents = append(result.Contents, fakeS3Object{Key: key, Size: len(data), LastModified: "2020-01-01T00:00:00.000Z"})
+			}
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		result.KeyCount = len(result.Contents)
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(result)
+		return
+	}
+
+	data, ok := s.objects[strings.TrimPrefix(path, s.bucket+"/")]
+	if !ok || !strings.HasPrefix(path, s.bucket+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+		return
+	}
+	// ServeContent handles the Range header.
+	http.ServeContent(w, r, path, time.Time{}, bytes.NewReader(data))
+}
+
+func newTestS3Storage(t *testing.T, objects map[string][]byte) (*s3Storage, func()) {
+	t.Helper()
+	ts := httptest.NewServer(fakeS3{bucket: "bucket", objects: objects})
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials("key", "secret", ""),
+		Endpoint:         aws.String(ts.URL),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+
+	s := newS3Storage(ts.URL)
+	s.once.Do(func() {
+		s.client = SDK.New(sess)
+	})
+	return s, ts.Close
+}
+
+func TestS3StorageWalk(t *testing.T) {
+	s, cleanup := newTestS3Storage(t, map[string][]byte{
+		"photos/":            nil,
+		"photos/a.jpg":       []byte("a"),
+		"photos/sub/b.jpg":   []byte("b"),
+		"photos-old/c.jpg":   []byte("c"),
+		"other/photos/d.jpg": []byte("d"),
+	})
+	defer cleanup()
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{path: "s3://bucket/photos", want: []string{"photos/a.jpg", "photos/sub/b.jpg"}},
+		{path: "s3://bucket/photos/", want: []string{"photos/a.jpg", "photos/sub/b.jpg"}},
+		{path: "s3://bucket", want: []string{"other/photos/d.jpg", "photos-old/c.jpg", "photos/a.jpg", "photos/sub/b.jpg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var keys []string
+			err := s.Walk(tt.path, func(key string, info os.FileInfo) error {
+				keys = append(keys, key)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalkS3RelativePath(t *testing.T) {
+	s, cleanup := newTestS3Storage(t, map[string][]byte{
+		"photos/a.jpg":     []byte("a"),
+		"photos/sub/b.jpg": []byte("b"),
+		"photos-old/c.jpg": []byte("c"),
+	})
+	defer cleanup()
+
+	var mu sync.Mutex
+	var paths []string
+	w := &fileWalker{
+		opt:   WalkOption{Include: []string{"*/*"}},
+		types: newFileType([]string{"jpg"}),
+		s3:    s,
+		fn: func(path string) {
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, path)
+		},
+	}
+	if err := w.walkS3("s3://bucket/photos"); err != nil {
+		t.Fatal(err)
+	}
+	// the pattern is matched with the path relative to 'photos/'.
+	if want := []string{"s3://bucket/photos/sub/b.jpg"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+func TestS3StorageRead(t *testing.T) {
+	s, cleanup := newTestS3Storage(t, map[string][]byte{
+		"photos/a.jpg":     []byte("0123456789"),
+		"photos/large.jpg": bytes.Repeat([]byte("x"), 100),
+	})
+	defer cleanup()
+	s.maxSize = 10
+
+	byt, err := s.ReadFile("s3://bucket/photos/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(byt) != "0123456789" {
+		t.Errorf("ReadFile = %s, want 0123456789", byt)
+	}
+
+	byt, err = s.ReadHead("s3://bucket/photos/a.jpg", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(byt) != "0123" {
+		t.Errorf("ReadHead = %s, want 0123", byt)
+	}
+
+	// the head is read regardless of the max size.
+	byt, err = s.ReadHead("s3://bucket/photos/large.jpg", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byt) != 20 {
+		t.Errorf("ReadHead = %d bytes, want 20 bytes", len(byt))
+	}
+
+	if _, err := s.ReadFile("s3://bucket/photos/large.jpg"); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("err = %v, want too large error", err)
+	}
+	if _, err := s.ReadFile("s3://bucket/photos/missing.jpg"); err == nil {
+		t.Error("err = nil, want not found error")
+	}
+}
@@ -0,0 +1,241 @@
+package fda
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// Sink writes the detection results of Pipeline.
+type Sink interface {
+	// Open is called with the initialized engines before writing.
+	Open(engines []engine.Engine) error
+	Write(r DetectResult) error
+	Close() error
+}
+
+const (
+	outputFormatTSV   = "tsv"
+	outputFormatJSONL = "jsonl"
+	outputFormatCSV   = "csv"
+)
+
+// outputFormats is the sinks of the output formats added by AddOutputFormat.
+var outputFormats = make(map[string]func(path string) (Sink, error))
+
+// AddOutputFormat adds the sink of the output format for detect command.
+// It's used for the sinks which have extra dependencies, like sqlite package which requires cgo.
+func AddOutputFormat(format string, fn func(path string) (Sink, error)) {
+	outputFormats[format] = fn
+}
+
+// newSink returns the sink of the output format.
+func newSink(format, path string) (Sink, error) {
+	switch format {
+	case outputFormatTSV, "":
+		return NewTSVSink(path)
+	case outputFormatJSONL:
+		return NewJSONLSink(path)
+	case outputFormatCSV:
+		return NewFaceCSVSink(path)
+	}
+	if fn, ok := outputFormats[format]; ok {
+		return fn(path)
+	}
+	return nil, fmt.Errorf("unknown output format: [%s]", format)
+}
+
+// TSVSink writes the results in the format of detector's output TSV.
+type TSVSink struct {
+	path string
+
+	mu sync.Mutex
+	fp *os.File
+}
+
+// NewTSVSink returns the sink writing into the file path.
+func NewTSVSink(path string) (*TSVSink, error) {
+	if _, err := NewFileHandler(path); err != nil {
+		return nil, err
+	}
+	return &TSVSink{
+		path: path,
+	}, nil
+}
+
+func (s *TSVSink) Open(engines []engine.Engine) error {
+	fp, err := os.Create(s.path)
+	if err != nil {
+		return err
+	}
+	if _, err := fp.WriteString(getHeader(engines)); err != nil {
+		fp.Close()
+		return err
+	}
+	s.fp = fp
+	return nil
+}
+
+func (s *TSVSink) Write(r DetectResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fp == nil {
+		return fmt.Errorf("sink is not opened: [%s]", s.path)
+	}
+	// the last line does not have a newline, same as FileHandler.WriteAll.
+	_, err := s.fp.WriteString("\n" + r.Row())
+	return err
+}
+
+func (s *TSVSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fp == nil {
+		return nil
+	}
+	if err := s.fp.Sync(); err != nil {
+		s.fp.Close()
+		return err
+	}
+	return s.fp.Close()
+}
+
+// JSONLSink writes a JSON object of all engines per image in each line.
+type JSONLSink struct {
+	path    string
+	engines []engine.Engine
+
+	mu sync.Mutex
+	fp *os.File
+}
+
+// NewJSONLSink returns the sink writing into the file path.
+func NewJSONLSink(path string) (*JSONLSink, error) {
+	if _, err := NewFileHandler(path); err != nil {
+		return nil, err
+	}
+	return &JSONLSink{
+		path: path,
+	}, nil
+}
+
+func (s *JSONLSink) Open(engines []engine.Engine) error {
+	fp, err := os.Create(s.path)
+	if err != nil {
+		return err
+	}
+	s.fp = fp
+	s.engines = engines
+	return nil
+}
+
+func (s *JSONLSink) Write(r DetectResult) error {
+	byt, err := json.Marshal(r.record(s.engines))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fp == nil {
+		return fmt.Errorf("sink is not opened: [%s]", s.path)
+	}
+	_, err = s.fp.Write(append(byt, '\n'))
+	return err
+}
+
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fp == nil {
+		return nil
+	}
+	if err := s.fp.Sync(); err != nil {
+		s.fp.Close()
+		return err
+	}
+	return s.fp.Close()
+}
+
+// FaceCSVSink writes a row per face. The images without faces are not written.
+type FaceCSVSink struct {
+	path    string
+	engines []engine.Engine
+	w       *CSVWriter
+}
+
+var faceCSVHeader = []string{"path", "engine", "face_index", "x", "y", "width", "height", "width_per", "height_per", "confidence", "raw_confidence"}
+
+// NewFaceCSVSink returns the sink writing into the file path.
+func NewFaceCSVSink(path string) (*FaceCSVSink, error) {
+	if _, err := NewFileHandler(path); err != nil {
+		return nil, err
+	}
+	return &FaceCSVSink{
+		path: path,
+	}, nil
+}
+
+func (s *FaceCSVSink) Open(engines []engine.Engine) error {
+	w, err := NewCSVWriter(s.path)
+	if err != nil {
+		return err
+	}
+	if err := w.Write(faceCSVHeader); err != nil {
+		w.Close()
+		return err
+	}
+	s.w = w
+	s.engines = engines
+	return nil
+}
+
+func (s *FaceCSVSink) Write(r DetectResult) error {
+	if s.w == nil {
+		return fmt.Errorf("sink is not opened: [%s]", s.path)
+	}
+
+	for i, res := range r.Results {
+		if res == nil {
+			continue
+		}
+		for j, f := range res.Faces {
+			err := s.w.Write([]string{
+				r.Path,
+				s.engines[i].String(),
+				strconv.Itoa(j),
+				strconv.Itoa(f.X),
+				strconv.Itoa(f.Y),
+				strconv.Itoa(f.Width),
+				strconv.Itoa(f.Height),
+				formatFloat(f.PercentWidth),
+				formatFloat(f.PercentHeight),
+				formatFloat(f.Confidence),
+				formatFloat(f.RawConfidence),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *FaceCSVSink) Close() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Close()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
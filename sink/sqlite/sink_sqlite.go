@@ -0,0 +1,297 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	// SQLite driver
+	_ "github.com/mattn/go-sqlite3"
+
+	fda "github.com/evalphobia/face-detect-annotator"
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// OutputFormat is the name of output format. Register it by fda.AddOutputFormat(sqlite.OutputFormat, sqlite.NewSink).
+const OutputFormat = "sqlite"
+
+// number of images committed at once.
+const sqliteBatchSize = 100
+
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS images (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		count TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS engines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE
+	)`,
+	// result of each engine per image, to distinguish no face from the failure.
+	`CREATE TABLE IF NOT EXISTS results (
+		image_id INTEGER NOT NULL REFERENCES images(id),
+		engine_id INTEGER NOT NULL REFERENCES engines(id),
+		face_count INTEGER,
+		latency_ms REAL,
+		error TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (image_id, engine_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS faces (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		image_id INTEGER NOT NULL REFERENCES images(id),
+		engine_id INTEGER NOT NULL REFERENCES engines(id),
+		face_index INTEGER NOT NULL,
+		x INTEGER NOT NULL,
+		y INTEGER NOT NULL,
+		width INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		width_per REAL NOT NULL,
+		height_per REAL NOT NULL,
+		confidence REAL NOT NULL,
+		raw_confidence REAL,
+		landmarks TEXT,
+		pose TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_images_path ON images (path)`,
+	`CREATE INDEX IF NOT EXISTS idx_faces_image ON faces (image_id, engine_id)`,
+}
+
+// sqliteAddedColumns is the columns added after the first schema, to migrate the existing database.
+var sqliteAddedColumns = []struct {
+	table  string
+	column string
+	typ    string
+}{
+	{"results", "latency_ms", "REAL"},
+	{"faces", "raw_confidence", "REAL"},
+}
+
+// migrate adds the missing columns into the tables created by the older version.
+func migrate(db *sql.DB) error {
+	for _, c := range sqliteAddedColumns {
+		ok, err := hasColumn(db, c.table, c.column)
+		switch {
+		case err != nil:
+			return err
+		case ok:
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.column, c.typ)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: [%s]", c.table, c.column, err.Error())
+		}
+	}
+	return nil
+}
+
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+	for rows.Next() {
+		// cid, name, type, notnull, dflt_value, pk
+		values := make([]interface{}, len(cols))
+		var name string
+		for i := range values {
+			values[i] = new(interface{})
+		}
+		values[1] = &name
+		if err := rows.Scan(values...); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// Sink writes the results into images, engines, results and faces tables of SQLite database.
+// The results are appended when the database already exists, and the columns missing in the database of the older version are added.
+type Sink struct {
+	path string
+
+	mu        sync.Mutex
+	db        *sql.DB
+	tx        *sql.Tx
+	txCount   int
+	engineIDs []int64
+}
+
+// NewSink returns the sink writing into the database file path.
+func NewSink(path string) (fda.Sink, error) {
+	if _, err := fda.NewFileHandler(path); err != nil {
+		return nil, err
+	}
+	return &Sink{
+		path: path,
+	}, nil
+}
+
+func (s *Sink) Open(engines []engine.Engine) error {
+	db, err := sql.Open("sqlite3", s.path)
+	if err != nil {
+		return err
+	}
+	// writes are serialized by the mutex.
+	db.SetMaxOpenConns(1)
+
+	for _, q := range sqliteSchema {
+		if _, err := db.Exec(q); err != nil {
+			db.Close()
+			return err
+		}
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return err
+	}
+
+	ids := make([]int64, len(engines))
+	for i, e := range engines {
+		if _, err := db.Exec(`INSERT OR IGNORE INTO engines (name) VALUES (?)`, e.String()); err != nil {
+			db.Close()
+			return err
+		}
+		if err := db.QueryRow(`SELECT id FROM engines WHERE name = ?`, e.String()).Scan(&ids[i]); err != nil {
+			db.Close()
+			return err
+		}
+	}
+
+	s.db = db
+	s.engineIDs = ids
+	return nil
+}
+
+func (s *Sink) Write(r fda.DetectResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return fmt.Errorf("sink is not opened: [%s]", s.path)
+	}
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		s.tx = tx
+	}
+
+	if err := s.insert(s.tx, r); err != nil {
+		return err
+	}
+
+	s.txCount++
+	if s.txCount < sqliteBatchSize {
+		return nil
+	}
+	return s.commit()
+}
+
+func (s *Sink) insert(tx *sql.Tx, r fda.DetectResult) error {
+	errMsg := ""
+	if r.Error != nil {
+		errMsg = r.Error.Error()
+	}
+	res, err := tx.Exec(`INSERT INTO images (path, count, error) VALUES (?, ?, ?)`, r.Path, r.Count, errMsg)
+	if err != nil {
+		return err
+	}
+	imageID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if r.Error != nil {
+		return nil
+	}
+
+	for i, result := range r.Results {
+		engineID := s.engineIDs[i]
+		if result == nil {
+			engineErr := ""
+			if i < len(r.Errors) && r.Errors[i] != nil {
+				engineErr = r.Errors[i].Error()
+			}
+			if _, err := tx.Exec(`INSERT INTO results (image_id, engine_id, face_count, error) VALUES (?, ?, NULL, ?)`, imageID, engineID, engineErr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var latency interface{}
+		if i < len(r.Latencies) {
+			latency = float64(r.Latencies[i]) / float64(time.Millisecond)
+		}
+		if _, err := tx.Exec(`INSERT INTO results (image_id, engine_id, face_count, latency_ms) VALUES (?, ?, ?, ?)`, imageID, engineID, len(result.Faces), latency); err != nil {
+			return err
+		}
+		for j, f := range result.Faces {
+			landmarks, pose, err := marshalFaceOptions(f)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`INSERT INTO faces (image_id, engine_id, face_index, x, y, width, height, width_per, height_per, confidence, raw_confidence, landmarks, pose)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				imageID, engineID, j, f.X, f.Y, f.Width, f.Height, f.PercentWidth, f.PercentHeight, f.Confidence, f.RawConfidence, landmarks, pose)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// marshalFaceOptions returns the landmarks and the pose in JSON. nil means the engine does not support them.
+func marshalFaceOptions(f engine.FaceData) (landmarks, pose interface{}, err error) {
+	if f.HasLandmarks() {
+		byt, err := json.Marshal(f.Landmarks)
+		if err != nil {
+			return nil, nil, err
+		}
+		landmarks = string(byt)
+	}
+	if f.HasPose() {
+		byt, err := json.Marshal(f.Pose)
+		if err != nil {
+			return nil, nil, err
+		}
+		pose = string(byt)
+	}
+	return landmarks, pose, nil
+}
+
+func (s *Sink) commit() error {
+	if s.tx == nil {
+		return nil
+	}
+	err := s.tx.Commit()
+	s.tx = nil
+	s.txCount = 0
+	return err
+}
+
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+	err := s.commit()
+	if closeErr := s.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
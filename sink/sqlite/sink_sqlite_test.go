@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fda "github.com/evalphobia/face-detect-annotator"
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// schema of the first version, without results.latency_ms and faces.raw_confidence.
+var oldSchema = []string{
+	`CREATE TABLE images (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		count TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE engines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE
+	)`,
+	`CREATE TABLE results (
+		image_id INTEGER NOT NULL REFERENCES images(id),
+		engine_id INTEGER NOT NULL REFERENCES engines(id),
+		face_count INTEGER,
+		error TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (image_id, engine_id)
+	)`,
+	`CREATE TABLE faces (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		image_id INTEGER NOT NULL REFERENCES images(id),
+		engine_id INTEGER NOT NULL REFERENCES engines(id),
+		face_index INTEGER NOT NULL,
+		x INTEGER NOT NULL,
+		y INTEGER NOT NULL,
+		width INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		width_per REAL NOT NULL,
+		height_per REAL NOT NULL,
+		confidence REAL NOT NULL,
+		landmarks TEXT,
+		pose TEXT
+	)`,
+}
+
+type testEngine struct{}
+
+func (testEngine) Init(conf engine.Config) error { return nil }
+func (testEngine) String() string                { return "test" }
+func (testEngine) Detect(imgPath string) (engine.FaceResult, error) {
+	return engine.FaceResult{}, nil
+}
+
+func TestSinkMigrateOldSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fda-sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "old.db")
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range oldSchema {
+		if _, err := db.Exec(q); err != nil {
+			db.Close()
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	// opens twice to check the migrated database is opened again.
+	for i := 0; i < 2; i++ {
+		s, err := NewSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Open([]engine.Engine{testEngine{}}); err != nil {
+			t.Fatalf("#%d Open: %s", i, err.Error())
+		}
+		err = s.Write(fda.DetectResult{
+			Path:      "a.jpg",
+			Count:     "1",
+			Results:   []*engine.FaceResult{{Faces: []engine.FaceData{{Width: 2, Height: 2, Confidence: 0.5, RawConfidence: 12.5}}}},
+			Errors:    []error{nil},
+			Latencies: []time.Duration{3 * time.Millisecond},
+		})
+		if err != nil {
+			t.Fatalf("#%d Write: %s", i, err.Error())
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("#%d Close: %s", i, err.Error())
+		}
+	}
+
+	db, err = sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var raw, latency float64
+	if err := db.QueryRow(`SELECT f.raw_confidence, r.latency_ms FROM faces f JOIN results r ON r.image_id = f.image_id AND r.engine_id = f.engine_id LIMIT 1`).Scan(&raw, &latency); err != nil {
+		t.Fatal(err)
+	}
+	if raw != 12.5 || latency != 3 {
+		t.Errorf("raw_confidence, latency_ms = %f, %f, want 12.5, 3", raw, latency)
+	}
+}
@@ -0,0 +1,284 @@
+package fda
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// upper bounds of the face size buckets by the ratio to the image size.
+var faceSizeBounds = []float64{0.02, 0.05, 0.1, 0.2, 0.4}
+
+// max face count of the faces per image buckets. the last bucket includes more faces.
+const maxFaceCountBucket = 5
+
+// detectStats is the statistics of detector's output.
+type detectStats struct {
+	Images     int              `json:"images"`
+	Engines    []engineStats    `json:"engines"`
+	Agreements []countAgreement `json:"agreements"`
+}
+
+// engineStats is the statistics of an engine.
+type engineStats struct {
+	Engine string `json:"engine"`
+	// number of the images detected successfully.
+	Images          int `json:"images"`
+	Errors          int `json:"errors"`
+	Faces           int `json:"faces"`
+	ImagesWithFaces int `json:"images_with_faces"`
+
+	FacesPerImage          distribution   `json:"faces_per_image"`
+	FacesPerImageHistogram []histogramBin `json:"faces_per_image_histogram"`
+	// size of faces by the ratio to the image. (PercentWidth and PercentHeight)
+	FaceWidth         distribution   `json:"face_width"`
+	FaceHeight        distribution   `json:"face_height"`
+	FaceSizeHistogram []histogramBin `json:"face_size_histogram"`
+	// zero confidences are excluded, because some engines do not return it.
+	Confidence          distribution   `json:"confidence"`
+	ConfidenceHistogram []histogramBin `json:"confidence_histogram"`
+}
+
+// distribution is the summary of values.
+type distribution struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+}
+
+// histogramBin is a bin of histogram. The range is [Min, Max), and the last bin of equal width histogram includes Max.
+type histogramBin struct {
+	Label string  `json:"label"`
+	Min   float64 `json:"min"`
+	// zero means no upper limit.
+	Max   float64 `json:"max,omitempty"`
+	Count int     `json:"count"`
+}
+
+// countAgreement is the agreement of face count between two engines on the images detected by both.
+type countAgreement struct {
+	A       string  `json:"a"`
+	B       string  `json:"b"`
+	Images  int     `json:"images"`
+	Matched int     `json:"matched"`
+	Rate    float64 `json:"rate"`
+	// mean of the absolute difference of face count.
+	MeanAbsDiff float64 `json:"mean_abs_diff"`
+}
+
+func newDetectStats(result *detectResult, confidenceBins int) detectStats {
+	s := detectStats{
+		Images:  len(result.Rows),
+		Engines: make([]engineStats, len(result.Engines)),
+	}
+	for i, e := range result.Engines {
+		s.Engines[i] = newEngineStats(result.Rows, e, confidenceBins)
+	}
+	for i, a := range result.Engines {
+		for _, b := range result.Engines[i+1:] {
+			s.Agreements = append(s.Agreements, newCountAgreement(result.Rows, a, b))
+		}
+	}
+	return s
+}
+
+func newEngineStats(rows []detectResultRow, e string, confidenceBins int) engineStats {
+	s := engineStats{Engine: e}
+	var counts, widths, heights, sizes, confidences []float64
+	for _, row := range rows {
+		data, ok := row.Results[e]
+		if !ok {
+			s.Errors++
+			continue
+		}
+		s.Images++
+		s.Faces += len(data.Faces)
+		if data.HasFaces() {
+			s.ImagesWithFaces++
+		}
+		counts = append(counts, float64(len(data.Faces)))
+		for _, f := range data.Faces {
+			widths = append(widths, f.PercentWidth)
+			heights = append(heights, f.PercentHeight)
+			sizes = append(sizes, math.Max(f.PercentWidth, f.PercentHeight))
+			if f.Confidence != 0 {
+				confidences = append(confidences, f.Confidence)
+			}
+		}
+	}
+
+	s.FacesPerImage = newDistribution(counts)
+	s.FacesPerImageHistogram = newFaceCountHistogram(counts)
+	s.FaceWidth = newDistribution(widths)
+	s.FaceHeight = newDistribution(heights)
+	s.FaceSizeHistogram = newBoundsHistogram(sizes, faceSizeBounds)
+	s.Confidence = newDistribution(confidences)
+	s.ConfidenceHistogram = newHistogram(confidences, confidenceBins)
+	return s
+}
+
+func newCountAgreement(rows []detectResultRow, a, b string) countAgreement {
+	ag := countAgreement{A: a, B: b}
+	absDiff := 0
+	for _, row := range rows {
+		ra, okA := row.Results[a]
+		rb, okB := row.Results[b]
+		if !okA || !okB {
+			continue
+		}
+		ag.Images++
+		diff := len(ra.Faces) - len(rb.Faces)
+		if diff == 0 {
+			ag.Matched++
+		}
+		if diff < 0 {
+			diff = -diff
+		}
+		absDiff += diff
+	}
+	if ag.Images != 0 {
+		ag.Rate = float64(ag.Matched) / float64(ag.Images)
+		ag.MeanAbsDiff = float64(absDiff) / float64(ag.Images)
+	}
+	return ag
+}
+
+func newDistribution(values []float64) distribution {
+	if len(values) == 0 {
+		return distribution{}
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	return distribution{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		Mean:  sum / float64(len(sorted)),
+		P50:   percentileFloat(sorted, 50),
+		P90:   percentileFloat(sorted, 90),
+	}
+}
+
+// percentileFloat returns the p-th percentile of the sorted values by nearest-rank method.
+func percentileFloat(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// newFaceCountHistogram returns the histogram of face count from 0 to maxFaceCountBucket or more.
+func newFaceCountHistogram(counts []float64) []histogramBin {
+	bins := make([]histogramBin, maxFaceCountBucket+1)
+	for i := range bins {
+		bins[i] = histogramBin{
+			Label: strconv.Itoa(i),
+			Min:   float64(i),
+			Max:   float64(i + 1),
+		}
+	}
+	last := &bins[maxFaceCountBucket]
+	last.Label += "+"
+	last.Max = 0
+
+	for _, c := range counts {
+		i := int(c)
+		if i > maxFaceCountBucket {
+			i = maxFaceCountBucket
+		}
+		bins[i].Count++
+	}
+	return bins
+}
+
+// newBoundsHistogram returns the histogram of ratio values split by the upper bounds.
+func newBoundsHistogram(values []float64, bounds []float64) []histogramBin {
+	bins := make([]histogramBin, len(bounds)+1)
+	lower := 0.0
+	for i, upper := range bounds {
+		bins[i] = histogramBin{
+			Label: formatRatio(lower) + "-" + formatRatio(upper),
+			Min:   lower,
+			Max:   upper,
+		}
+		lower = upper
+	}
+	bins[len(bounds)] = histogramBin{
+		Label: ">=" + formatRatio(lower),
+		Min:   lower,
+	}
+
+	for _, v := range values {
+		i := sort.Search(len(bounds), func(i int) bool { return v < bounds[i] })
+		bins[i].Count++
+	}
+	return bins
+}
+
+// newHistogram returns the histogram of equal width bins between the min and max of the values.
+func newHistogram(values []float64, size int) []histogramBin {
+	if len(values) == 0 {
+		return nil
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	min, max := values[0], values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	if min == max {
+		size = 1
+	}
+
+	width := (max - min) / float64(size)
+	bins := make([]histogramBin, size)
+	for i := range bins {
+		lower := min + width*float64(i)
+		upper := lower + width
+		if i == size-1 {
+			upper = max
+		}
+		bins[i] = histogramBin{
+			Label: formatStatsFloat(lower) + "-" + formatStatsFloat(upper),
+			Min:   lower,
+			Max:   upper,
+		}
+	}
+	for _, v := range values {
+		i := size - 1
+		if width > 0 {
+			i = int((v - min) / width)
+		}
+		if i >= size {
+			i = size - 1
+		}
+		bins[i].Count++
+	}
+	return bins
+}
+
+// formatRatio returns the ratio in percent like '5%'.
+func formatRatio(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/10, 'f', -1, 64) + "%"
+}
+
+func formatStatsFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
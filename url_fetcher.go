@@ -0,0 +1,210 @@
+package fda
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultFetchConcurrency = 4
+	defaultFetchTimeout     = 30 * time.Second
+	defaultMaxDownloadSize  = 20 << 20
+)
+
+// isURLPath checks the path is http(s) URL.
+func isURLPath(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// getURLExt returns the file extension of the URL path without query string.
+func getURLExt(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return path.Ext(u.Path)
+}
+
+// urlFetcher downloads images from http(s) URL with the limits of concurrency and size.
+type urlFetcher struct {
+	client *http.Client
+	sem    chan struct{}
+	// max file size in bytes. (0 means no limit)
+	maxSize int64
+	// directory to save the downloaded files. (empty means no cache)
+	cacheDir string
+}
+
+func newURLFetcher(concurrency int, timeout time.Duration, maxSize int64, cacheDir string) *urlFetcher {
+	if concurrency < 1 {
+		concurrency = defaultFetchConcurrency
+	}
+	if timeout <= 0 {
+		timeout = defaultFetchTimeout
+	}
+	return &urlFetcher{
+		client: &http.Client{
+			Timeout: timeout,
+		},
+		sem:      make(chan struct{}, concurrency),
+		maxSize:  maxSize,
+		cacheDir: cacheDir,
+	}
+}
+
+// denyPrivateNetwork rejects the connections to private, loopback and link-local addresses,
+// not to access the internal network via the URL from API clients.
+// The address is checked on dialing, so the redirected URLs and the hosts resolved into them are rejected too.
+func (f *urlFetcher) denyPrivateNetwork() {
+	f.client.Transport = &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   denyPrivateAddress,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// privateNetworks are the address ranges which are not reachable from the internet.
+var privateNetworks = func() []*net.IPNet {
+	cidrs := []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"::/128",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+	}
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}()
+
+func isPrivateIP(ip net.IP) bool {
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// denyPrivateAddress is the Control of net.Dialer, which is called with the resolved address before connecting.
+func denyPrivateAddress(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("private network address is not allowed: [%s]", address)
+	}
+	return nil
+}
+
+// Fetch returns the content of the URL from the cache or by downloading.
+func (f *urlFetcher) Fetch(rawURL string) ([]byte, error) {
+	if f.cacheDir != "" {
+		if byt, err := ioutil.ReadFile(f.getCachePath(rawURL)); err == nil {
+			return byt, nil
+		}
+	}
+
+	f.sem <- struct{}{}
+	byt, err := f.download(rawURL)
+	<-f.sem
+	if err != nil {
+		return nil, err
+	}
+
+	if f.cacheDir != "" {
+		if err := f.saveCache(rawURL, byt); err != nil {
+			fmt.Printf("[WARN] cannot save cache url:%s\terr:%s\n", rawURL, err.Error())
+		}
+	}
+	return byt, nil
+}
+
+func (f *urlFetcher) download(rawURL string) ([]byte, error) {
+	resp, err := f.client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: status=[%d] url=[%s]", resp.StatusCode, rawURL)
+	}
+	if f.maxSize > 0 && resp.ContentLength > f.maxSize {
+		return nil, fmt.Errorf("download failed: too large file size=[%d] url=[%s]", resp.ContentLength, rawURL)
+	}
+
+	var r io.Reader = resp.Body
+	if f.maxSize > 0 {
+		// Content-Length can be missing or wrong.
+		r = io.LimitReader(resp.Body, f.maxSize+1)
+	}
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if f.maxSize > 0 && int64(len(byt)) > f.maxSize {
+		return nil, fmt.Errorf("download failed: too large file size=[>%d] url=[%s]", f.maxSize, rawURL)
+	}
+	return byt, nil
+}
+
+// getCachePath returns the cache file path from the hash of the URL.
+func (f *urlFetcher) getCachePath(rawURL string) string {
+	h := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(f.cacheDir, hex.EncodeToString(h[:])+getURLExt(rawURL))
+}
+
+// saveCache writes the file atomically not to leave a broken cache.
+func (f *urlFetcher) saveCache(rawURL string, byt []byte) error {
+	if err := os.MkdirAll(f.cacheDir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(f.cacheDir, ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(byt); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.getCachePath(rawURL))
+}
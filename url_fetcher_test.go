@@ -0,0 +1,285 @@
+package fda
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+func newTestPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newTestTempDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "fda-fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestURLFetcherFetch(t *testing.T) {
+	img := newTestPNG(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(img)
+	}))
+	defer ts.Close()
+
+	f := newURLFetcher(1, time.Second, 0, "")
+	byt, err := f.Fetch(ts.URL + "/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(byt, img) {
+		t.Errorf("content = %d bytes, want %d bytes", len(byt), len(img))
+	}
+}
+
+func TestURLFetcherMaxDownloadSize(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), 100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// flushing before writing the body removes Content-Length.
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		maxSize int64
+		wantErr bool
+	}{
+		{name: "content length over limit", path: "/sized", maxSize: 99, wantErr: true},
+		{name: "body over limit without content length", path: "/chunked", maxSize: 99, wantErr: true},
+		{name: "same as limit", path: "/chunked", maxSize: 100},
+		{name: "no limit", path: "/sized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newURLFetcher(1, time.Second, tt.maxSize, "")
+			byt, err := f.Fetch(ts.URL + tt.path)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "too large") {
+					t.Errorf("err = %v, want too large error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(byt) != len(body) {
+				t.Errorf("content = %d bytes, want %d bytes", len(byt), len(body))
+			}
+		})
+	}
+}
+
+func TestURLFetcherTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	f := newURLFetcher(1, 50*time.Millisecond, 0, "")
+	start := time.Now()
+	if _, err := f.Fetch(ts.URL + "/slow.png"); err == nil {
+		t.Error("err = nil, want timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("elapsed = %s, want to stop by the timeout", elapsed)
+	}
+}
+
+func TestURLFetcherCache(t *testing.T) {
+	img := newTestPNG(t)
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write(img)
+	}))
+	defer ts.Close()
+
+	dir, cleanup := newTestTempDir(t)
+	defer cleanup()
+	cacheDir := filepath.Join(dir, "cache")
+	rawURL := ts.URL + "/a.png?size=large"
+
+	f := newURLFetcher(1, time.Second, 0, cacheDir)
+	for i := 0; i < 2; i++ {
+		byt, err := f.Fetch(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(byt, img) {
+			t.Errorf("#%d content = %d bytes, want %d bytes", i, len(byt), len(img))
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+
+	cachePath := f.getCachePath(rawURL)
+	if filepath.Ext(cachePath) != ".png" {
+		t.Errorf("cache path = %s, want the extension of URL path", cachePath)
+	}
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Errorf("cache file is not saved: %s", err.Error())
+	}
+}
+
+func TestURLFetcherDenyPrivateNetwork(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer ts.Close()
+
+	f := newURLFetcher(1, time.Second, 0, "")
+	f.denyPrivateNetwork()
+	_, err := f.Fetch(ts.URL + "/a.png")
+	if err == nil || !strings.Contains(err.Error(), "private network") {
+		t.Errorf("err = %v, want private network error", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("requests = %d, want 0", n)
+	}
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"::", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"8.8.8.8", false},
+		{"172.32.0.1", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := isPrivateIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isPrivateIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// testEngine returns a face for any image.
+type testEngine struct{}
+
+func (testEngine) Init(conf engine.Config) error { return nil }
+func (testEngine) String() string                { return "test" }
+
+func (e testEngine) Detect(imgPath string) (engine.FaceResult, error) {
+	byt, err := ioutil.ReadFile(imgPath)
+	if err != nil {
+		return engine.FaceResult{}, err
+	}
+	return e.DetectBytes(byt)
+}
+
+func (e testEngine) DetectBytes(byt []byte) (engine.FaceResult, error) {
+	return engine.FaceResult{
+		EngineName: e.String(),
+		Faces:      []engine.FaceData{{Width: 2, Height: 2}},
+	}, nil
+}
+
+func TestDetectFromCSVDownloadError(t *testing.T) {
+	img := newTestPNG(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(img)
+	}))
+	defer ts.Close()
+
+	dir, cleanup := newTestTempDir(t)
+	defer cleanup()
+	input := filepath.Join(dir, "input.csv")
+	output := filepath.Join(dir, "output.tsv")
+	list := strings.Join([]string{"path", ts.URL + "/ok.png", ts.URL + "/missing.png"}, "\n")
+	if err := ioutil.WriteFile(input, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{
+		InputPath:    input,
+		OutputPath:   output,
+		OutputFormat: outputFormatTSV,
+	}
+	opts := []Option{
+		WithConfig(conf),
+		WithEngines(testEngine{}),
+		WithURLFetch(2, time.Second, defaultMaxDownloadSize, ""),
+	}
+	if err := detectFromCSV(conf, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := readDetectResult(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string]detectResultRow)
+	for _, row := range result.Rows {
+		rows[row.Path] = row
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rows = %d, want 2", len(rows))
+	}
+
+	ok := rows[ts.URL+"/ok.png"]
+	if ok.Error != "" {
+		t.Errorf("error of ok.png = %s, want empty", ok.Error)
+	}
+	if got := ok.Line["test"+colSuffixCount]; got != "1" {
+		t.Errorf("count of ok.png = %s, want 1", got)
+	}
+
+	missing := rows[ts.URL+"/missing.png"]
+	if !strings.Contains(missing.Error, "status=[404]") {
+		t.Errorf("error of missing.png = %s, want status 404", missing.Error)
+	}
+	if _, ok := missing.Results["test"]; ok {
+		t.Error("missing.png has the result, want no result")
+	}
+}
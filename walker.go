@@ -0,0 +1,344 @@
+package fda
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultWalkConcurrency = 8
+	walkReadDirBatchSize   = 1000
+)
+
+// WalkOption is options for WalkFiles.
+type WalkOption struct {
+	// file extensions to find. (empty means all files except dot files)
+	Types []string
+
+	// glob patterns. the pattern with '/' is matched with the relative path from the dir, otherwise with the base name.
+	Include []string
+	Exclude []string
+
+	// file size in bytes. (0 means no limit)
+	MinSize int64
+	MaxSize int64
+
+	// image size in pixels. (0 means no limit)
+	MinWidth  int
+	MaxWidth  int
+	MinHeight int
+	MaxHeight int
+
+	// max depth of directory. (0 means no limit, 1 means the files directly under the dir)
+	MaxDepth int
+	// FollowSymlink walks into the symbolic links of directories. The symbolic links of files are always listed.
+	FollowSymlink bool
+	// Archive expands zip and tar files into the entries like 'images.zip!/train/001.jpg'.
+	// The patterns are matched with the entry name in the archive.
+	Archive bool
+
+	// S3Endpoint is the endpoint of S3 compatible storage to walk S3 path. (empty means AWS S3)
+	S3Endpoint string
+
+	// number of directories read concurrently.
+	Concurrency int
+	// OnError is called when the path cannot be read. (nil means ignoring errors)
+	OnError func(path string, err error)
+}
+
+func (o WalkOption) getConcurrency() int {
+	if o.Concurrency > 0 {
+		return o.Concurrency
+	}
+	return defaultWalkConcurrency
+}
+
+// WalkFiles walks the dir concurrently and calls fn with the path of each file matched with the option.
+// fn is called from multiple goroutines, and the order of the paths is not guaranteed.
+// Unreadable paths under the dir are reported to opt.OnError and skipped.
+// When dir is zip or tar file, fn is called with the entries in the archive.
+// When dir is S3 path like 's3://bucket/prefix', fn is called with the objects under the prefix.
+func WalkFiles(dir string, opt WalkOption, fn func(path string)) error {
+	w := &fileWalker{
+		root:    dir,
+		opt:     opt,
+		types:   newFileType(opt.Types),
+		fn:      fn,
+		visited: make(map[string]struct{}),
+	}
+	w.queueCond = sync.NewCond(&w.queueMu)
+	if isS3Path(dir) {
+		w.s3 = newS3Storage(opt.S3Endpoint)
+		return w.walkS3(dir)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if !isArchiveFile(dir) {
+			return fmt.Errorf("'%s' is not dir", dir)
+		}
+		return w.walkArchive(dir)
+	}
+
+	w.push(walkDirJob{path: dir})
+	var wg sync.WaitGroup
+	for i := 0; i < opt.getConcurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+type fileWalker struct {
+	root  string
+	opt   WalkOption
+	types fileType
+	fn    func(path string)
+	s3    *s3Storage
+
+	// directories to read by the fixed number of workers.
+	queueMu   sync.Mutex
+	queueCond *sync.Cond
+	queue     []walkDirJob
+	// number of the directories queued or being read.
+	pending int
+
+	mu      sync.Mutex
+	visited map[string]struct{}
+}
+
+type walkDirJob struct {
+	path  string
+	depth int
+}
+
+func (w *fileWalker) push(j walkDirJob) {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+	w.queue = append(w.queue, j)
+	w.pending++
+	w.queueCond.Signal()
+}
+
+// pop waits for the next directory, and returns false when all of the directories are read.
+// The last queued directory is read first to keep the queue small like depth-first search.
+func (w *fileWalker) pop() (walkDirJob, bool) {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+	for len(w.queue) == 0 && w.pending > 0 {
+		w.queueCond.Wait()
+	}
+	if len(w.queue) == 0 {
+		return walkDirJob{}, false
+	}
+	j := w.queue[len(w.queue)-1]
+	w.queue = w.queue[:len(w.queue)-1]
+	return j, true
+}
+
+func (w *fileWalker) done() {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+	w.pending--
+	if w.pending == 0 {
+		w.queueCond.Broadcast()
+	}
+}
+
+func (w *fileWalker) work() {
+	for {
+		j, ok := w.pop()
+		if !ok {
+			return
+		}
+		w.walkDir(j.path, j.depth)
+		w.done()
+	}
+}
+
+func (w *fileWalker) onError(path string, err error) {
+	if w.opt.OnError != nil {
+		w.opt.OnError(path, err)
+	}
+}
+
+// isVisited checks the real path of the dir to avoid infinite loop of symbolic links.
+func (w *fileWalker) isVisited(dir string) bool {
+	realPath, err := filepath.EvalSymlinks(dir)
+	if err == nil {
+		realPath, err = filepath.Abs(realPath)
+	}
+	if err != nil {
+		w.onError(dir, err)
+		return true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.visited[realPath]; ok {
+		return true
+	}
+	w.visited[realPath] = struct{}{}
+	return false
+}
+
+func (w *fileWalker) walkDir(dir string, depth int) {
+	if w.opt.FollowSymlink && w.isVisited(dir) {
+		return
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		w.onError(dir, err)
+		return
+	}
+	defer f.Close()
+
+	for {
+		// read by batch not to hold whole entries of huge directory.
+		files, err := f.Readdir(walkReadDirBatchSize)
+		for _, file := range files {
+			w.handleEntry(dir, depth, file)
+		}
+
+		switch {
+		case err == io.EOF:
+			return
+		case err != nil:
+			w.onError(dir, err)
+			return
+		}
+	}
+}
+
+func (w *fileWalker) handleEntry(dir string, depth int, file os.FileInfo) {
+	fileName := file.Name()
+	filePath := filepath.Join(dir, fileName)
+	if file.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if file, err = os.Stat(filePath); err != nil {
+			w.onError(filePath, err)
+			return
+		}
+		// the symbolic links of files are always listed, and FollowSymlink is only for directories.
+		if file.IsDir() && !w.opt.FollowSymlink {
+			return
+		}
+	}
+
+	relPath, err := filepath.Rel(w.root, filePath)
+	if err != nil {
+		relPath = filePath
+	}
+
+	if file.IsDir() {
+		if w.opt.isExcludedDir(relPath, depth+1) {
+			return
+		}
+		w.push(walkDirJob{path: filePath, depth: depth + 1})
+		return
+	}
+
+	if w.opt.Archive && isArchiveFile(fileName) {
+		if matchPatterns(w.opt.Exclude, relPath) {
+			return
+		}
+		if err := w.walkArchive(filePath); err != nil {
+			w.onError(filePath, err)
+		}
+		return
+	}
+
+	if !w.types.isTarget(fileName) {
+		return
+	}
+	if !w.opt.isTargetFile(filePath, relPath, file) {
+		return
+	}
+	w.fn(filePath)
+}
+
+// walkArchive calls fn with the entries of zip or tar file in the order of the archive.
+func (w *fileWalker) walkArchive(archivePath string) error {
+	// the entries are not read into memory by Walk.
+	a, err := openArchive(archivePath, 0)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	return a.Walk(func(name string, info os.FileInfo, r io.Reader) error {
+		if isHiddenEntry(name) || !w.types.isTarget(name) {
+			return nil
+		}
+		if !w.opt.isTargetEntry(name, info, r) {
+			return nil
+		}
+		w.fn(joinArchivePath(archivePath, name))
+		return nil
+	})
+}
+
+// walkS3 calls fn with the objects under the S3 prefix.
+// The patterns are matched with the key relative to the prefix.
+func (w *fileWalker) walkS3(dir string) error {
+	bucket, prefix, _ := splitS3Path(dir)
+	return w.s3.Walk(dir, func(key string, info os.FileInfo) error {
+		relPath := strings.TrimPrefix(key, s3DirPrefix(prefix))
+		if isHiddenEntry(relPath) || !w.types.isTarget(key) {
+			return nil
+		}
+		if w.opt.MaxDepth > 0 && strings.Count(relPath, "/") >= w.opt.MaxDepth {
+			return nil
+		}
+		if !w.opt.matchFile(relPath, info.Size()) {
+			return nil
+		}
+
+		filePath := s3Scheme + bucket + "/" + key
+		if w.opt.hasDimensionLimit() && !w.isTargetS3Dimension(filePath) {
+			return nil
+		}
+		w.fn(filePath)
+		return nil
+	})
+}
+
+// isTargetS3Dimension reads the head of the object to check the image size.
+func (w *fileWalker) isTargetS3Dimension(filePath string) bool {
+	byt, err := w.s3.ReadHead(filePath, s3HeadSize)
+	if err != nil {
+		w.onError(filePath, err)
+		return false
+	}
+	conf, _, err := image.DecodeConfig(bytes.NewReader(byt))
+	if err != nil {
+		// the header is larger than the head size.
+		if byt, err = w.s3.ReadFile(filePath); err == nil {
+			conf, _, err = image.DecodeConfig(bytes.NewReader(byt))
+		}
+	}
+	return err == nil && w.opt.matchDimension(conf)
+}
+
+// isHiddenEntry checks dot files and resource forks of macOS in archive.
+func isHiddenEntry(name string) bool {
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return true
+	}
+	return strings.HasPrefix(path.Base(name), ".")
+}
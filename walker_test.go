@@ -0,0 +1,162 @@
+package fda
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// newSymlinkTree creates the tree below and returns the root dir and the function to remove it.
+//
+//	root/
+//	  a.jpg
+//	  link.jpg -> a.jpg
+//	  broken.jpg -> missing.jpg
+//	  linkdir -> ../outside
+//	  loop -> .
+//	outside/
+//	  b.jpg
+func newSymlinkTree(t *testing.T) (string, func()) {
+	t.Helper()
+	base, err := ioutil.TempDir("", "fda-walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup := func() { os.RemoveAll(base) }
+
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(root, "a.jpg"), filepath.Join(outside, "b.jpg")} {
+		if err := ioutil.WriteFile(file, []byte("jpg"), 0644); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+
+	links := [][2]string{
+		{"a.jpg", "link.jpg"},
+		{"missing.jpg", "broken.jpg"},
+		{filepath.Join("..", "outside"), "linkdir"},
+		{".", "loop"},
+	}
+	for _, l := range links {
+		if err := os.Symlink(l[0], filepath.Join(root, l[1])); err != nil {
+			cleanup()
+			t.Skipf("symbolic link is not supported: %s", err.Error())
+		}
+	}
+	return root, cleanup
+}
+
+func walkRelPaths(t *testing.T, root string, opt WalkOption) (paths, errPaths []string) {
+	t.Helper()
+	var mu sync.Mutex
+	opt.OnError = func(path string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errPaths = append(errPaths, path)
+	}
+	err := WalkFiles(root, opt, func(path string) {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	return paths, errPaths
+}
+
+func TestWalkFilesSymlink(t *testing.T) {
+	root, cleanup := newSymlinkTree(t)
+	defer cleanup()
+
+	tests := []struct {
+		name          string
+		followSymlink bool
+		want          []string
+	}{
+		{
+			name: "file links are listed without following dirs",
+			want: []string{"a.jpg", "link.jpg"},
+		},
+		{
+			name:          "dir links are followed once",
+			followSymlink: true,
+			want:          []string{"a.jpg", "link.jpg", "linkdir/b.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, errPaths := walkRelPaths(t, root, WalkOption{FollowSymlink: tt.followSymlink})
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("paths = %v, want %v", paths, tt.want)
+			}
+			if want := []string{filepath.Join(root, "broken.jpg")}; !reflect.DeepEqual(errPaths, want) {
+				t.Errorf("error paths = %v, want %v", errPaths, want)
+			}
+		})
+	}
+}
+
+func TestWalkFilesBoundedGoroutines(t *testing.T) {
+	root, err := ioutil.TempDir("", "fda-walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	const dirs = 200
+	var want []string
+	for i := 0; i < dirs; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%03d", i), "sub")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "a.jpg"), []byte("jpg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, fmt.Sprintf("d%03d/sub/a.jpg", i))
+	}
+
+	const concurrency = 2
+	before := runtime.NumGoroutine()
+	var mu sync.Mutex
+	maxGoroutines := 0
+	paths, errPaths := walkRelPaths(t, root, WalkOption{Concurrency: concurrency})
+	_ = WalkFiles(root, WalkOption{Concurrency: concurrency}, func(path string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if n := runtime.NumGoroutine(); n > maxGoroutines {
+			maxGoroutines = n
+		}
+	})
+
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %d files, want %d files", len(paths), len(want))
+	}
+	if len(errPaths) != 0 {
+		t.Errorf("error paths = %v, want empty", errPaths)
+	}
+	if maxGoroutines > before+concurrency {
+		t.Errorf("goroutines = %d, want at most %d", maxGoroutines, before+concurrency)
+	}
+}
@@ -0,0 +1,278 @@
+package fda
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileStamp is the size and modified time to check the file is changed.
+type fileStamp struct {
+	Size    int64
+	ModTime time.Time
+}
+
+func newFileStamp(info os.FileInfo) fileStamp {
+	return fileStamp{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+}
+
+func (s fileStamp) Equal(o fileStamp) bool {
+	return s.Size == o.Size && s.ModTime.Equal(o.ModTime)
+}
+
+// dirWatcher polls the directory and finds new files which stop changing.
+type dirWatcher struct {
+	dir string
+	opt WalkOption
+	// duration which the file size and modified time must be unchanged.
+	settle time.Duration
+	state  *watchState
+
+	pending map[string]pendingFile
+}
+
+type pendingFile struct {
+	stamp fileStamp
+	since time.Time
+}
+
+func newDirWatcher(dir string, opt WalkOption, settle time.Duration, state *watchState) *dirWatcher {
+	return &dirWatcher{
+		dir:     dir,
+		opt:     opt,
+		settle:  settle,
+		state:   state,
+		pending: make(map[string]pendingFile),
+	}
+}
+
+// Poll returns the files which are not processed yet and unchanged during the settle time.
+func (w *dirWatcher) Poll(now time.Time) ([]string, error) {
+	var mu sync.Mutex
+	stamps := make(map[string]fileStamp)
+	err := WalkFiles(w.dir, w.opt, func(path string) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		stamps[path] = newFileStamp(info)
+		mu.Unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// forget the files removed while waiting.
+	for path := range w.pending {
+		if _, ok := stamps[path]; !ok {
+			delete(w.pending, path)
+		}
+	}
+
+	var ready []string
+	for path, stamp := range stamps {
+		if w.state.IsDone(path, stamp) || w.state.IsWaitingRetry(path, stamp, now) {
+			continue
+		}
+
+		p, ok := w.pending[path]
+		switch {
+		case !ok, !p.stamp.Equal(stamp):
+			w.pending[path] = pendingFile{
+				stamp: stamp,
+				since: now,
+			}
+		case now.Sub(p.since) >= w.settle:
+			delete(w.pending, path)
+			w.state.Begin(path)
+			ready = append(ready, path)
+		}
+	}
+	return ready, nil
+}
+
+// max wait time before retrying the failed file.
+const maxWatchRetryWait = time.Hour
+
+// status of the file given up after the max attempts in the state file.
+const watchStatusFailed = "failed"
+
+// watchState records the processed files into the state file to skip them on restart.
+// The line of state file is 'path<TAB>size<TAB>modified time in unix nano',
+// and the file given up after the max attempts has 'failed' in the 4th column.
+type watchState struct {
+	// max attempts of the failed file. (0 means no limit)
+	maxAttempts int
+	// wait time before the first retry, which is doubled on each failure.
+	retryWait time.Duration
+
+	mu   sync.Mutex
+	fp   *os.File
+	done map[string]fileStamp
+	// files being processed now.
+	running map[string]struct{}
+	// files waiting for the retry.
+	retries map[string]watchRetry
+}
+
+type watchRetry struct {
+	stamp    fileStamp
+	attempts int
+	next     time.Time
+}
+
+func openWatchState(file string, maxAttempts int, retryWait time.Duration) (*watchState, error) {
+	s := &watchState{
+		maxAttempts: maxAttempts,
+		retryWait:   retryWait,
+		done:        make(map[string]fileStamp),
+		running:     make(map[string]struct{}),
+		retries:     make(map[string]watchRetry),
+	}
+	if file == "" {
+		return s, nil
+	}
+
+	fp, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := bufio.NewScanner(fp)
+	for sc.Scan() {
+		cols := strings.Split(sc.Text(), "\t")
+		if len(cols) != 3 && len(cols) != 4 {
+			continue
+		}
+		size, err := strconv.ParseInt(cols[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		nano, err := strconv.ParseInt(cols[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		s.done[cols[0]] = fileStamp{
+			Size:    size,
+			ModTime: time.Unix(0, nano),
+		}
+	}
+	if err := sc.Err(); err != nil {
+		fp.Close()
+		return nil, err
+	}
+	s.fp = fp
+	return s, nil
+}
+
+// IsDone checks the file is already processed and not changed after that, or being processed now.
+func (s *watchState) IsDone(path string, stamp fileStamp) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.running[path]; ok {
+		return true
+	}
+	done, ok := s.done[path]
+	return ok && done.Equal(stamp)
+}
+
+// IsWaitingRetry checks the failed file waits for the next retry. The modified file is retried immediately.
+func (s *watchState) IsWaitingRetry(path string, stamp fileStamp, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.retries[path]
+	return ok && r.stamp.Equal(stamp) && now.Before(r.next)
+}
+
+// MarkDone records the file as processed.
+func (s *watchState) MarkDone(path string, stamp fileStamp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running, path)
+	delete(s.retries, path)
+	return s.record(path, stamp, "")
+}
+
+// Fail counts the attempt of the failed file and returns the wait time before the next retry.
+// The file is recorded as failed after the max attempts, and it's not retried until modified.
+func (s *watchState) Fail(path string, stamp fileStamp, now time.Time) (attempts int, wait time.Duration, failed bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running, path)
+	r := s.retries[path]
+	if !r.stamp.Equal(stamp) {
+		r = watchRetry{stamp: stamp}
+	}
+	r.attempts++
+
+	if s.maxAttempts > 0 && r.attempts >= s.maxAttempts {
+		delete(s.retries, path)
+		return r.attempts, 0, true, s.record(path, stamp, watchStatusFailed)
+	}
+
+	wait = s.retryWait
+	for i := 1; i < r.attempts && wait < maxWatchRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > maxWatchRetryWait {
+		wait = maxWatchRetryWait
+	}
+	r.next = now.Add(wait)
+	s.retries[path] = r
+	return r.attempts, wait, false, nil
+}
+
+// record adds the line of the file into the state file.
+func (s *watchState) record(path string, stamp fileStamp, status string) error {
+	s.done[path] = stamp
+	if s.fp == nil {
+		return nil
+	}
+
+	line := fmt.Sprintf("%s\t%d\t%d", path, stamp.Size, stamp.ModTime.UnixNano())
+	if status != "" {
+		line += "\t" + status
+	}
+	if _, err := fmt.Fprintln(s.fp, line); err != nil {
+		return err
+	}
+	return s.fp.Sync()
+}
+
+// Begin marks the file as being processed.
+func (s *watchState) Begin(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running[path] = struct{}{}
+}
+
+// Cancel unmarks the file to process it again in the next poll.
+func (s *watchState) Cancel(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running, path)
+}
+
+func (s *watchState) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fp == nil {
+		return nil
+	}
+	return s.fp.Close()
+}
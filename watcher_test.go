@@ -0,0 +1,146 @@
+package fda
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+func TestWatchStateFail(t *testing.T) {
+	dir, cleanup := newTestTempDir(t)
+	defer cleanup()
+	file := filepath.Join(dir, "watch.state")
+
+	s, err := openWatchState(file, 3, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0)
+	stamp := fileStamp{Size: 1, ModTime: now}
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+		s.Begin("a.jpg")
+		attempts, wait, failed, err := s.Fail("a.jpg", stamp, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != i+1 || wait != want || failed {
+			t.Errorf("#%d attempts, wait, failed = %d, %s, %v, want %d, %s, false", i, attempts, wait, failed, i+1, want)
+		}
+		if s.IsDone("a.jpg", stamp) {
+			t.Errorf("#%d IsDone = true, want false", i)
+		}
+		if !s.IsWaitingRetry("a.jpg", stamp, now.Add(wait-1)) {
+			t.Errorf("#%d IsWaitingRetry before the wait = false, want true", i)
+		}
+		if s.IsWaitingRetry("a.jpg", stamp, now.Add(wait)) {
+			t.Errorf("#%d IsWaitingRetry after the wait = true, want false", i)
+		}
+	}
+	// the modified file is retried immediately.
+	if s.IsWaitingRetry("a.jpg", fileStamp{Size: 2, ModTime: now}, now) {
+		t.Error("IsWaitingRetry of modified file = true, want false")
+	}
+
+	_, _, failed, err := s.Fail("a.jpg", stamp, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !failed {
+		t.Error("failed = false after the max attempts, want true")
+	}
+	s.Close()
+
+	// the failed file is skipped after restart.
+	s, err = openWatchState(file, 3, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if !s.IsDone("a.jpg", stamp) {
+		t.Error("IsDone of failed file after restart = false, want true")
+	}
+}
+
+// countEngine counts the detections.
+type countEngine struct {
+	testEngine
+	count int32
+}
+
+func (e *countEngine) Detect(imgPath string) (engine.FaceResult, error) {
+	atomic.AddInt32(&e.count, 1)
+	return e.testEngine.Detect(imgPath)
+}
+
+func TestWatchProcessorRetryFailedStep(t *testing.T) {
+	dir, cleanup := newTestTempDir(t)
+	defer cleanup()
+	input := filepath.Join(dir, "input")
+	if err := os.Mkdir(input, 0755); err != nil {
+		t.Fatal(err)
+	}
+	img := filepath.Join(input, "a.png")
+	if err := ioutil.WriteFile(img, newTestPNG(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &countEngine{}
+	pipeline, err := NewPipeline(WithEngines(e))
+	if err != nil {
+		t.Fatal(err)
+	}
+	argv := &watchT{
+		Input:        input,
+		Output:       filepath.Join(dir, "watch.tsv"),
+		State:        filepath.Join(dir, "watch.state"),
+		MaxRetry:     5,
+		Redact:       filepath.Join(dir, "redact"),
+		RedactMethod: redactMethodFill,
+	}
+	p, err := newWatchProcessor(argv, pipeline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// redaction fails since the output is not a directory.
+	if err := os.Remove(argv.Redact); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(argv.Redact, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.state.Begin(img)
+	p.Process(img)
+
+	info, err := os.Stat(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp := newFileStamp(info)
+	if p.state.IsDone(img, stamp) {
+		t.Fatal("IsDone after failure = true, want false")
+	}
+
+	if err := os.Remove(argv.Redact); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(argv.Redact, 0755); err != nil {
+		t.Fatal(err)
+	}
+	p.state.Begin(img)
+	p.Process(img)
+
+	if !p.state.IsDone(img, stamp) {
+		t.Error("IsDone after retry = false, want true")
+	}
+	if n := atomic.LoadInt32(&e.count); n != 1 {
+		t.Errorf("detections = %d, want 1", n)
+	}
+}
@@ -0,0 +1,145 @@
+package fda
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+// engine name of the ground truth from WIDER FACE annotation file.
+const widerEngineName = "wider"
+
+// widerAnnotation is the faces of WIDER FACE annotation by the relative path of the image.
+type widerAnnotation struct {
+	Faces map[string][]engine.FaceData
+	// faces marked as invalid, which are the regions to ignore the detected faces.
+	Invalid map[string][]engine.FaceData
+}
+
+// readWiderAnnotation reads the annotation file of WIDER FACE dataset. (e.g. wider_face_val_bbx_gt.txt)
+// It returns the faces by the relative path of the image, and the faces marked as invalid are separated.
+//
+// The file consists of the image path, the number of faces, and the lines of each face:
+// x1, y1, w, h, blur, expression, illumination, invalid, occlusion, pose
+func readWiderAnnotation(file string) (widerAnnotation, error) {
+	result := widerAnnotation{
+		Faces:   make(map[string][]engine.FaceData),
+		Invalid: make(map[string][]engine.FaceData),
+	}
+	fp, err := os.Open(file)
+	if err != nil {
+		return result, err
+	}
+	defer fp.Close()
+
+	sc := bufio.NewScanner(fp)
+	lineNo := 0
+	next := func() (string, bool) {
+		for sc.Scan() {
+			lineNo++
+			if s := strings.TrimSpace(sc.Text()); s != "" {
+				return s, true
+			}
+		}
+		return "", false
+	}
+
+	for {
+		path, ok := next()
+		if !ok {
+			break
+		}
+		s, ok := next()
+		if !ok {
+			return result, fmt.Errorf("number of faces is missing: line=[%d]", lineNo)
+		}
+		count, err := strconv.Atoi(s)
+		if err != nil {
+			return result, fmt.Errorf("invalid number of faces: line=[%d] [%s]", lineNo, s)
+		}
+
+		faces := make([]engine.FaceData, 0, count)
+		var invalid []engine.FaceData
+		// the image without faces has a line of zeros.
+		lines := count
+		if lines == 0 {
+			lines = 1
+		}
+		for i := 0; i < lines; i++ {
+			s, ok := next()
+			if !ok {
+				return result, fmt.Errorf("face data is missing: line=[%d]", lineNo)
+			}
+			f, valid, err := parseWiderFace(s)
+			if err != nil {
+				return result, fmt.Errorf("invalid face data: line=[%d] [%s]", lineNo, err.Error())
+			}
+			switch {
+			case count == 0:
+			case valid:
+				faces = append(faces, f)
+			default:
+				invalid = append(invalid, f)
+			}
+		}
+		path = filepath.ToSlash(path)
+		result.Faces[path] = faces
+		if len(invalid) != 0 {
+			result.Invalid[path] = invalid
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func parseWiderFace(s string) (f engine.FaceData, valid bool, err error) {
+	cols := strings.Fields(s)
+	if len(cols) < 10 {
+		return f, false, fmt.Errorf("10 columns are required: [%s]", s)
+	}
+	v := make([]int, 10)
+	for i := range v {
+		if v[i], err = strconv.Atoi(cols[i]); err != nil {
+			return f, false, err
+		}
+	}
+
+	f = engine.FaceData{
+		X:      v[0],
+		Y:      v[1],
+		Width:  v[2],
+		Height: v[3],
+		Attributes: &engine.Attributes{
+			Blur:         v[4],
+			Expression:   v[5],
+			Illumination: v[6],
+			Occlusion:    v[8],
+			Pose:         v[9],
+		},
+	}
+	valid = v[7] == 0 && f.Width > 0 && f.Height > 0
+	return f, valid, nil
+}
+
+// getWiderTruthFaces returns the faces of the annotation by the path of the rows.
+// The path of the row is matched with the relative path of the annotation by the suffix.
+func getWiderTruthFaces(rows []detectResultRow, annotation map[string][]engine.FaceData) map[string][]engine.FaceData {
+	truth := make(map[string][]engine.FaceData, len(rows))
+	for _, row := range rows {
+		parts := strings.Split(filepath.ToSlash(row.Path), "/")
+		for i := range parts {
+			if faces, ok := annotation[strings.Join(parts[i:], "/")]; ok {
+				truth[row.Path] = faces
+				break
+			}
+		}
+	}
+	return truth
+}
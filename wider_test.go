@@ -0,0 +1,68 @@
+package fda
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/evalphobia/face-detect-annotator/engine"
+)
+
+func TestReadWiderAnnotationInvalid(t *testing.T) {
+	dir, cleanup := newTestTempDir(t)
+	defer cleanup()
+	file := filepath.Join(dir, "gt.txt")
+	annotation := `0--Parade/a.jpg
+2
+0 0 10 10 0 0 0 0 0 0
+50 50 10 10 0 0 0 1 0 0
+0--Parade/b.jpg
+0
+0 0 0 0 0 0 0 0 0 0
+`
+	if err := ioutil.WriteFile(file, []byte(annotation), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := readWiderAnnotation(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(result.Faces["0--Parade/a.jpg"]); n != 1 {
+		t.Errorf("faces of a.jpg = %d, want 1", n)
+	}
+	if n := len(result.Invalid["0--Parade/a.jpg"]); n != 1 {
+		t.Errorf("invalid faces of a.jpg = %d, want 1", n)
+	}
+	if faces, ok := result.Faces["0--Parade/b.jpg"]; !ok || len(faces) != 0 {
+		t.Errorf("faces of b.jpg = %v, want empty", faces)
+	}
+	if _, ok := result.Invalid["0--Parade/b.jpg"]; ok {
+		t.Error("b.jpg has invalid faces, want none")
+	}
+}
+
+func TestEvaluateEngineIgnore(t *testing.T) {
+	images := []evalImage{{
+		Path:   "a.jpg",
+		Truth:  []engine.FaceData{{X: 0, Y: 0, Width: 10, Height: 10}},
+		Ignore: []engine.FaceData{{X: 50, Y: 50, Width: 20, Height: 20}},
+		Faces: []engine.FaceData{
+			{X: 0, Y: 0, Width: 10, Height: 10, Confidence: 0.9},
+			{X: 50, Y: 50, Width: 10, Height: 10, Confidence: 0.8},
+			{X: 60, Y: 60, Width: 10, Height: 10, Confidence: 0.7},
+			{X: 100, Y: 100, Width: 10, Height: 10, Confidence: 0.6},
+		},
+	}}
+
+	ev := evaluateEngine("test", images, 0.2)
+	if ev.Faces != 2 {
+		t.Errorf("faces = %d, want 2", ev.Faces)
+	}
+	if ev.All.TP != 1 || ev.All.FP != 1 || ev.All.FN != 0 {
+		t.Errorf("tp, fp, fn = %d, %d, %d, want 1, 1, 0", ev.All.TP, ev.All.FP, ev.All.FN)
+	}
+	if n := len(images[0].Faces); n != 4 {
+		t.Errorf("faces of the input = %d, want unchanged 4", n)
+	}
+}